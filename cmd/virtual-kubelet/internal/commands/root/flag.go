@@ -72,6 +72,8 @@ func installFlags(flags *pflag.FlagSet, c *Opts) {
 	flags.StringVar(&c.Provider, "provider", c.Provider, "cloud provider")
 	flags.StringVar(&c.ProviderConfigPath, "provider-config", c.ProviderConfigPath, "cloud provider configuration file")
 	flags.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "address to listen for metrics/stats requests")
+	/* #nosec */
+	flags.MarkDeprecated("metrics-addr", "this option is ignored, /metrics and /stats are served on the main listener as the nodes/metrics and nodes/stats subresources, and without authentication on --read-only-addr") //nolint:errcheck
 
 	flags.StringVar(&c.TaintKey, "taint", c.TaintKey, "Set node taint key")
 
@@ -99,6 +101,35 @@ func installFlags(flags *pflag.FlagSet, c *Opts) {
 			" automatically closed, default 30s.")
 	flags.DurationVar(&c.StreamCreationTimeout, "stream-creation-timeout", c.StreamCreationTimeout,
 		"stream-creation-timeout is the maximum time for streaming connection, default 30s.")
+	flags.DurationVar(&c.HTTPDrainTimeout, "http-drain-timeout", c.HTTPDrainTimeout,
+		"how long to wait on shutdown for http requests and exec, attach, port-forward and log streams to end before closing them")
+
+	flags.StringVar(&c.NodeShutdownPolicy, "node-shutdown-policy", c.NodeShutdownPolicy,
+		"what to do with the node object on shutdown, one of: None, Cordon, Delete")
+	flags.DurationVar(&c.NodeShutdownTimeout, "node-shutdown-timeout", c.NodeShutdownTimeout,
+		"maximum time to spend applying the node shutdown policy")
+	flags.DurationVar(&c.NodeShutdownDrainTimeout, "node-shutdown-drain-timeout", c.NodeShutdownDrainTimeout,
+		"how long to wait for pods to be evicted from the node on shutdown, pods are not waited for when 0")
+	flags.BoolVar(&c.ReconcileNode, "reconcile-node", c.ReconcileNode,
+		"watch the node object and restore it when it is deleted, or its labels and taints are removed")
+	flags.BoolVar(&c.AdaptiveHeartbeat, "adaptive-heartbeat", c.AdaptiveHeartbeat,
+		"jitter node status updates, skip them when only the heartbeat would change and back off when the API server throttles requests")
+	flags.DurationVar(&c.HeartbeatMaxStaleness, "heartbeat-max-staleness", c.HeartbeatMaxStaleness,
+		"longest time the node status goes without being updated with --adaptive-heartbeat, 5m when 0")
+	flags.BoolVar(&c.RotateServerCertificates, "rotate-server-certificates", c.RotateServerCertificates,
+		"request the serving certificate through a kubernetes.io/kubelet-serving CertificateSigningRequest and rotate it before it expires")
+	flags.StringVar(&c.CertDir, "cert-dir", c.CertDir,
+		"directory to store the serving certificate requested with --rotate-server-certificates in, it is only kept in memory when empty")
+	flags.StringVar(&c.StaticAuthPolicyFile, "static-auth-policy-file", c.StaticAuthPolicyFile,
+		"authenticate and authorize requests from local files instead of through the API server, using the policy in this file; client certificates are verified with APISERVER_CA_CERT_LOCATION")
+	flags.StringVar(&c.StaticAuthTokenFile, "static-auth-token-file", c.StaticAuthTokenFile,
+		"CSV file of bearer tokens (token,user,uid,\"group1,group2\") to authenticate with --static-auth-policy-file")
+	flags.StringVar(&c.ReadOnlyAddr, "read-only-addr", c.ReadOnlyAddr,
+		"address to serve the read-only pods, stats, metrics and health routes on, without TLS or authentication, like the kubelet read-only port; disabled when empty")
+	flags.StringVar(&c.UnixSocketPath, "unix-socket", c.UnixSocketPath,
+		"path of a unix socket to serve the API on without authentication, the socket is only accessible to the user running virtual-kubelet; disabled when empty")
+	flags.StringVar(&c.AuditLogPath, "audit-log-path", c.AuditLogPath,
+		"file to write audit events for exec, attach, port-forward, run and container logs requests to as JSON lines, '-' for stdout; disabled when empty")
 
 	flagset := flag.NewFlagSet("klog", flag.PanicOnError)
 	klog.InitFlags(flagset)
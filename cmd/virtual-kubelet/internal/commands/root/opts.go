@@ -23,6 +23,8 @@ import (
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/node"
+	"github.com/virtual-kubelet/virtual-kubelet/node/nodeutil"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -41,6 +43,9 @@ const (
 	DefaultTaintKey              = "virtual-kubelet.io/provider"
 	DefaultStreamIdleTimeout     = 30 * time.Second
 	DefaultStreamCreationTimeout = 30 * time.Second
+	DefaultNodeShutdownPolicy    = string(node.NodeShutdownPolicyNone)
+	DefaultNodeShutdownTimeout   = node.DefaultNodeShutdownTimeout
+	DefaultHTTPDrainTimeout      = nodeutil.DefaultHTTPDrainTimeout
 )
 
 // Opts stores all the options for configuring the root virtual-kubelet command.
@@ -72,8 +77,14 @@ type Opts struct {
 	TaintEffect  string
 	DisableTaint bool
 
+	// MetricsAddr is ignored, the metrics are served on the main listener and on ReadOnlyAddr
 	MetricsAddr string
 
+	// ReadOnlyAddr is the address to serve the read-only pods, stats, metrics and health routes on, without TLS or auth
+	ReadOnlyAddr string
+	// UnixSocketPath is the path of a unix socket to serve the API on without auth, for trusted local agents
+	UnixSocketPath string
+
 	// Number of workers to use to handle pod notifications
 	PodSyncWorkers       int
 	InformerResyncPeriod time.Duration
@@ -92,6 +103,36 @@ type Opts struct {
 	StreamIdleTimeout time.Duration
 	// StreamCreationTimeout is the maximum time for streaming connection
 	StreamCreationTimeout time.Duration
+	// HTTPDrainTimeout is how long to wait on shutdown for http requests and streaming sessions to end
+	HTTPDrainTimeout time.Duration
+
+	// NodeShutdownPolicy determines what happens to the node object when virtual-kubelet exits
+	NodeShutdownPolicy string
+	// NodeShutdownTimeout is the maximum time to spend applying the node shutdown policy
+	NodeShutdownTimeout time.Duration
+	// NodeShutdownDrainTimeout is how long to wait for pods to be evicted from the node on shutdown
+	NodeShutdownDrainTimeout time.Duration
+
+	// ReconcileNode restores the node object when it is deleted or modified by others
+	ReconcileNode bool
+
+	// AdaptiveHeartbeat jitters node status updates and skips them when the status has not changed
+	AdaptiveHeartbeat bool
+	// HeartbeatMaxStaleness is the longest the node status goes without being updated with the adaptive heartbeat
+	HeartbeatMaxStaleness time.Duration
+
+	// RotateServerCertificates requests the serving certificate through a CertificateSigningRequest and rotates it
+	RotateServerCertificates bool
+	// CertDir is the directory to store the serving certificate in when it is requested through a CSR
+	CertDir string
+
+	// StaticAuthPolicyFile enables static auth, authorizing requests with the policy in this file instead of through the API server
+	StaticAuthPolicyFile string
+	// StaticAuthTokenFile is the CSV file of bearer tokens to authenticate with static auth
+	StaticAuthTokenFile string
+
+	// AuditLogPath is the file to write audit events for exec, attach, port-forward, run and container logs requests to
+	AuditLogPath string
 
 	Version string
 }
@@ -174,5 +215,17 @@ func SetDefaultOpts(c *Opts) error {
 		c.StreamCreationTimeout = DefaultStreamCreationTimeout
 	}
 
+	if c.HTTPDrainTimeout == 0 {
+		c.HTTPDrainTimeout = DefaultHTTPDrainTimeout
+	}
+
+	if c.NodeShutdownPolicy == "" {
+		c.NodeShutdownPolicy = DefaultNodeShutdownPolicy
+	}
+
+	if c.NodeShutdownTimeout == 0 {
+		c.NodeShutdownTimeout = DefaultNodeShutdownTimeout
+	}
+
 	return nil
 }
@@ -31,7 +31,6 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	"github.com/virtual-kubelet/virtual-kubelet/node/nodeutil"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apiserver/pkg/server/dynamiccertificates"
 )
 
 // NewCommand creates a new top-level command.
@@ -114,6 +113,15 @@ func runRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		return err
 	}
 
+	// The client CA is shared by the TLS config and the authentication of requests so they agree when it is reloaded.
+	var clientCA *nodeutil.ClientCA
+	if apiConfig.CACertPath != "" {
+		clientCA, err = nodeutil.NewClientCAFromPathReload(apiConfig.CACertPath)
+		if err != nil {
+			return err
+		}
+	}
+
 	cm, err := nodeutil.NewNode(c.NodeName, newProvider, func(cfg *nodeutil.NodeConfig) error {
 		cfg.KubeconfigPath = c.KubeConfigPath
 		cfg.Handler = mux
@@ -128,19 +136,57 @@ func runRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		cfg.HTTPListenAddr = apiConfig.Addr
 		cfg.StreamCreationTimeout = apiConfig.StreamCreationTimeout
 		cfg.StreamIdleTimeout = apiConfig.StreamIdleTimeout
+		cfg.HTTPDrainTimeout = c.HTTPDrainTimeout
 		cfg.DebugHTTP = true
 
 		cfg.NumWorkers = c.PodSyncWorkers
 
+		cfg.ShutdownPolicy = node.NodeShutdownPolicy(c.NodeShutdownPolicy)
+		cfg.ShutdownTimeout = c.NodeShutdownTimeout
+		cfg.ShutdownDrainTimeout = c.NodeShutdownDrainTimeout
+		cfg.ReconcileNode = c.ReconcileNode
+		if c.AdaptiveHeartbeat {
+			cfg.AdaptiveHeartbeat = &node.HeartbeatConfig{MaxStaleness: c.HeartbeatMaxStaleness}
+		}
+		cfg.Configz = map[string]interface{}{"options": c}
+
+		if c.RotateServerCertificates {
+			if err := nodeutil.WithServingCertificateBootstrap(c.CertDir)(cfg); err != nil {
+				return err
+			}
+		}
+
+		// The additional listeners get the routes without the auth configured for the main listener.
+		if c.ReadOnlyAddr != "" {
+			h := nodeutil.ReadOnlyHandler(api.InstrumentHandler(nodeutil.WithAuth(nodeutil.NoAuth(), mux)))
+			if err := nodeutil.WithHTTPListener(nodeutil.HTTPListener{Network: "tcp", Addr: c.ReadOnlyAddr, Handler: h})(cfg); err != nil {
+				return err
+			}
+		}
+		if c.UnixSocketPath != "" {
+			h := api.InstrumentHandler(nodeutil.WithAuth(nodeutil.NoAuth(), mux))
+			if err := nodeutil.WithHTTPListener(nodeutil.HTTPListener{Network: "unix", Addr: c.UnixSocketPath, Handler: h})(cfg); err != nil {
+				return err
+			}
+		}
+
+		if c.AuditLogPath != "" {
+			if err := nodeutil.WithAuditLog(c.AuditLogPath)(cfg); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	},
 		nodeutil.WithClient(clientSet),
-		setAuth(c.NodeName, apiConfig),
+		setAuth(c, apiConfig, clientCA),
 		nodeutil.WithTLSConfig(
-			nodeutil.WithKeyPairFromPath(apiConfig.CertPath, apiConfig.KeyPath),
-			maybeCA(apiConfig.CACertPath),
+			maybeKeyPair(apiConfig.CertPath, apiConfig.KeyPath, c.RotateServerCertificates),
+			maybeCA(clientCA),
 		),
 		nodeutil.AttachProviderRoutes(mux),
+		nodeutil.AttachHealthRoutes(mux),
+		nodeutil.AttachMetricsRoutes(mux),
 	)
 	if err != nil {
 		return err
@@ -180,8 +226,26 @@ func runRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 	return nil
 }
 
-func setAuth(node string, apiCfg *apiServerConfig) nodeutil.NodeOpt {
-	if apiCfg.CACertPath == "" {
+func setAuth(c Opts, apiCfg *apiServerConfig, clientCA *nodeutil.ClientCA) nodeutil.NodeOpt {
+	node := c.NodeName
+	if c.StaticAuthPolicyFile != "" {
+		return func(cfg *nodeutil.NodeConfig) error {
+			auth, err := nodeutil.StaticAuth(node, func(cfg *nodeutil.StaticAuthConfig) error {
+				cfg.ClientCAFile = apiCfg.CACertPath
+				cfg.TokenFile = c.StaticAuthTokenFile
+				cfg.PolicyFile = c.StaticAuthPolicyFile
+				cfg.Reload = true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			cfg.Handler = api.InstrumentHandler(nodeutil.WithAuth(auth, cfg.Handler))
+			return nil
+		}
+	}
+
+	if clientCA == nil {
 		return func(cfg *nodeutil.NodeConfig) error {
 			cfg.Handler = api.InstrumentHandler(nodeutil.WithAuth(nodeutil.NoAuth(), cfg.Handler))
 			return nil
@@ -190,9 +254,8 @@ func setAuth(node string, apiCfg *apiServerConfig) nodeutil.NodeOpt {
 
 	return func(cfg *nodeutil.NodeConfig) error {
 		auth, err := nodeutil.WebhookAuth(cfg.Client, node, func(cfg *nodeutil.WebhookAuthConfig) error {
-			var err error
-			cfg.AuthnConfig.ClientCertificateCAContentProvider, err = dynamiccertificates.NewDynamicCAContentFromFile("ca-cert-bundle", apiCfg.CACertPath)
-			return err
+			cfg.AuthnConfig.ClientCertificateCAContentProvider = clientCA
+			return nil
 		})
 		if err != nil {
 			return err
@@ -202,9 +265,18 @@ func setAuth(node string, apiCfg *apiServerConfig) nodeutil.NodeOpt {
 	}
 }
 
-func maybeCA(p string) func(*tls.Config) error {
-	if p == "" {
+// maybeKeyPair loads the serving key pair from disk.
+// The key pair is optional when the serving certificate is requested from the cluster.
+func maybeKeyPair(cert, key string, rotate bool) func(*tls.Config) error {
+	if rotate && cert == "" && key == "" {
+		return func(*tls.Config) error { return nil }
+	}
+	return nodeutil.WithKeyPairFromPathReload(cert, key)
+}
+
+func maybeCA(ca *nodeutil.ClientCA) func(*tls.Config) error {
+	if ca == nil {
 		return func(*tls.Config) error { return nil }
 	}
-	return nodeutil.WithCAFromPath(p)
+	return ca.TLSConfig
 }
@@ -1,11 +1,12 @@
 package mock
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"os"
 	"strings"
 	"time"
@@ -31,6 +32,16 @@ const (
 	namespaceKey     = "namespace"
 	nameKey          = "name"
 	containerNameKey = "containerName"
+
+	// Rates of the dummy network usage of pods.
+	mockRxBytesPerSecond = 2048
+	mockTxBytesPerSecond = 1024
+)
+
+var (
+	// Dummy resource usage of containers without requests.
+	mockContainerCPU    = resource.MustParse("100m")
+	mockContainerMemory = resource.MustParse("64Mi")
 )
 
 // See: https://github.com/virtual-kubelet/virtual-kubelet/issues/632
@@ -294,6 +305,54 @@ func (p *MockProvider) GetContainerLogs(ctx context.Context, namespace, podName,
 	return io.NopCloser(strings.NewReader("")), nil
 }
 
+// CheckpointContainer creates a checkpoint of a container in the pod.
+// The mock checkpoint is a tar archive holding the pod and container spec.
+func (p *MockProvider) CheckpointContainer(ctx context.Context, namespace, podName, containerName string, opts api.ContainerCheckpointOpts) (*api.ContainerCheckpoint, error) {
+	ctx, span := trace.StartSpan(ctx, "CheckpointContainer")
+	defer span.End()
+
+	// Add pod and container attributes to the current span.
+	ctx = addAttributes(ctx, span, namespaceKey, namespace, nameKey, podName, containerNameKey, containerName)
+
+	log.G(ctx).Infof("receive CheckpointContainer %q", containerName)
+
+	pod, err := p.GetPod(ctx, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	var container *v1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			container = &pod.Spec.Containers[i]
+		}
+	}
+	if container == nil {
+		return nil, errdefs.NotFoundf("container %q not found in pod \"%s/%s\"", containerName, namespace, podName)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		obj  interface{}
+	}{{"spec.dump", pod}, {"config.dump", container}} {
+		b, err := json.Marshal(f.obj)
+		if err != nil {
+			return nil, err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0600, Size: int64(len(b)), ModTime: time.Now()}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &api.ContainerCheckpoint{Archive: io.NopCloser(&buf)}, nil
+}
+
 // RunInContainer executes a command in a container in the pod, copying data
 // between in/out/err and the container's stdin/stdout/stderr.
 func (p *MockProvider) RunInContainer(ctx context.Context, namespace, name, container string, cmd []string, attach api.AttachIO) error {
@@ -454,209 +513,68 @@ func (p *MockProvider) nodeDaemonEndpoints() v1.NodeDaemonEndpoints {
 	}
 }
 
-// GetStatsSummary returns dummy stats for all pods known by this provider.
-func (p *MockProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
+// GetStatsSamples returns dummy resource usage samples for all pods known by this provider.
+// Containers use the CPU and memory they request, pods receive and send a constant rate of bytes.
+func (p *MockProvider) GetStatsSamples(ctx context.Context) ([]api.PodStatsSample, error) {
 	var span trace.Span
-	ctx, span = trace.StartSpan(ctx, "GetStatsSummary") //nolint: ineffassign,staticcheck
+	ctx, span = trace.StartSpan(ctx, "GetStatsSamples") //nolint: ineffassign,staticcheck
 	defer span.End()
 
-	// Grab the current timestamp so we can report it as the time the stats were generated.
-	time := metav1.NewTime(time.Now())
-
-	// Create the Summary object that will later be populated with node and pod stats.
-	res := &stats.Summary{}
-
-	// Populate the Summary object with basic node stats.
-	res.Node = stats.NodeStats{
-		NodeName:  p.nodeName,
-		StartTime: metav1.NewTime(p.startTime),
-	}
-
-	// Populate the Summary object with dummy stats for each pod known by this provider.
+	now := time.Now()
+	samples := make([]api.PodStatsSample, 0, len(p.pods))
 	for _, pod := range p.pods {
-		var (
-			// totalUsageNanoCores will be populated with the sum of the values of UsageNanoCores computes across all containers in the pod.
-			totalUsageNanoCores uint64
-			// totalUsageBytes will be populated with the sum of the values of UsageBytes computed across all containers in the pod.
-			totalUsageBytes uint64
-		)
-
-		// Create a PodStats object to populate with pod stats.
-		pss := stats.PodStats{
+		startTime := pod.CreationTimestamp.Time
+		if pod.Status.StartTime != nil {
+			startTime = pod.Status.StartTime.Time
+		}
+		elapsed := now.Sub(startTime)
+
+		sample := api.PodStatsSample{
 			PodRef: stats.PodReference{
 				Name:      pod.Name,
 				Namespace: pod.Namespace,
 				UID:       string(pod.UID),
 			},
-			StartTime: pod.CreationTimestamp,
+			StartTime: startTime,
+			Time:      now,
+			Network: []api.NetworkInterfaceSample{{
+				Name:    "eth0",
+				RxBytes: uint64(elapsed.Seconds() * mockRxBytesPerSecond),
+				TxBytes: uint64(elapsed.Seconds() * mockTxBytesPerSecond),
+			}},
 		}
-
-		// Iterate over all containers in the current pod to compute dummy stats.
 		for _, container := range pod.Spec.Containers {
-			// Grab a dummy value to be used as the total CPU usage.
-			// The value should fit a uint32 in order to avoid overflows later on when computing pod stats.
-
-			/* #nosec */
-			dummyUsageNanoCores := uint64(rand.Uint32())
-			totalUsageNanoCores += dummyUsageNanoCores
-			// Create a dummy value to be used as the total RAM usage.
-			// The value should fit a uint32 in order to avoid overflows later on when computing pod stats.
-
-			/* #nosec */
-			dummyUsageBytes := uint64(rand.Uint32())
-			totalUsageBytes += dummyUsageBytes
-			// Append a ContainerStats object containing the dummy stats to the PodStats object.
-			pss.Containers = append(pss.Containers, stats.ContainerStats{
-				Name:      container.Name,
-				StartTime: pod.CreationTimestamp,
-				CPU: &stats.CPUStats{
-					Time:           time,
-					UsageNanoCores: &dummyUsageNanoCores,
-				},
-				Memory: &stats.MemoryStats{
-					Time:       time,
-					UsageBytes: &dummyUsageBytes,
-				},
+			cpu, memory := mockContainerCPU, mockContainerMemory
+			if q, ok := container.Resources.Requests[v1.ResourceCPU]; ok {
+				cpu = q
+			}
+			if q, ok := container.Resources.Requests[v1.ResourceMemory]; ok {
+				memory = q
+			}
+			sample.Containers = append(sample.Containers, api.ContainerStatsSample{
+				Name:                    container.Name,
+				StartTime:               startTime,
+				CPUUsageCoreNanoSeconds: uint64(float64(cpu.MilliValue()) / 1000 * float64(elapsed)),
+				MemoryUsageBytes:        uint64(memory.Value()),
+				MemoryWorkingSetBytes:   uint64(memory.Value()),
+				MemoryRSSBytes:          uint64(memory.Value()),
 			})
 		}
-
-		// Populate the CPU and RAM stats for the pod and append the PodsStats object to the Summary object to be returned.
-		pss.CPU = &stats.CPUStats{
-			Time:           time,
-			UsageNanoCores: &totalUsageNanoCores,
-		}
-		pss.Memory = &stats.MemoryStats{
-			Time:       time,
-			UsageBytes: &totalUsageBytes,
-		}
-		res.Pods = append(res.Pods, pss)
-	}
-
-	// Return the dummy stats.
-	return res, nil
-}
-
-func (p *MockProvider) generateMockMetrics(metricsMap map[string][]*dto.Metric, resourceType string, label []*dto.LabelPair) map[string][]*dto.Metric {
-	var (
-		cpuMetricSuffix    = "_cpu_usage_seconds_total"
-		memoryMetricSuffix = "_memory_working_set_bytes"
-		dummyValue         = float64(100)
-	)
-
-	if metricsMap == nil {
-		metricsMap = map[string][]*dto.Metric{}
-	}
-
-	finalCpuMetricName := resourceType + cpuMetricSuffix
-	finalMemoryMetricName := resourceType + memoryMetricSuffix
-
-	newCPUMetric := dto.Metric{
-		Label: label,
-		Counter: &dto.Counter{
-			Value: &dummyValue,
-		},
-	}
-	newMemoryMetric := dto.Metric{
-		Label: label,
-		Gauge: &dto.Gauge{
-			Value: &dummyValue,
-		},
-	}
-	// if metric family exists add to metric array
-	if cpuMetrics, ok := metricsMap[finalCpuMetricName]; ok {
-		metricsMap[finalCpuMetricName] = append(cpuMetrics, &newCPUMetric)
-	} else {
-		metricsMap[finalCpuMetricName] = []*dto.Metric{&newCPUMetric}
-	}
-	if memoryMetrics, ok := metricsMap[finalMemoryMetricName]; ok {
-		metricsMap[finalMemoryMetricName] = append(memoryMetrics, &newMemoryMetric)
-	} else {
-		metricsMap[finalMemoryMetricName] = []*dto.Metric{&newMemoryMetric}
+		samples = append(samples, sample)
 	}
-
-	return metricsMap
+	return samples, nil
 }
 
-func (p *MockProvider) getMetricType(metricName string) *dto.MetricType {
-	var (
-		dtoCounterMetricType = dto.MetricType_COUNTER
-		dtoGaugeMetricType   = dto.MetricType_GAUGE
-		cpuMetricSuffix      = "_cpu_usage_seconds_total"
-		memoryMetricSuffix   = "_memory_working_set_bytes"
-	)
-	if strings.HasSuffix(metricName, cpuMetricSuffix) {
-		return &dtoCounterMetricType
-	}
-	if strings.HasSuffix(metricName, memoryMetricSuffix) {
-		return &dtoGaugeMetricType
-	}
-
-	return nil
+// GetStatsSummary is not used, the stats are built from GetStatsSamples by the routes attached with
+// nodeutil.AttachProviderRoutes.
+func (p *MockProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
+	return nil, errdefs.NotImplemented("stats are built from the stats samples")
 }
 
+// GetMetricsResource is not used, the metrics are built from GetStatsSamples by the routes attached with
+// nodeutil.AttachProviderRoutes.
 func (p *MockProvider) GetMetricsResource(ctx context.Context) ([]*dto.MetricFamily, error) {
-	var span trace.Span
-	ctx, span = trace.StartSpan(ctx, "GetMetricsResource") //nolint: ineffassign,staticcheck
-	defer span.End()
-
-	var (
-		nodeNameStr      = "NodeName"
-		podNameStr       = "PodName"
-		containerNameStr = "containerName"
-	)
-	nodeLabels := []*dto.LabelPair{
-		{
-			Name:  &nodeNameStr,
-			Value: &p.nodeName,
-		},
-	}
-
-	metricsMap := p.generateMockMetrics(nil, "node", nodeLabels)
-	for _, pod := range p.pods {
-		podLabels := []*dto.LabelPair{
-			{
-				Name:  &nodeNameStr,
-				Value: &p.nodeName,
-			},
-			{
-				Name:  &podNameStr,
-				Value: &pod.Name,
-			},
-		}
-		metricsMap = p.generateMockMetrics(metricsMap, "pod", podLabels)
-		for _, container := range pod.Spec.Containers {
-			containerLabels := []*dto.LabelPair{
-				{
-					Name:  &nodeNameStr,
-					Value: &p.nodeName,
-				},
-				{
-					Name:  &podNameStr,
-					Value: &pod.Name,
-				},
-				{
-					Name:  &containerNameStr,
-					Value: &container.Name,
-				},
-			}
-			metricsMap = p.generateMockMetrics(metricsMap, "container", containerLabels)
-		}
-	}
-
-	res := []*dto.MetricFamily{}
-	for metricName := range metricsMap {
-		tempName := metricName
-		tempMetrics := metricsMap[tempName]
-
-		metricFamily := dto.MetricFamily{
-			Name:   &tempName,
-			Type:   p.getMetricType(tempName),
-			Metric: tempMetrics,
-		}
-		res = append(res, &metricFamily)
-	}
-
-	return res, nil
+	return nil, errdefs.NotImplemented("metrics are built from the stats samples")
 }
 
 // NotifyPods is called to set a pod notifier callback function. This should be called before any operations are done
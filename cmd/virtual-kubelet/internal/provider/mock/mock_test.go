@@ -1,5 +1,20 @@
 package mock
 
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
 // We can guarantee the right interfaces are implemented inside of by putting casts in place. We must do the verification
 // that a given type *does not* implement a given interface in this test.
 // Cannot implement this due to:  https://github.com/virtual-kubelet/virtual-kubelet/issues/632
@@ -10,3 +25,51 @@ func TestMockLegacyInterface(t *testing.T) {
 	assert.Assert(t, !ok)
 }
 */
+
+func TestCheckpointContainer(t *testing.T) {
+	p, err := NewMockProviderMockConfig(MockConfig{}, "vk", "linux", "127.0.0.1", 10250)
+	assert.NilError(t, err)
+	p.NotifyPods(context.Background(), func(*v1.Pod) {})
+
+	pod := &v1.Pod{}
+	pod.Namespace = "default"
+	pod.Name = "foo"
+	pod.Spec.Containers = []v1.Container{{Name: "bar", Image: "busybox"}}
+	assert.NilError(t, p.CreatePod(context.Background(), pod))
+
+	srv := httptest.NewServer(api.PodHandler(api.PodHandlerConfig{CheckpointContainer: p.CheckpointContainer}, false))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/checkpoint/default/foo/bar?timeout=10", "", nil)
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	assert.Assert(t, is.Equal(resp.StatusCode, http.StatusOK))
+	assert.Check(t, is.Equal(resp.Header.Get("Content-Type"), "application/x-tar"))
+
+	tr := tar.NewReader(resp.Body)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		names = append(names, hdr.Name)
+		if hdr.Name == "config.dump" {
+			var c v1.Container
+			assert.NilError(t, json.NewDecoder(tr).Decode(&c))
+			assert.Check(t, is.Equal(c.Image, "busybox"))
+		}
+	}
+	assert.Check(t, is.DeepEqual(names, []string{"spec.dump", "config.dump"}))
+
+	resp, err = http.Post(srv.URL+"/checkpoint/default/foo/missing", "", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, is.Equal(resp.StatusCode, http.StatusNotFound))
+
+	resp, err = http.Post(srv.URL+"/checkpoint/default/foo/bar?timeout=abc", "", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, is.Equal(resp.StatusCode, http.StatusBadRequest))
+}
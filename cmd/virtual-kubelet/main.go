@@ -48,6 +48,9 @@ func main() {
 	go func() {
 		<-sig
 		cancel()
+		// Shutting down is bounded by timeouts, but allow a second signal to exit right away.
+		<-sig
+		os.Exit(1)
 	}()
 
 	log.L = logruslogger.FromLogrus(logrus.NewEntry(logrus.StandardLogger()))
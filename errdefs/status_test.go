@@ -0,0 +1,55 @@
+package errdefs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	"gotest.tools/assert/cmp"
+)
+
+func TestStatusCategories(t *testing.T) {
+	type testCase struct {
+		name string
+		as   func(error) error
+		is   func(error) bool
+	}
+
+	for _, c := range []testCase{
+		{name: "Unauthorized", as: AsUnauthorized, is: IsUnauthorized},
+		{name: "Forbidden", as: AsForbidden, is: IsForbidden},
+		{name: "Conflict", as: AsConflict, is: IsConflict},
+		{name: "NotImplemented", as: AsNotImplemented, is: IsNotImplemented},
+		{name: "Unavailable", as: AsUnavailable, is: IsUnavailable},
+		{
+			name: "TooManyRequests",
+			as:   func(err error) error { return AsTooManyRequests(err, time.Second) },
+			is:   IsTooManyRequests,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := errors.New("this is a test")
+			e := c.as(err)
+			assert.Check(t, cmp.Equal(e.Error(), "this is a test"))
+			assert.Check(t, c.is(e))
+			assert.Check(t, c.is(errors.Wrap(e, "some details")))
+			assert.Check(t, cmp.Equal(e.(causal).Cause(), err))
+			assert.Check(t, !c.is(err))
+			assert.Check(t, !c.is(nil))
+			assert.Check(t, cmp.Nil(c.as(nil)))
+			assert.Check(t, !IsNotFound(e))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Check(t, cmp.Equal(RetryAfter(nil), time.Duration(0)))
+	assert.Check(t, cmp.Equal(RetryAfter(errors.New("test")), time.Duration(0)))
+	assert.Check(t, cmp.Equal(RetryAfter(TooManyRequests("test", 0)), time.Duration(0)))
+
+	err := TooManyRequestsf(3*time.Second, "%s is busy", "foo")
+	assert.Check(t, cmp.Equal(err.Error(), "foo is busy"))
+	assert.Check(t, cmp.Equal(RetryAfter(err), 3*time.Second))
+	assert.Check(t, cmp.Equal(RetryAfter(errors.Wrap(err, "some details")), 3*time.Second))
+}
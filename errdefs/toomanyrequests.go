@@ -0,0 +1,91 @@
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTooManyRequests is an error interface which denotes whether the operation failed due
+// to the caller being rate limited.
+type ErrTooManyRequests interface {
+	TooManyRequests() bool
+	error
+}
+
+type tooManyRequestsError struct {
+	error
+	retryAfter time.Duration
+}
+
+func (e *tooManyRequestsError) TooManyRequests() bool {
+	return true
+}
+
+func (e *tooManyRequestsError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+func (e *tooManyRequestsError) Cause() error {
+	return e.error
+}
+
+// AsTooManyRequests wraps the passed in error to make it of type ErrTooManyRequests.
+// retryAfter is how long the caller should wait before retrying, 0 if unknown.
+//
+// Callers should make sure the passed in error has exactly the error message
+// it wants as this function does not decorate the message.
+func AsTooManyRequests(err error, retryAfter time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &tooManyRequestsError{err, retryAfter}
+}
+
+// TooManyRequests makes an ErrTooManyRequests from the provided error message
+func TooManyRequests(msg string, retryAfter time.Duration) error {
+	return &tooManyRequestsError{errors.New(msg), retryAfter}
+}
+
+// TooManyRequestsf makes an ErrTooManyRequests from the provided error format and args
+func TooManyRequestsf(retryAfter time.Duration, format string, args ...interface{}) error {
+	return &tooManyRequestsError{fmt.Errorf(format, args...), retryAfter}
+}
+
+// IsTooManyRequests determines if the passed in error is of type ErrTooManyRequests
+//
+// This will traverse the causal chain (`Cause() error`), until it finds an error
+// which implements the `TooManyRequests` interface.
+func IsTooManyRequests(err error) bool {
+	if err == nil {
+		return false
+	}
+	if e, ok := err.(ErrTooManyRequests); ok {
+		return e.TooManyRequests()
+	}
+
+	if e, ok := err.(causal); ok {
+		return IsTooManyRequests(e.Cause())
+	}
+
+	return false
+}
+
+// RetryAfter returns how long the caller should wait before retrying the failed operation.
+//
+// This will traverse the causal chain (`Cause() error`), until it finds an error
+// which implements `RetryAfter() time.Duration`. It returns 0 if there is none.
+func RetryAfter(err error) time.Duration {
+	if err == nil {
+		return 0
+	}
+	if e, ok := err.(interface{ RetryAfter() time.Duration }); ok {
+		return e.RetryAfter()
+	}
+
+	if e, ok := err.(causal); ok {
+		return RetryAfter(e.Cause())
+	}
+
+	return 0
+}
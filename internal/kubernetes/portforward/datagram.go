@@ -0,0 +1,86 @@
+package portforward
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// MaxDatagramSize is the maximum size of the datagrams forwarded for the datagram protocols.
+//
+// The data streams of the datagram protocols carry frames made of the length of a datagram, as an unsigned 16 bit
+// integer in big endian format, followed by the datagram.
+const MaxDatagramSize = 1<<16 - 1
+
+// datagramStream decodes and encodes the frames of a data stream so each read returns a single datagram and each
+// write sends a single datagram, like the connections of datagram sockets.
+type datagramStream struct {
+	stream io.ReadWriteCloser
+
+	rmu     sync.Mutex
+	rheader [2]byte
+
+	wmu sync.Mutex
+}
+
+func newDatagramStream(stream io.ReadWriteCloser) *datagramStream {
+	return &datagramStream{stream: stream}
+}
+
+// Read reads the next datagram into p.
+// Like with datagram sockets, the end of a datagram which is larger than p is discarded.
+func (s *datagramStream) Read(p []byte) (int, error) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	if _, err := io.ReadFull(s.stream, s.rheader[:]); err != nil {
+		if err == io.EOF {
+			// The stream ended between two frames.
+			return 0, err
+		}
+		return 0, datagramReadError(err)
+	}
+	size := int(binary.BigEndian.Uint16(s.rheader[:]))
+
+	n := min(size, len(p))
+	if _, err := io.ReadFull(s.stream, p[:n]); err != nil {
+		return 0, datagramReadError(err)
+	}
+	if n < size {
+		if _, err := io.CopyN(io.Discard, s.stream, int64(size-n)); err != nil {
+			return 0, datagramReadError(err)
+		}
+	}
+	return n, nil
+}
+
+// datagramReadError reports the end of the stream in the middle of a frame as an error.
+func datagramReadError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("truncated datagram frame: %w", io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// Write sends p as a single datagram.
+func (s *datagramStream) Write(p []byte) (int, error) {
+	if len(p) > MaxDatagramSize {
+		return 0, fmt.Errorf("datagram of %d bytes is larger than the maximum of %d bytes", len(p), MaxDatagramSize)
+	}
+
+	frame := make([]byte, 2+len(p))
+	binary.BigEndian.PutUint16(frame, uint16(len(p)))
+	copy(frame[2:], p)
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if _, err := s.stream.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *datagramStream) Close() error {
+	return s.stream.Close()
+}
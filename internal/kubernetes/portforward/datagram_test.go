@@ -0,0 +1,50 @@
+package portforward
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type bufferStream struct {
+	bytes.Buffer
+}
+
+func (*bufferStream) Close() error { return nil }
+
+func TestDatagramStream(t *testing.T) {
+	buf := &bufferStream{}
+	s := newDatagramStream(buf)
+
+	for _, d := range []string{"hello", "", "world"} {
+		if n, err := s.Write([]byte(d)); err != nil || n != len(d) {
+			t.Fatalf("write %q: n=%d, err=%v", d, n, err)
+		}
+	}
+	if e, a := "\x00\x05hello\x00\x00\x00\x05world", buf.String(); e != a {
+		t.Fatalf("expected frames %q, got %q", e, a)
+	}
+	if _, err := s.Write(make([]byte, MaxDatagramSize+1)); err == nil {
+		t.Fatal("expected an error writing a datagram larger than the maximum")
+	}
+
+	p := make([]byte, 3)
+	for _, expected := range []string{"hel", "", "wor"} {
+		n, err := s.Read(p)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if a := string(p[:n]); a != expected {
+			t.Fatalf("expected datagram %q, got %q", expected, a)
+		}
+	}
+	if _, err := s.Read(p); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	buf.WriteString("\x00\x05hel")
+	if _, err := s.Read(p); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected an unexpected EOF for a truncated frame, got %v", err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"sync"
@@ -71,22 +72,14 @@ func handleHTTPStreams(req *http.Request, w http.ResponseWriter, portForwarder P
 }
 
 // httpStreamReceived is the httpstream.NewStreamHandler for port
-// forward streams. It checks each stream's port and stream type headers,
+// forward streams. It checks each stream's target and stream type headers,
 // rejecting any streams that with missing or invalid values. Each valid
 // stream is sent to the streams channel.
 func httpStreamReceived(streams chan httpstream.Stream) func(httpstream.Stream, <-chan struct{}) error {
 	return func(stream httpstream.Stream, replySent <-chan struct{}) error {
-		// make sure it has a valid port header
-		portString := stream.Headers().Get(api.PortHeader)
-		if len(portString) == 0 {
-			return fmt.Errorf("%q header is required", api.PortHeader)
-		}
-		port, err := strconv.ParseUint(portString, 10, 16)
-		if err != nil {
-			return fmt.Errorf("unable to parse %q as a port: %v", portString, err)
-		}
-		if port < 1 {
-			return fmt.Errorf("port %q must be > 0", portString)
+		// make sure it has a valid target, a port or the path of a unix socket
+		if _, err := parseTarget(stream.Headers().Get); err != nil {
+			return err
 		}
 
 		// make sure it has a valid stream type header
@@ -247,15 +240,19 @@ func (h *httpStreamHandler) portForward(p *httpStreamPair) {
 	defer p.dataStream.Close()
 	defer p.errorStream.Close()
 
-	portString := p.dataStream.Headers().Get(api.PortHeader)
-	port, _ := strconv.ParseInt(portString, 10, 32)
+	// the headers were validated when the stream was received
+	target, _ := parseTarget(p.dataStream.Headers().Get)
+	var stream io.ReadWriteCloser = p.dataStream
+	if target.Datagram() {
+		stream = newDatagramStream(stream)
+	}
 
-	klog.V(5).InfoS("Connection request invoking forwarder.PortForward for port", "connection", h.conn, "request", p.requestID, "port", portString)
-	err := h.forwarder.PortForward(ctx, h.pod, h.uid, int32(port), p.dataStream)
-	klog.V(5).InfoS("Connection request done invoking forwarder.PortForward for port", "connection", h.conn, "request", p.requestID, "port", portString)
+	klog.V(5).InfoS("Connection request invoking forwarder.PortForward for target", "connection", h.conn, "request", p.requestID, "target", target)
+	err := h.forwarder.PortForward(ctx, h.pod, h.uid, target, stream)
+	klog.V(5).InfoS("Connection request done invoking forwarder.PortForward for target", "connection", h.conn, "request", p.requestID, "target", target)
 
 	if err != nil {
-		msg := fmt.Errorf("error forwarding port %d to pod %s, uid %v: %v", port, h.pod, h.uid, err)
+		msg := fmt.Errorf("error forwarding %s to pod %s, uid %v: %v", target, h.pod, h.uid, err)
 		utilruntime.HandleError(msg)
 		fmt.Fprint(p.errorStream, msg.Error())
 	}
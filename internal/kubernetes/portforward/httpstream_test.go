@@ -28,6 +28,8 @@ import (
 func TestHTTPStreamReceived(t *testing.T) {
 	tests := map[string]struct {
 		port          string
+		protocol      string
+		path          string
 		streamType    string
 		expectedError string
 	}{
@@ -59,6 +61,35 @@ func TestHTTPStreamReceived(t *testing.T) {
 			streamType:    "foo",
 			expectedError: `invalid stream type "foo"`,
 		},
+		"valid udp port": {
+			port:       "53",
+			protocol:   "udp",
+			streamType: "data",
+		},
+		"missing udp port": {
+			protocol:      "udp",
+			expectedError: `"port" header is required`,
+		},
+		"valid unix socket": {
+			protocol:   "unix",
+			path:       "/run/app.sock",
+			streamType: "data",
+		},
+		"missing unix socket path": {
+			protocol:      "unixgram",
+			port:          "80",
+			expectedError: `"path" is required for unix sockets`,
+		},
+		"relative unix socket path": {
+			protocol:      "unix",
+			path:          "app.sock",
+			expectedError: `socket path "app.sock" must be absolute`,
+		},
+		"unsupported protocol": {
+			port:          "80",
+			protocol:      "sctp",
+			expectedError: `unsupported protocol "sctp"`,
+		},
 	}
 	for name, test := range tests {
 		streams := make(chan httpstream.Stream, 1)
@@ -67,6 +98,12 @@ func TestHTTPStreamReceived(t *testing.T) {
 		if len(test.port) > 0 {
 			stream.headers.Set("port", test.port)
 		}
+		if len(test.protocol) > 0 {
+			stream.headers.Set("protocol", test.protocol)
+		}
+		if len(test.path) > 0 {
+			stream.headers.Set("path", test.path)
+		}
 		if len(test.streamType) > 0 {
 			stream.headers.Set("streamType", test.streamType)
 		}
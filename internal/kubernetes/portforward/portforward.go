@@ -28,10 +28,11 @@ import (
 )
 
 // PortForwarder knows how to forward content from a data stream to/from a port
-// in a pod.
+// or a socket in a pod.
 type PortForwarder interface {
-	// PortForwarder copies data between a data stream and a port in a pod.
-	PortForward(ctx context.Context, name string, uid types.UID, port int32, stream io.ReadWriteCloser) error
+	// PortForwarder copies data between a data stream and a target in a pod.
+	// The stream of a datagram target reads and writes single datagrams, see MaxDatagramSize.
+	PortForward(ctx context.Context, name string, uid types.UID, target Target, stream io.ReadWriteCloser) error
 }
 
 // ServePortForward handles a port forwarding request.  A single request is
@@ -0,0 +1,116 @@
+package portforward
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+
+	api "k8s.io/api/core/v1"
+)
+
+// Protocols of the targets of port forwarding, named like the networks of package net.
+const (
+	ProtocolTCP      = "tcp"
+	ProtocolUDP      = "udp"
+	ProtocolUnix     = "unix"
+	ProtocolUnixgram = "unixgram"
+)
+
+const (
+	// ProtocolHeader is the stream header, or the query parameter of websocket requests, with the protocol of the
+	// target. Streams without it are forwarded to a TCP port.
+	ProtocolHeader = "protocol"
+	// PathHeader is the stream header, or the query parameter of websocket requests, with the path of the socket in
+	// the pod for the unix protocols.
+	PathHeader = "path"
+)
+
+// Target is where a stream is forwarded to in the pod.
+type Target struct {
+	Protocol string
+	// Port is set for the tcp and udp protocols.
+	Port int32
+	// Path is set for the unix and unixgram protocols.
+	Path string
+}
+
+// Datagram returns whether the protocol of the target is a datagram protocol, whose streams are framed, see
+// MaxDatagramSize.
+func (t Target) Datagram() bool {
+	return t.Protocol == ProtocolUDP || t.Protocol == ProtocolUnixgram
+}
+
+// String describes the target in messages, TCP ports are described as they were before other protocols were
+// supported.
+func (t Target) String() string {
+	switch t.Protocol {
+	case ProtocolTCP:
+		return fmt.Sprintf("port %d", t.Port)
+	case ProtocolUDP:
+		return fmt.Sprintf("udp port %d", t.Port)
+	default:
+		return fmt.Sprintf("%s socket %s", t.Protocol, t.Path)
+	}
+}
+
+// parsePort parses the value of a port header or query parameter.
+func parsePort(s string) (int32, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse %q as a port: %v", s, err)
+	}
+	if port < 1 {
+		return 0, fmt.Errorf("port %q must be > 0", s)
+	}
+	return int32(port), nil
+}
+
+// parseProtocol parses the value of a protocol header or query parameter, which defaults to TCP.
+func parseProtocol(s string) (string, error) {
+	switch s {
+	case "":
+		return ProtocolTCP, nil
+	case ProtocolTCP, ProtocolUDP, ProtocolUnix, ProtocolUnixgram:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unsupported protocol %q", s)
+	}
+}
+
+// validatePath checks the path of a socket for the unix protocols.
+func validatePath(p string) error {
+	if len(p) == 0 {
+		return fmt.Errorf("%q is required for unix sockets", PathHeader)
+	}
+	if !path.IsAbs(p) {
+		return fmt.Errorf("socket path %q must be absolute", p)
+	}
+	return nil
+}
+
+// parseTarget reads the target of a stream from its headers.
+func parseTarget(get func(string) string) (Target, error) {
+	protocol, err := parseProtocol(get(ProtocolHeader))
+	if err != nil {
+		return Target{}, err
+	}
+	t := Target{Protocol: protocol}
+
+	if protocol == ProtocolUnix || protocol == ProtocolUnixgram {
+		t.Path = get(PathHeader)
+		if err := validatePath(t.Path); err != nil {
+			return Target{}, err
+		}
+		return t, nil
+	}
+
+	portString := get(api.PortHeader)
+	if len(portString) == 0 {
+		return Target{}, fmt.Errorf("%q header is required", api.PortHeader)
+	}
+	t.Port, err = parsePort(portString)
+	if err != nil {
+		return Target{}, err
+	}
+	return t, nil
+}
@@ -50,6 +50,10 @@ const (
 // CRI (k8s.io/cri-api/pkg/apis/{version}/api.proto) PortForwardRequest.
 type V4Options struct {
 	Ports []int32
+	// Protocol is the protocol of the targets, TCP when it is not set.
+	Protocol string
+	// Paths are the paths of the sockets to forward to for the unix protocols, instead of Ports.
+	Paths []string
 }
 
 // NewV4Options creates a new options from the Request.
@@ -58,7 +62,25 @@ func NewV4Options(req *http.Request) (*V4Options, error) {
 		return &V4Options{}, nil
 	}
 
-	portStrings := req.URL.Query()[api.PortHeader]
+	query := req.URL.Query()
+	protocol := query.Get(ProtocolHeader)
+	if _, err := parseProtocol(protocol); err != nil {
+		return nil, err
+	}
+	if protocol == ProtocolUnix || protocol == ProtocolUnixgram {
+		paths := query[PathHeader]
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("query parameter %q is required", PathHeader)
+		}
+		for _, p := range paths {
+			if err := validatePath(p); err != nil {
+				return nil, err
+			}
+		}
+		return &V4Options{Protocol: protocol, Paths: paths}, nil
+	}
+
+	portStrings := query[api.PortHeader]
 	if len(portStrings) == 0 {
 		return nil, fmt.Errorf("query parameter %q is required", api.PortHeader)
 	}
@@ -81,7 +103,8 @@ func NewV4Options(req *http.Request) (*V4Options, error) {
 	}
 
 	return &V4Options{
-		Ports: ports,
+		Ports:    ports,
+		Protocol: protocol,
 	}, nil
 }
 
@@ -90,13 +113,31 @@ func BuildV4Options(ports []int32) (*V4Options, error) {
 	return &V4Options{Ports: ports}, nil
 }
 
+// Targets returns the targets to forward to, one per port or socket path.
+func (o *V4Options) Targets() []Target {
+	protocol, _ := parseProtocol(o.Protocol)
+	if protocol == ProtocolUnix || protocol == ProtocolUnixgram {
+		targets := make([]Target, 0, len(o.Paths))
+		for _, p := range o.Paths {
+			targets = append(targets, Target{Protocol: protocol, Path: p})
+		}
+		return targets
+	}
+	targets := make([]Target, 0, len(o.Ports))
+	for _, port := range o.Ports {
+		targets = append(targets, Target{Protocol: protocol, Port: port})
+	}
+	return targets
+}
+
 // handleWebSocketStreams handles requests to forward ports to a pod via
-// a PortForwarder. A pair of streams are created per port (DATA n,
+// a PortForwarder. A pair of streams are created per port or socket (DATA n,
 // ERROR n+1). The associated port is written to each stream as a unsigned 16
-// bit integer in little endian format.
+// bit integer in little endian format, it is 0 for sockets.
 func handleWebSocketStreams(req *http.Request, w http.ResponseWriter, portForwarder PortForwarder, podName string, uid types.UID, opts *V4Options, supportedPortForwardProtocols []string, idleTimeout, streamCreationTimeout time.Duration) error {
-	channels := make([]wsstream.ChannelType, 0, len(opts.Ports)*2)
-	for i := 0; i < len(opts.Ports); i++ {
+	targets := opts.Targets()
+	channels := make([]wsstream.ChannelType, 0, len(targets)*2)
+	for i := 0; i < len(targets); i++ {
 		channels = append(channels, wsstream.ReadWriteChannel, wsstream.WriteChannel)
 	}
 	conn := wsstream.NewConn(map[string]wsstream.ChannelProtocolConfig{
@@ -120,10 +161,10 @@ func handleWebSocketStreams(req *http.Request, w http.ResponseWriter, portForwar
 		return err
 	}
 	defer conn.Close()
-	streamPairs := make([]*websocketStreamPair, len(opts.Ports))
+	streamPairs := make([]*websocketStreamPair, len(targets))
 	for i := range streamPairs {
 		streamPair := websocketStreamPair{
-			port:        opts.Ports[i],
+			target:      targets[i],
 			dataStream:  streams[i*2+dataChannel],
 			errorStream: streams[i*2+errorChannel],
 		}
@@ -131,7 +172,7 @@ func handleWebSocketStreams(req *http.Request, w http.ResponseWriter, portForwar
 
 		portBytes := make([]byte, 2)
 		// port is always positive so conversion is allowable
-		binary.LittleEndian.PutUint16(portBytes, uint16(streamPair.port))
+		binary.LittleEndian.PutUint16(portBytes, uint16(streamPair.target.Port))
 		streamPair.dataStream.Write(portBytes)
 		streamPair.errorStream.Write(portBytes)
 	}
@@ -150,7 +191,7 @@ func handleWebSocketStreams(req *http.Request, w http.ResponseWriter, portForwar
 // websocketStreamPair represents the error and data streams for a port
 // forwarding request.
 type websocketStreamPair struct {
-	port        int32
+	target      Target
 	dataStream  io.ReadWriteCloser
 	errorStream io.WriteCloser
 }
@@ -187,12 +228,17 @@ func (h *websocketStreamHandler) portForward(p *websocketStreamPair) {
 	defer p.dataStream.Close()
 	defer p.errorStream.Close()
 
-	klog.V(5).InfoS("Connection invoking forwarder.PortForward for port", "connection", h.conn, "port", p.port)
-	err := h.forwarder.PortForward(ctx, h.pod, h.uid, p.port, p.dataStream)
-	klog.V(5).InfoS("Connection done invoking forwarder.PortForward for port", "connection", h.conn, "port", p.port)
+	var stream io.ReadWriteCloser = p.dataStream
+	if p.target.Datagram() {
+		stream = newDatagramStream(stream)
+	}
+
+	klog.V(5).InfoS("Connection invoking forwarder.PortForward for target", "connection", h.conn, "target", p.target)
+	err := h.forwarder.PortForward(ctx, h.pod, h.uid, p.target, stream)
+	klog.V(5).InfoS("Connection done invoking forwarder.PortForward for target", "connection", h.conn, "target", p.target)
 
 	if err != nil {
-		msg := fmt.Errorf("error forwarding port %d to pod %s, uid %v: %v", p.port, h.pod, h.uid, err)
+		msg := fmt.Errorf("error forwarding %s to pod %s, uid %v: %v", p.target, h.pod, h.uid, err)
 		runtime.HandleError(msg)
 		fmt.Fprint(p.errorStream, msg.Error())
 	}
@@ -69,6 +69,32 @@ func TestV4Options(t *testing.T) {
 				Ports: []int32{80, 90},
 			},
 		},
+		"udp ports": {
+			url:       "http://example.com?port=53&protocol=udp",
+			websocket: true,
+			expectedOpts: &V4Options{
+				Ports:    []int32{53},
+				Protocol: "udp",
+			},
+		},
+		"unix sockets": {
+			url:       "http://example.com?protocol=unix&path=/run/a.sock&path=/run/b,c.sock",
+			websocket: true,
+			expectedOpts: &V4Options{
+				Protocol: "unix",
+				Paths:    []string{"/run/a.sock", "/run/b,c.sock"},
+			},
+		},
+		"missing unix socket path": {
+			url:           "http://example.com?protocol=unix&port=80",
+			websocket:     true,
+			expectedError: `query parameter "path" is required`,
+		},
+		"unsupported protocol": {
+			url:           "http://example.com?port=80&protocol=sctp",
+			websocket:     true,
+			expectedError: `unsupported protocol "sctp"`,
+		},
 	}
 	for name, test := range tests {
 		req, err := http.NewRequest(http.MethodGet, test.url, nil)
@@ -99,3 +125,17 @@ func TestV4Options(t *testing.T) {
 		}
 	}
 }
+
+func TestV4OptionsTargets(t *testing.T) {
+	opts := &V4Options{Ports: []int32{80, 90}}
+	expected := []Target{{Protocol: ProtocolTCP, Port: 80}, {Protocol: ProtocolTCP, Port: 90}}
+	if targets := opts.Targets(); !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+
+	opts = &V4Options{Protocol: ProtocolUnixgram, Ports: []int32{80}, Paths: []string{"/run/app.sock"}}
+	expected = []Target{{Protocol: ProtocolUnixgram, Path: "/run/app.sock"}}
+	if targets := opts.Targets(); !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+}
@@ -0,0 +1,93 @@
+package remotecommand
+
+import (
+	"bytes"
+	gocontext "context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// stdinExecutor writes the stdin it reads to stdout, followed by EOF if stdin was closed.
+type stdinExecutor struct {
+	wait time.Duration
+}
+
+func (e stdinExecutor) ExecInContainer(name string, uid types.UID, container string, cmd []string, in io.Reader, out, err io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize, timeout time.Duration) error {
+	var (
+		buf  bytes.Buffer
+		done = make(chan error, 1)
+	)
+	go func() {
+		_, err := io.Copy(&buf, in)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s EOF", buf.String())
+		return err
+	case <-time.After(e.wait):
+		// buf is still written to by the copy, only report that stdin was not closed.
+		_, err := fmt.Fprint(out, "no EOF")
+		return err
+	}
+}
+
+func TestWebSocketStdinClose(t *testing.T) {
+	tests := map[string]struct {
+		protocol       string
+		expectedOutput string
+	}{
+		"v5 closes stdin": {
+			protocol:       "v5.channel.k8s.io",
+			expectedOutput: "hello EOF",
+		},
+		"v4 does not close stdin": {
+			protocol:       "v4.channel.k8s.io",
+			expectedOutput: "no EOF",
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		opts, err := NewOptions(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ServeExec(w, req, stdinExecutor{wait: 500 * time.Millisecond}, "pod", "", "container", []string{"cat"}, opts, time.Minute, time.Minute, nil)
+	}))
+	defer srv.Close()
+
+	for name, test := range tests {
+		exec, err := remotecommand.NewWebSocketExecutorForProtocols(&restclient.Config{Host: srv.URL}, "GET", srv.URL+"/exec?input=1&output=1", test.protocol)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+
+		var stdout bytes.Buffer
+		err = exec.StreamWithContext(gocontext.Background(), remotecommand.StreamOptions{
+			Stdin:  strings.NewReader("hello"),
+			Stdout: &stdout,
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		if e, a := test.expectedOutput, stdout.String(); e != a {
+			t.Errorf("%s: expected output %q, got %q", name, e, a)
+		}
+	}
+}
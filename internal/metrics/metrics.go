@@ -0,0 +1,70 @@
+// Package metrics holds the Prometheus registry for the metrics virtual-kubelet collects about itself.
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Namespace is the prefix of all virtual-kubelet metrics.
+const Namespace = "virtual_kubelet"
+
+// Registry is the registry all virtual-kubelet metrics are registered with.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Error classes returned by ErrorClass.
+const (
+	ErrorClassNone             = "none"
+	ErrorClassNotFound         = "not_found"
+	ErrorClassInvalidInput     = "invalid_input"
+	ErrorClassConflict         = "conflict"
+	ErrorClassTooManyRequests  = "too_many_requests"
+	ErrorClassUnavailable      = "unavailable"
+	ErrorClassCanceled         = "canceled"
+	ErrorClassDeadlineExceeded = "deadline_exceeded"
+	ErrorClassOther            = "other"
+)
+
+// ErrorClass returns a low cardinality classification of the error, suitable for a metric label.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errdefs.IsNotFound(err), apierrors.IsNotFound(err):
+		return ErrorClassNotFound
+	case errdefs.IsInvalidInput(err), apierrors.IsBadRequest(err), apierrors.IsInvalid(err):
+		return ErrorClassInvalidInput
+	case errdefs.IsConflict(err), apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return ErrorClassConflict
+	case errdefs.IsTooManyRequests(err), apierrors.IsTooManyRequests(err):
+		return ErrorClassTooManyRequests
+	case errdefs.IsUnavailable(err), apierrors.IsServiceUnavailable(err):
+		return ErrorClassUnavailable
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded), apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return ErrorClassDeadlineExceeded
+	default:
+		return ErrorClassOther
+	}
+}
+
+// Result returns "success" or "error" depending on err, for use as a metric label.
+func Result(err error) string {
+	if err == nil {
+		return "success"
+	}
+	return "error"
+}
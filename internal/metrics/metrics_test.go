@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestErrorClass(t *testing.T) {
+	for err, class := range map[error]string{
+		nil:                     ErrorClassNone,
+		errdefs.NotFound("foo"): ErrorClassNotFound,
+		errors.Wrap(errdefs.NotFound("foo"), "bar"):                               ErrorClassNotFound,
+		apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "foo"):      ErrorClassNotFound,
+		errdefs.InvalidInput("foo"):                                               ErrorClassInvalidInput,
+		apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "foo", nil): ErrorClassConflict,
+		apierrors.NewTooManyRequests("foo", 1):                                    ErrorClassTooManyRequests,
+		errors.Wrap(context.Canceled, "foo"):                                      ErrorClassCanceled,
+		context.DeadlineExceeded:                                                  ErrorClassDeadlineExceeded,
+		errors.New("foo"):                                                         ErrorClassOther,
+	} {
+		assert.Check(t, is.Equal(ErrorClass(err), class), "%v", err)
+	}
+}
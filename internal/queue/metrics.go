@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/virtual-kubelet/virtual-kubelet/internal/metrics"
+)
+
+const metricsSubsystem = "queue"
+
+var (
+	depthMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "depth",
+		Help:      "Number of items waiting in the queue, not including items being processed.",
+	}, []string{"name"})
+	addsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "adds_total",
+		Help:      "Number of items added to the queue which were not already queued.",
+	}, []string{"name"})
+	latencyMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "latency_seconds",
+		Help:      "How long items wait in the queue after they are due for processing until a worker picks them up.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"name"})
+	workDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "work_duration_seconds",
+		Help:      "How long it takes to process an item.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"name"})
+	retriesMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "retries_total",
+		Help:      "Number of items requeued after failing to be processed.",
+	}, []string{"name"})
+	dropsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "drops_total",
+		Help:      "Number of items which failed to be processed and are not retried anymore, e.g. after MaxRetries.",
+	}, []string{"name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(depthMetric, addsMetric, latencyMetric, workDurationMetric, retriesMetric, dropsMetric)
+}
+
+// updateDepth updates the depth metric, it must be called with the lock held.
+func (q *Queue) updateDepth() {
+	depthMetric.WithLabelValues(q.name).Set(float64(len(q.itemsInQueue)))
+}
@@ -140,6 +140,7 @@ func (q *Queue) Forget(ctx context.Context, key string) {
 		span.WithField(ctx, "status", "itemInQueue")
 		delete(q.itemsInQueue, key)
 		q.items.Remove(item)
+		q.updateDepth()
 		return
 	}
 
@@ -212,6 +213,8 @@ func (q *Queue) insert(ctx context.Context, key string, ratelimit bool, delay *t
 	}
 
 	span.WithField(ctx, "status", "added")
+	addsMetric.WithLabelValues(q.name).Inc()
+	defer q.updateDepth()
 	now := q.clock.Now()
 	val := &queueItem{
 		key:                  key,
@@ -377,7 +380,9 @@ func (q *Queue) getNextItem(ctx context.Context) (*queueItem, error) {
 				q.itemsBeingProcessed[qi.key] = qi
 				q.items.Remove(element)
 				delete(q.itemsInQueue, qi.key)
+				q.updateDepth()
 				q.lock.Unlock()
+				latencyMetric.WithLabelValues(q.name).Observe((-timeUntilProcessing).Seconds())
 				return qi, nil
 			}
 
@@ -451,7 +456,9 @@ func (q *Queue) handleQueueItemObject(ctx context.Context, qi *queueItem) error
 	// Add the current key as an attribute to the current span.
 	ctx = span.WithField(ctx, "key", qi.key)
 	// Run the syncHandler, passing it the namespace/name string of the Pod resource to be synced.
+	start := q.clock.Now()
 	err := q.handler(ctx, qi.key)
+	workDurationMetric.WithLabelValues(q.name).Observe(q.clock.Since(start).Seconds())
 
 	q.lock.Lock()
 	defer q.lock.Unlock()
@@ -473,12 +480,14 @@ func (q *Queue) handleQueueItemObject(ctx context.Context, qi *queueItem) error
 		if err == nil {
 			// Put the item back on the work Queue to handle any transient errors.
 			log.G(ctx).WithError(originalError).Warnf("requeuing %q due to failed sync", qi.key)
+			retriesMetric.WithLabelValues(q.name).Inc()
 			newQI := q.insert(ctx, qi.key, true, delay)
 			newQI.requeues = qi.requeues + 1
 			newQI.originallyAdded = qi.originallyAdded
 
 			return nil
 		}
+		dropsMetric.WithLabelValues(q.name).Inc()
 		if !qi.redirtiedAt.IsZero() {
 			err = fmt.Errorf("temporarily (requeued) forgetting %q due to: %w", qi.key, err)
 		} else {
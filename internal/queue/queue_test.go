@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	logruslogger "github.com/virtual-kubelet/virtual-kubelet/log/logrus"
@@ -41,6 +42,7 @@ func TestQueueMaxRetries(t *testing.T) {
 		&workqueue.TypedBucketRateLimiter[any]{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
 	), t.Name(), handler, nil)
 	wq.Enqueue(context.TODO(), "test")
+	assert.Check(t, is.Equal(testutil.ToFloat64(depthMetric.WithLabelValues(t.Name())), 1.0))
 
 	for n < MaxRetries {
 		assert.Assert(t, wq.handleQueueItem(ctx))
@@ -48,6 +50,10 @@ func TestQueueMaxRetries(t *testing.T) {
 
 	assert.Assert(t, is.Equal(n, MaxRetries))
 	assert.Assert(t, is.Equal(0, wq.Len()))
+	assert.Check(t, is.Equal(testutil.ToFloat64(depthMetric.WithLabelValues(t.Name())), 0.0))
+	assert.Check(t, is.Equal(testutil.ToFloat64(addsMetric.WithLabelValues(t.Name())), float64(MaxRetries)))
+	assert.Check(t, is.Equal(testutil.ToFloat64(retriesMetric.WithLabelValues(t.Name())), float64(MaxRetries-1)))
+	assert.Check(t, is.Equal(testutil.ToFloat64(dropsMetric.WithLabelValues(t.Name())), 1.0))
 }
 
 func TestQueueCustomRetries(t *testing.T) {
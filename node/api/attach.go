@@ -21,7 +21,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/internal/kubernetes/remotecommand"
 	"k8s.io/apimachinery/pkg/types"
@@ -33,8 +32,7 @@ import (
 type ContainerAttachHandlerFunc func(ctx context.Context, namespace, podName, containerName string, attach AttachIO) error
 
 // HandleContainerAttach makes an http handler func from a Provider which execs a command in a pod's container
-// Note that this handler currently depends on gorrilla/mux to get url parts as variables.
-// TODO(@cpuguy83): don't force gorilla/mux on consumers of this function
+// The namespace, pod and container are read from the path parameters of the request, see PathParam.
 func HandleContainerAttach(h ContainerAttachHandlerFunc, opts ...ContainerExecHandlerOption) http.HandlerFunc {
 	if h == nil {
 		return NotImplemented
@@ -53,7 +51,7 @@ func HandleContainerAttach(h ContainerAttachHandlerFunc, opts ...ContainerExecHa
 	}
 
 	return handleError(func(w http.ResponseWriter, req *http.Request) error {
-		vars := mux.Vars(req)
+		vars := podPathParams(req)
 
 		namespace := vars["namespace"]
 		pod := vars["pod"]
@@ -66,10 +64,10 @@ func HandleContainerAttach(h ContainerAttachHandlerFunc, opts ...ContainerExecHa
 			return errdefs.AsInvalidInput(err)
 		}
 
-		ctx, cancel := context.WithCancel(req.Context())
+		ctx, cancel := streamSessionFrom(req.Context()).withDrain(req.Context())
 		defer cancel()
 
-		attach := &containerAttachContext{ctx: ctx, h: h, pod: pod, namespace: namespace, container: container}
+		attach := &containerAttachContext{ctx: ctx, h: h, pod: pod, namespace: namespace, container: container, audit: auditEventFrom(req.Context()), record: cfg.SessionRecording}
 		remotecommand.ServeAttach(
 			w,
 			req,
@@ -91,6 +89,8 @@ type containerAttachContext struct {
 	h                         ContainerAttachHandlerFunc
 	namespace, pod, container string
 	ctx                       context.Context
+	audit                     *AuditEvent
+	record                    SessionRecordingSink
 }
 
 // AttachToContainer Implements remotecommand.Attacher
@@ -111,11 +111,26 @@ func (c *containerAttachContext) AttachToContainer(name string, uid types.UID, c
 	ctx, cancel := context.WithCancel(c.ctx)
 	defer cancel()
 
+	rec, recErr := startSessionRecording(ctx, c.record, SessionInfo{
+		Subresource: "attach",
+		Namespace:   c.namespace,
+		Pod:         c.pod,
+		Container:   c.container,
+	}, eio)
+	if recErr != nil {
+		c.audit.recordError(recErr)
+		return recErr
+	}
+	defer rec.Close(ctx)
+	c.audit.recordSession(rec.ID())
+
 	if tty {
 		go func() {
 			send := func(s remoteutils.TerminalSize) bool {
+				size := TermSize{Width: s.Width, Height: s.Height}
+				rec.resize(size)
 				select {
-				case eio.chResize <- TermSize{Width: s.Width, Height: s.Height}:
+				case eio.chResize <- size:
 					return false
 				case <-ctx.Done():
 					return true
@@ -135,5 +150,10 @@ func (c *containerAttachContext) AttachToContainer(name string, uid types.UID, c
 		}()
 	}
 
-	return c.h(c.ctx, c.namespace, c.pod, c.container, eio)
+	attachErr := c.h(c.ctx, c.namespace, c.pod, c.container, eio)
+	if isShuttingDown(c.ctx) {
+		attachErr = errShuttingDown
+	}
+	c.audit.recordError(attachErr)
+	return attachErr
 }
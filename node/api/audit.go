@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Outcomes of audited requests.
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent records a request to one of the sensitive kubelet API endpoints: exec, attach, port-forward, run and
+// container logs.
+type AuditEvent struct {
+	// Time is when the request was received.
+	Time        time.Time `json:"time"`
+	Verb        string    `json:"verb"`
+	Subresource string    `json:"subresource"`
+	RequestURI  string    `json:"requestURI"`
+	SourceIP    string    `json:"sourceIP,omitempty"`
+	// User is the authenticated user, it is only set when the request went through authentication, see nodeutil.WithAuth.
+	User      string   `json:"user,omitempty"`
+	UID       string   `json:"uid,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+	Namespace string   `json:"namespace"`
+	Pod       string   `json:"pod"`
+	Container string   `json:"container,omitempty"`
+	// Command is the command executed, for exec and run requests.
+	Command []string `json:"command,omitempty"`
+	// Ports are the TCP ports forwarded, for port-forward requests.
+	Ports []int32 `json:"ports,omitempty"`
+	// Targets are the other ports and sockets forwarded, for port-forward requests, for example udp/53 or
+	// unix:/run/app.sock.
+	Targets []string `json:"targets,omitempty"`
+	// SessionID is the ID of the session recording, for exec and attach requests, see WithExecSessionRecording.
+	SessionID       string  `json:"sessionID,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	// BytesReceived and BytesSent count the bytes of the request and response, including streamed data.
+	BytesReceived int64  `json:"bytesReceived"`
+	BytesSent     int64  `json:"bytesSent"`
+	Code          int    `json:"code"`
+	Outcome       string `json:"outcome"`
+	Error         string `json:"error,omitempty"`
+
+	// mu protects the fields which are set by handlers while the request is served.
+	mu sync.Mutex
+}
+
+// AuditFunc is called with the event once an audited request is done.
+type AuditFunc func(context.Context, *AuditEvent)
+
+// NewAuditLogWriter creates an AuditFunc which writes events to w as JSON lines.
+func NewAuditLogWriter(w io.Writer) AuditFunc {
+	var mu sync.Mutex
+	return func(ctx context.Context, e *AuditEvent) {
+		e.mu.Lock()
+		b, err := json.Marshal(e)
+		e.mu.Unlock()
+		if err != nil {
+			log.G(ctx).WithError(err).Error("Error marshalling audit event")
+			return
+		}
+		b = append(b, '\n')
+
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := w.Write(b); err != nil {
+			log.G(ctx).WithError(err).Error("Error writing audit event")
+		}
+	}
+}
+
+type auditEventKey struct{}
+
+// auditEventFrom returns the audit event of the request, or nil if the request is not audited.
+func auditEventFrom(ctx context.Context) *AuditEvent {
+	e, _ := ctx.Value(auditEventKey{}).(*AuditEvent)
+	return e
+}
+
+// recordError records the error which made the request fail.
+// Only the first error is kept, it is safe to call on a nil event.
+func (e *AuditEvent) recordError(err error) {
+	if e == nil || err == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Error == "" {
+		e.Error = err.Error()
+	}
+}
+
+// recordSession records the ID of the session recording, it is safe to call on a nil event.
+func (e *AuditEvent) recordSession(id string) {
+	if e == nil || id == "" {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.SessionID = id
+}
+
+// recordPortForward records a forwarded port or socket, it is safe to call on a nil event.
+func (e *AuditEvent) recordPortForward(target PortForwardTarget) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if target.Protocol == PortForwardProtocolTCP {
+		if !slices.Contains(e.Ports, target.Port) {
+			e.Ports = append(e.Ports, target.Port)
+		}
+		return
+	}
+	t := fmt.Sprintf("%s/%d", target.Protocol, target.Port)
+	if target.Path != "" {
+		t = string(target.Protocol) + ":" + target.Path
+	}
+	if !slices.Contains(e.Targets, t) {
+		e.Targets = append(e.Targets, t)
+	}
+}
+
+// withAudit wraps the handler to record an audit event for each request.
+// The handler is returned as is when f is nil.
+func withAudit(subresource string, f AuditFunc, h http.HandlerFunc) http.HandlerFunc {
+	if f == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := podPathParams(req)
+		e := &AuditEvent{
+			Time:        time.Now().UTC(),
+			Verb:        auditVerb(req.Method),
+			Subresource: subresource,
+			RequestURI:  req.RequestURI,
+			Namespace:   vars["namespace"],
+			Pod:         vars["pod"],
+			Container:   vars["container"],
+		}
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			e.SourceIP = host
+		}
+		if u, ok := request.UserFrom(req.Context()); ok {
+			e.User = u.GetName()
+			e.UID = u.GetUID()
+			e.Groups = u.GetGroups()
+		}
+		switch subresource {
+		case "exec":
+			e.Command = req.URL.Query()["command"]
+		case "run":
+			e.Command = runCommand(req)
+		}
+
+		aw := &auditResponseWriter{ResponseWriter: w}
+		body := &countingReader{ReadCloser: req.Body, n: &aw.received}
+		req.Body = body
+		req = req.WithContext(context.WithValue(req.Context(), auditEventKey{}, e))
+
+		defer func() {
+			e.mu.Lock()
+			e.DurationSeconds = time.Since(e.Time).Seconds()
+			e.BytesReceived = atomic.LoadInt64(&aw.received)
+			e.BytesSent = atomic.LoadInt64(&aw.sent)
+			e.Code = aw.code
+			if e.Code == 0 {
+				e.Code = http.StatusOK
+			}
+			e.Outcome = AuditOutcomeSuccess
+			if e.Code >= 400 || e.Error != "" {
+				e.Outcome = AuditOutcomeFailure
+			}
+			e.mu.Unlock()
+			f(req.Context(), e)
+		}()
+		h(aw, req)
+	}
+}
+
+func auditVerb(method string) string {
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodGet:
+		return "get"
+	}
+	return method
+}
+
+// auditResponseWriter counts the bytes sent and received, including on hijacked connections used for streaming.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	code     int
+	sent     int64
+	received int64
+}
+
+func (w *auditResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditResponseWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	atomic.AddInt64(&w.sent, int64(n))
+	return n, err
+}
+
+func (w *auditResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *auditResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	cc := &countingConn{Conn: conn, w: w}
+	// Data the server has already buffered must be read before the connection.
+	buffered, _ := rw.Reader.Peek(rw.Reader.Buffered())
+	atomic.AddInt64(&w.received, int64(len(buffered)))
+	r := io.MultiReader(bytes.NewReader(append([]byte(nil), buffered...)), cc)
+	return cc, bufio.NewReadWriter(bufio.NewReader(r), bufio.NewWriter(cc)), nil
+}
+
+func (w *auditResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// countingConn counts the bytes read from and written to a hijacked connection.
+type countingConn struct {
+	net.Conn
+	w *auditResponseWriter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(&c.w.received, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.w.sent, int64(n))
+	return n, err
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	h := PodHandler(PodHandlerConfig{
+		GetContainerLogs: func(_ context.Context, namespace, pod, container string, _ ContainerLogOpts) (io.ReadCloser, error) {
+			if pod != "web" {
+				return nil, errdefs.NotFoundf("pod %s/%s not found", namespace, pod)
+			}
+			return io.NopCloser(strings.NewReader("hello\n")), nil
+		},
+		RunInContainer: func(context.Context, string, string, string, []string, AttachIO) error {
+			return nil
+		},
+		Audit: NewAuditLogWriter(&buf),
+	}, false)
+	authenticated := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		u := &user.DefaultInfo{Name: "alice", UID: "1", Groups: []string{"devs"}}
+		h.ServeHTTP(w, req.WithContext(request.WithUser(req.Context(), u)))
+	})
+
+	for _, path := range []string{
+		"/containerLogs/default/web/app",
+		"/containerLogs/default/missing/app",
+		"/exec/default/web/app?command=ls&command=-l",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		authenticated.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var events []*AuditEvent
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		e := &AuditEvent{}
+		assert.NilError(t, dec.Decode(e))
+		events = append(events, e)
+	}
+	assert.Assert(t, is.Len(events, 3))
+
+	e := events[0]
+	assert.Check(t, is.Equal(e.Verb, "get"))
+	assert.Check(t, is.Equal(e.Subresource, "log"))
+	assert.Check(t, is.Equal(e.SourceIP, "10.0.0.1"))
+	assert.Check(t, is.Equal(e.User, "alice"))
+	assert.Check(t, is.DeepEqual(e.Groups, []string{"devs"}))
+	assert.Check(t, is.Equal(e.Namespace, "default"))
+	assert.Check(t, is.Equal(e.Pod, "web"))
+	assert.Check(t, is.Equal(e.Container, "app"))
+	assert.Check(t, is.Equal(e.BytesSent, int64(len("hello\n"))))
+	assert.Check(t, is.Equal(e.Code, http.StatusOK))
+	assert.Check(t, is.Equal(e.Outcome, AuditOutcomeSuccess))
+	assert.Check(t, is.Equal(e.Error, ""))
+
+	e = events[1]
+	assert.Check(t, is.Equal(e.Code, http.StatusNotFound))
+	assert.Check(t, is.Equal(e.Outcome, AuditOutcomeFailure))
+	assert.Check(t, is.Contains(e.Error, "not found"))
+
+	e = events[2]
+	assert.Check(t, is.Equal(e.Subresource, "exec"))
+	assert.Check(t, is.DeepEqual(e.Command, []string{"ls", "-l"}))
+	// The request is not upgraded to a stream.
+	assert.Check(t, is.Equal(e.Outcome, AuditOutcomeFailure))
+}
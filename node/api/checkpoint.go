@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+// ContainerCheckpointOpts are used to pass along options for checkpointing a container.
+type ContainerCheckpointOpts struct {
+	// Timeout is how long the checkpoint may take, 0 if the caller did not set a timeout.
+	// The context passed to the handler already has this deadline applied.
+	Timeout time.Duration
+}
+
+// ContainerCheckpoint is the result of checkpointing a container.
+// Exactly one of Location or Archive must be set, the request fails otherwise.
+type ContainerCheckpoint struct {
+	// Location is where the checkpoint archive was stored by the provider, it is returned to the client the same way
+	// the kubelet does.
+	Location string
+	// Archive is the checkpoint archive (a tar file) which is streamed to the client.
+	Archive io.ReadCloser
+}
+
+// ContainerCheckpointHandlerFunc is used in place of backend implementations for checkpointing a container.
+type ContainerCheckpointHandlerFunc func(ctx context.Context, namespace, podName, containerName string, opts ContainerCheckpointOpts) (*ContainerCheckpoint, error)
+
+// checkpointResponse is the response body when the checkpoint is stored by the provider, it matches the kubelet.
+type checkpointResponse struct {
+	Items []string `json:"items"`
+}
+
+// validateCheckpoint checks that the provider returned exactly one of the location or the archive of the checkpoint.
+func validateCheckpoint(checkpoint *ContainerCheckpoint) error {
+	switch {
+	case checkpoint == nil:
+		return errors.New("provider did not return a checkpoint")
+	case checkpoint.Archive != nil && checkpoint.Location != "":
+		checkpoint.Archive.Close() //nolint:errcheck
+		return errors.New("provider returned both a checkpoint location and archive")
+	case checkpoint.Archive == nil && checkpoint.Location == "":
+		return errors.New("provider returned a checkpoint without location or archive")
+	}
+	return nil
+}
+
+func parseCheckpointOptions(req *http.Request) (opts ContainerCheckpointOpts, err error) {
+	if timeout := req.URL.Query().Get("timeout"); timeout != "" {
+		seconds, err := strconv.ParseInt(timeout, 10, 64)
+		if err != nil {
+			return opts, errdefs.AsInvalidInput(errors.Wrap(err, "could not parse \"timeout\""))
+		}
+		if seconds < 0 {
+			return opts, errdefs.InvalidInputf("\"timeout\" is %d", seconds)
+		}
+		opts.Timeout = time.Duration(seconds) * time.Second
+	}
+	return opts, nil
+}
+
+// HandleContainerCheckpoint creates an http handler function from a provider to checkpoint a container.
+func HandleContainerCheckpoint(h ContainerCheckpointHandlerFunc) http.HandlerFunc {
+	if h == nil {
+		return NotImplemented
+	}
+	return handleError(func(w http.ResponseWriter, req *http.Request) error {
+		vars := podPathParams(req)
+		if len(vars) != 3 {
+			return errdefs.NotFound("not found")
+		}
+
+		opts, err := parseCheckpointOptions(req)
+		if err != nil {
+			return err
+		}
+
+		ctx := req.Context()
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+
+		checkpoint, err := h(ctx, vars["namespace"], vars["pod"], vars["container"], opts)
+		if err != nil {
+			return errors.Wrap(err, "checkpointing of container failed")
+		}
+		if err := validateCheckpoint(checkpoint); err != nil {
+			return err
+		}
+
+		if checkpoint.Archive == nil {
+			b, err := json.Marshal(checkpointResponse{Items: []string{checkpoint.Location}})
+			if err != nil {
+				return errors.Wrap(err, "error marshalling checkpoint response")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if _, err := w.Write(b); err != nil {
+				return errors.Wrap(err, "could not write to client")
+			}
+			return nil
+		}
+
+		defer checkpoint.Archive.Close()
+		w.Header().Set("Content-Type", "application/x-tar")
+		if _, err := io.Copy(flushOnWrite(w), checkpoint.Archive); err != nil {
+			return errors.Wrap(err, "error writing checkpoint to client")
+		}
+		return nil
+	})
+}
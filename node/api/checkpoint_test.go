@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestHandleContainerCheckpoint(t *testing.T) {
+	var (
+		gotOpts     ContainerCheckpointOpts
+		hasDeadline bool
+	)
+	h := PodHandler(PodHandlerConfig{
+		CheckpointContainer: func(ctx context.Context, namespace, pod, container string, opts ContainerCheckpointOpts) (*ContainerCheckpoint, error) {
+			gotOpts = opts
+			_, hasDeadline = ctx.Deadline()
+			return &ContainerCheckpoint{Location: "/checkpoints/" + namespace + "-" + pod + "-" + container + ".tar"}, nil
+		},
+	}, false)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkpoint/default/foo/bar?timeout=5", nil))
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Body.String(), `{"items":["/checkpoints/default-foo-bar.tar"]}`))
+	assert.Check(t, is.Equal(gotOpts.Timeout, 5*time.Second))
+	assert.Check(t, hasDeadline)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkpoint/default/foo/bar", nil))
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(gotOpts.Timeout, time.Duration(0)))
+	assert.Check(t, !hasDeadline)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkpoint/default/foo/bar?timeout=-1", nil))
+	assert.Check(t, is.Equal(w.Code, http.StatusBadRequest))
+
+	w = httptest.NewRecorder()
+	PodHandler(PodHandlerConfig{}, false).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkpoint/default/foo/bar", nil))
+	assert.Check(t, is.Equal(w.Code, http.StatusNotImplemented))
+}
+
+func TestHandleContainerCheckpointInvalid(t *testing.T) {
+	for name, checkpoint := range map[string]*ContainerCheckpoint{
+		"nil":   nil,
+		"empty": {},
+		"both":  {Location: "/checkpoints/foo.tar", Archive: io.NopCloser(strings.NewReader("tar"))},
+	} {
+		h := PodHandler(PodHandlerConfig{
+			CheckpointContainer: func(context.Context, string, string, string, ContainerCheckpointOpts) (*ContainerCheckpoint, error) {
+				return checkpoint, nil
+			},
+		}, false)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkpoint/default/foo/bar", nil))
+		assert.Check(t, is.Equal(w.Code, http.StatusInternalServerError), name)
+		assert.Check(t, !strings.Contains(w.Body.String(), `"items"`), name)
+	}
+}
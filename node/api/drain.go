@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+// errShuttingDown ends the streaming sessions which are drained, exec and attach clients get it on the remotecommand
+// error stream and port-forward clients on the error stream of the forwarded ports.
+var errShuttingDown = errdefs.Unavailable("node is shutting down")
+
+// StreamSessions tracks the streaming sessions served by the pod handlers, exec, attach, port-forward and followed
+// container logs, so they can be drained when the server shuts down, see Drain.
+//
+// Streaming sessions use hijacked connections, which http.Server.Shutdown neither waits for nor closes.
+type StreamSessions struct {
+	mu       sync.Mutex
+	sessions map[*streamSession]struct{}
+	draining bool
+	// idle is closed when the last session ends while draining.
+	idle chan struct{}
+}
+
+// NewStreamSessions creates a StreamSessions to pass to the pod handlers, see PodHandlerConfig.
+func NewStreamSessions() *StreamSessions {
+	return &StreamSessions{
+		sessions: make(map[*streamSession]struct{}),
+		idle:     make(chan struct{}),
+	}
+}
+
+type streamSession struct {
+	// ctx is cancelled with errShuttingDown when the session is drained.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+type streamSessionKey struct{}
+
+func streamSessionFrom(ctx context.Context) *streamSession {
+	s, _ := ctx.Value(streamSessionKey{}).(*streamSession)
+	return s
+}
+
+// withDrain returns a context derived from parent which is cancelled with errShuttingDown when the session is drained.
+func (s *streamSession) withDrain(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	if s == nil {
+		return ctx, func() { cancel(nil) }
+	}
+	stop := context.AfterFunc(s.ctx, func() {
+		cancel(context.Cause(s.ctx))
+	})
+	return ctx, func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// isShuttingDown returns true if ctx was cancelled because its session was drained.
+func isShuttingDown(ctx context.Context) bool {
+	return context.Cause(ctx) == errShuttingDown //nolint:errorlint
+}
+
+// close closes the hijacked connection of the session, if any.
+func (s *streamSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close() //nolint:errcheck
+	}
+}
+
+// track wraps the handler of the endpoint class to track its streaming sessions.
+// Requests which are not streams, and all requests when s is nil, are passed through as is.
+func (s *StreamSessions) track(class string, h http.HandlerFunc) http.HandlerFunc {
+	if s == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !isStreamRequest(class, req) {
+			h(w, req)
+			return
+		}
+
+		sess, ok := s.add()
+		if !ok {
+			handleError(func(http.ResponseWriter, *http.Request) error { return errShuttingDown })(w, req)
+			return
+		}
+		defer s.remove(sess)
+		defer sess.cancel(nil)
+
+		req = req.WithContext(context.WithValue(req.Context(), streamSessionKey{}, sess))
+		h(&sessionResponseWriter{ResponseWriter: w, s: sess}, req)
+	}
+}
+
+func (s *StreamSessions) add() (*streamSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return nil, false
+	}
+	sess := &streamSession{}
+	sess.ctx, sess.cancel = context.WithCancelCause(context.Background())
+	s.sessions[sess] = struct{}{}
+	return sess, true
+}
+
+func (s *StreamSessions) remove(sess *streamSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sess)
+	if s.draining && len(s.sessions) == 0 {
+		select {
+		case <-s.idle:
+		default:
+			close(s.idle)
+		}
+	}
+}
+
+// Active returns the number of active streaming sessions.
+func (s *StreamSessions) Active() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Drain stops accepting new streaming sessions and notifies the active ones that the node is shutting down: the
+// contexts passed to the provider are cancelled, and exec, attach and port-forward clients get an error on the error
+// stream when the provider returns.
+// It then waits for the sessions to end until ctx is done, and closes the connections of the remaining ones.
+//
+// It returns the number of sessions which were interrupted, and how many of them had to be closed.
+func (s *StreamSessions) Drain(ctx context.Context) (interrupted, closed int) {
+	s.mu.Lock()
+	s.draining = true
+	interrupted = len(s.sessions)
+	if interrupted == 0 {
+		s.mu.Unlock()
+		return 0, 0
+	}
+	for sess := range s.sessions {
+		sess.cancel(errShuttingDown)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.idle:
+		return interrupted, 0
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sess := range s.sessions {
+		sess.close()
+	}
+	return interrupted, len(s.sessions)
+}
+
+// sessionResponseWriter keeps track of the connection hijacked by a streaming session, so it can be closed.
+type sessionResponseWriter struct {
+	http.ResponseWriter
+	s *streamSession
+}
+
+func (w *sessionResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *sessionResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.s.mu.Lock()
+	w.s.conn = conn
+	w.s.mu.Unlock()
+	return conn, rw, nil
+}
+
+func (w *sessionResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
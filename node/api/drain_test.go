@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+func TestStreamSessionsDrain(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	defer close(release)
+	newServer := func(sessions *StreamSessions) *httptest.Server {
+		return httptest.NewServer(PodHandler(PodHandlerConfig{
+			RunInContainer: func(ctx context.Context, _, _, _ string, cmd []string, attach AttachIO) error {
+				started <- struct{}{}
+				if cmd[0] == "stuck" {
+					<-release
+					return nil
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			GetContainerLogs: func(ctx context.Context, _, _, _ string, _ ContainerLogOpts) (io.ReadCloser, error) {
+				r, w := io.Pipe()
+				go func() {
+					w.Write([]byte("hello\n")) //nolint:errcheck
+					<-ctx.Done()
+					w.Close()
+				}()
+				started <- struct{}{}
+				return r, nil
+			},
+			Sessions: sessions,
+		}, false))
+	}
+	exec := func(srv *httptest.Server, command string) <-chan error {
+		u, err := url.Parse(srv.URL + "/exec/default/web/app?output=1&command=" + command)
+		assert.NilError(t, err)
+		e, err := remotecommand.NewSPDYExecutor(&restclient.Config{Host: srv.URL}, "POST", u)
+		assert.NilError(t, err)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- e.StreamWithContext(context.Background(), remotecommand.StreamOptions{Stdout: io.Discard})
+		}()
+		<-started
+		return errs
+	}
+
+	// Sessions are notified and end by themselves.
+	sessions := NewStreamSessions()
+	srv := newServer(sessions)
+	defer srv.Close()
+
+	execErr := exec(srv, "wait")
+	// Follow is parsed like the logs handler does, any true value is a stream.
+	resp, err := http.Get(srv.URL + "/containerLogs/default/web/app?follow=1")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	<-started
+	assert.Check(t, is.Equal(sessions.Active(), 2))
+
+	interrupted, closed := sessions.Drain(context.Background())
+	assert.Check(t, is.Equal(interrupted, 2))
+	assert.Check(t, is.Equal(closed, 0))
+	assert.Check(t, is.ErrorContains(<-execErr, "node is shutting down"))
+	b, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(b), "hello\n"))
+
+	// New sessions are rejected once draining, other requests are not affected.
+	resp, err = http.Get(srv.URL + "/containerLogs/default/web/app?follow=t")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, is.Equal(resp.StatusCode, http.StatusServiceUnavailable))
+	resp, err = http.Get(srv.URL + "/containerLogs/default/web/app")
+	assert.NilError(t, err)
+	<-started
+	resp.Body.Close()
+	assert.Check(t, is.Equal(resp.StatusCode, http.StatusOK))
+
+	// Sessions which don't end in time are closed.
+	sessions = NewStreamSessions()
+	srv = newServer(sessions)
+	defer srv.Close()
+
+	execErr = exec(srv, "stuck")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	interrupted, closed = sessions.Drain(ctx)
+	assert.Check(t, is.Equal(interrupted, 1))
+	assert.Check(t, is.Equal(closed, 1))
+	// The client sees the end of the streams, it is not told why.
+	<-execErr
+}
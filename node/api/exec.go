@@ -21,7 +21,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/internal/kubernetes/remotecommand"
@@ -55,6 +54,8 @@ type ContainerExecHandlerConfig struct {
 	StreamIdleTimeout time.Duration
 	// StreamCreationTimeout is the maximum time for streaming connection
 	StreamCreationTimeout time.Duration
+	// SessionRecording is where sessions are recorded, sessions are not recorded when it is not set.
+	SessionRecording SessionRecordingSink
 }
 
 // ContainerExecHandlerOption configures a ContainerExecHandlerConfig
@@ -76,8 +77,7 @@ func WithExecStreamCreationTimeout(dur time.Duration) ContainerExecHandlerOption
 }
 
 // HandleContainerExec makes an http handler func from a Provider which execs a command in a pod's container
-// Note that this handler currently depends on gorrilla/mux to get url parts as variables.
-// TODO(@cpuguy83): don't force gorilla/mux on consumers of this function
+// The namespace, pod and container are read from the path parameters of the request, see PathParam.
 func HandleContainerExec(h ContainerExecHandlerFunc, opts ...ContainerExecHandlerOption) http.HandlerFunc {
 	if h == nil {
 		return NotImplemented
@@ -96,7 +96,7 @@ func HandleContainerExec(h ContainerExecHandlerFunc, opts ...ContainerExecHandle
 	}
 
 	return handleError(func(w http.ResponseWriter, req *http.Request) error {
-		vars := mux.Vars(req)
+		vars := podPathParams(req)
 
 		namespace := vars["namespace"]
 		pod := vars["pod"]
@@ -113,10 +113,10 @@ func HandleContainerExec(h ContainerExecHandlerFunc, opts ...ContainerExecHandle
 		}
 
 		// TODO: Why aren't we using req.Context() here?
-		ctx, cancel := context.WithCancel(context.TODO())
+		ctx, cancel := streamSessionFrom(req.Context()).withDrain(context.TODO())
 		defer cancel()
 
-		exec := &containerExecContext{ctx: ctx, h: h, pod: pod, namespace: namespace, container: container}
+		exec := &containerExecContext{ctx: ctx, h: h, pod: pod, namespace: namespace, container: container, audit: auditEventFrom(req.Context()), record: cfg.SessionRecording}
 		remotecommand.ServeExec(
 			w,
 			req,
@@ -167,6 +167,8 @@ type containerExecContext struct {
 	h                         ContainerExecHandlerFunc
 	namespace, pod, container string
 	ctx                       context.Context
+	audit                     *AuditEvent
+	record                    SessionRecordingSink
 }
 
 // ExecInContainer Implements remotecommand.Executor
@@ -187,11 +189,27 @@ func (c *containerExecContext) ExecInContainer(name string, uid types.UID, conta
 	ctx, cancel := context.WithCancel(c.ctx)
 	defer cancel()
 
+	rec, recErr := startSessionRecording(ctx, c.record, SessionInfo{
+		Subresource: "exec",
+		Namespace:   c.namespace,
+		Pod:         c.pod,
+		Container:   c.container,
+		Command:     cmd,
+	}, eio)
+	if recErr != nil {
+		c.audit.recordError(recErr)
+		return recErr
+	}
+	defer rec.Close(ctx)
+	c.audit.recordSession(rec.ID())
+
 	if tty {
 		go func() {
 			send := func(s remoteutils.TerminalSize) bool {
+				size := TermSize{Width: s.Width, Height: s.Height}
+				rec.resize(size)
 				select {
-				case eio.chResize <- TermSize{Width: s.Width, Height: s.Height}:
+				case eio.chResize <- size:
 					return false
 				case <-ctx.Done():
 					return true
@@ -211,7 +229,12 @@ func (c *containerExecContext) ExecInContainer(name string, uid types.UID, conta
 		}()
 	}
 
-	return c.h(c.ctx, c.namespace, c.pod, c.container, cmd, eio)
+	execErr := c.h(c.ctx, c.namespace, c.pod, c.container, cmd, eio)
+	if isShuttingDown(c.ctx) {
+		execErr = errShuttingDown
+	}
+	c.audit.recordError(execErr)
+	return execErr
 }
 
 type execIO struct {
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+func TestStdinCloseOverWebSocket(t *testing.T) {
+	// The handlers only return once stdin is closed, which WebSocket clients signal with the v5 protocol.
+	readStdin := func(attach AttachIO) error {
+		b, err := io.ReadAll(attach.Stdin())
+		if err != nil {
+			return err
+		}
+		_, err = attach.Stdout().Write(bytes.ToUpper(b))
+		return err
+	}
+	srv := httptest.NewServer(PodHandler(PodHandlerConfig{
+		RunInContainer: func(_ context.Context, _, _, _ string, _ []string, attach AttachIO) error {
+			return readStdin(attach)
+		},
+		AttachToContainer: func(_ context.Context, _, _, _ string, attach AttachIO) error {
+			return readStdin(attach)
+		},
+	}, false))
+	defer srv.Close()
+
+	for _, path := range []string{"/exec/default/web/app?command=cat&input=1&output=1", "/attach/default/web/app?input=1&output=1"} {
+		t.Run(strings.SplitN(path, "/", 3)[1], func(t *testing.T) {
+			exec, err := remotecommand.NewWebSocketExecutorForProtocols(&restclient.Config{Host: srv.URL}, "GET", srv.URL+path, "v5.channel.k8s.io")
+			assert.NilError(t, err)
+
+			var stdout bytes.Buffer
+			err = exec.StreamWithContext(context.Background(), remotecommand.StreamOptions{
+				Stdin:  strings.NewReader("hello\n"),
+				Stdout: &stdout,
+			})
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(stdout.String(), "HELLO\n"))
+		})
+	}
+}
@@ -15,13 +15,22 @@
 package api
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// statusReasonNotImplemented is used for 501 responses, which have no reason defined by Kubernetes.
+const statusReasonNotImplemented metav1.StatusReason = "NotImplemented"
+
 type handlerFunc func(http.ResponseWriter, *http.Request) error
 
 func handleError(f handlerFunc) http.HandlerFunc {
@@ -31,11 +40,12 @@ func handleError(f handlerFunc) http.HandlerFunc {
 			return
 		}
 
-		code := httpStatusCode(err)
-		w.WriteHeader(code)
-		if _, err := io.WriteString(w, err.Error()); err != nil {
-			log.G(req.Context()).WithError(err).Error("error writing error response")
-		}
+		auditEventFrom(req.Context()).recordError(err)
+
+		status := errorStatus(err, podPathParams(req))
+		writeStatus(w, req, status)
+
+		code := int(status.Code)
 		logger := log.G(req.Context()).WithError(err).WithField("httpStatusCode", code)
 
 		if code >= 500 {
@@ -78,7 +88,106 @@ func httpStatusCode(err error) int {
 		return http.StatusNotFound
 	case errdefs.IsInvalidInput(err):
 		return http.StatusBadRequest
+	case errdefs.IsUnauthorized(err):
+		return http.StatusUnauthorized
+	case errdefs.IsForbidden(err):
+		return http.StatusForbidden
+	case errdefs.IsConflict(err):
+		return http.StatusConflict
+	case errdefs.IsTooManyRequests(err):
+		return http.StatusTooManyRequests
+	case errdefs.IsNotImplemented(err):
+		return http.StatusNotImplemented
+	case errdefs.IsUnavailable(err):
+		return http.StatusServiceUnavailable
 	default:
 		return http.StatusInternalServerError
 	}
 }
+
+func statusReason(code int) metav1.StatusReason {
+	switch code {
+	case http.StatusBadRequest:
+		return metav1.StatusReasonBadRequest
+	case http.StatusUnauthorized:
+		return metav1.StatusReasonUnauthorized
+	case http.StatusForbidden:
+		return metav1.StatusReasonForbidden
+	case http.StatusNotFound:
+		return metav1.StatusReasonNotFound
+	case http.StatusMethodNotAllowed:
+		return metav1.StatusReasonMethodNotAllowed
+	case http.StatusConflict:
+		return metav1.StatusReasonConflict
+	case http.StatusTooManyRequests:
+		return metav1.StatusReasonTooManyRequests
+	case http.StatusNotImplemented:
+		return statusReasonNotImplemented
+	case http.StatusServiceUnavailable:
+		return metav1.StatusReasonServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return metav1.StatusReasonTimeout
+	case http.StatusInternalServerError:
+		return metav1.StatusReasonInternalError
+	default:
+		return metav1.StatusReasonUnknown
+	}
+}
+
+// newStatus creates a failure status with the given code and message.
+func newStatus(code int, msg string) *metav1.Status {
+	return &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Message:  msg,
+		Reason:   statusReason(code),
+		Code:     int32(code),
+	}
+}
+
+// errorStatus converts an error returned by a handler into a Kubernetes API status.
+// Errors which already carry a status, such as errors from the Kubernetes client, keep their status.
+func errorStatus(err error, vars map[string]string) *metav1.Status {
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) && apiStatus.Status().Code != 0 {
+		status := apiStatus.Status()
+		status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+		status.Message = err.Error()
+		return &status
+	}
+
+	status := newStatus(httpStatusCode(err), err.Error())
+	var details metav1.StatusDetails
+	if pod, ok := vars["pod"]; ok {
+		details.Kind = "pods"
+		details.Name = pod
+	}
+	if retryAfter := errdefs.RetryAfter(err); retryAfter > 0 {
+		// Round up, a client retrying too early would only be rejected again.
+		details.RetryAfterSeconds = int32((retryAfter + time.Second - 1) / time.Second)
+	}
+	if details.Name != "" || details.RetryAfterSeconds > 0 {
+		status.Details = &details
+	}
+	return status
+}
+
+// WriteError writes err as a Kubernetes API status, with the HTTP status code of its errdefs type.
+// It is meant for handlers wrapping the ones from this package, such as authentication, so all failures are reported
+// the same way.
+func WriteError(w http.ResponseWriter, req *http.Request, err error) {
+	writeStatus(w, req, errorStatus(err, nil))
+}
+
+// writeStatus writes the status as the response, setting the Retry-After header if the status requests it.
+func writeStatus(w http.ResponseWriter, req *http.Request, status *metav1.Status) {
+	if status.Details != nil && status.Details.RetryAfterSeconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(status.Details.RetryAfterSeconds)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(int(status.Code))
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.G(req.Context()).WithError(err).Error("error writing error response")
+	}
+}
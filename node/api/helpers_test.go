@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestHandleErrorStatus(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		code       int
+		reason     metav1.StatusReason
+		retryAfter string
+	}{
+		{name: "not found", err: errdefs.NotFound("not found"), code: http.StatusNotFound, reason: metav1.StatusReasonNotFound},
+		{name: "invalid input", err: errdefs.InvalidInput("bad"), code: http.StatusBadRequest, reason: metav1.StatusReasonBadRequest},
+		{name: "unauthorized", err: errdefs.Unauthorized("who"), code: http.StatusUnauthorized, reason: metav1.StatusReasonUnauthorized},
+		{name: "forbidden", err: errdefs.Forbidden("no"), code: http.StatusForbidden, reason: metav1.StatusReasonForbidden},
+		{name: "conflict", err: errdefs.Conflict("busy"), code: http.StatusConflict, reason: metav1.StatusReasonConflict},
+		{
+			name:       "too many requests",
+			err:        errors.Wrap(errdefs.TooManyRequests("slow down", 1500*time.Millisecond), "error"),
+			code:       http.StatusTooManyRequests,
+			reason:     metav1.StatusReasonTooManyRequests,
+			retryAfter: "2",
+		},
+		{name: "not implemented", err: errdefs.NotImplemented("nope"), code: http.StatusNotImplemented, reason: statusReasonNotImplemented},
+		{name: "unavailable", err: errdefs.Unavailable("down"), code: http.StatusServiceUnavailable, reason: metav1.StatusReasonServiceUnavailable},
+		{name: "internal", err: errors.New("oops"), code: http.StatusInternalServerError, reason: metav1.StatusReasonInternalError},
+		{
+			name:   "api status",
+			err:    errors.Wrap(apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "foo"), "error"),
+			code:   http.StatusNotFound,
+			reason: metav1.StatusReasonNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := mux.NewRouter()
+			r.HandleFunc("/containerLogs/{namespace}/{pod}/{container}", handleError(func(http.ResponseWriter, *http.Request) error {
+				return tc.err
+			}))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/containerLogs/default/foo/bar", nil))
+
+			assert.Check(t, is.Equal(w.Code, tc.code))
+			assert.Check(t, is.Equal(w.Header().Get("Content-Type"), "application/json"))
+			assert.Check(t, is.Equal(w.Header().Get("Retry-After"), tc.retryAfter))
+
+			var status metav1.Status
+			assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &status))
+			assert.Check(t, is.Equal(status.Kind, "Status"))
+			assert.Check(t, is.Equal(status.Status, metav1.StatusFailure))
+			assert.Check(t, is.Equal(status.Code, int32(tc.code)))
+			assert.Check(t, is.Equal(status.Reason, tc.reason))
+			assert.Check(t, is.Contains(status.Message, tc.err.Error()))
+			assert.Assert(t, status.Details != nil)
+			assert.Check(t, is.Equal(status.Details.Name, "foo"))
+		})
+	}
+}
+
+func TestNotFoundStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	NotFound(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	assert.Check(t, is.Equal(w.Code, http.StatusNotFound))
+
+	var status metav1.Status
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Check(t, is.Equal(status.Reason, metav1.StatusReasonNotFound))
+	assert.Check(t, is.Nil(status.Details))
+}
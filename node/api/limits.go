@@ -0,0 +1,252 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/internal/metrics"
+	"golang.org/x/time/rate"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Endpoint classes of the kubelet API, used to configure request rates.
+const (
+	// EndpointClassStreaming covers exec, attach, port-forward and run.
+	EndpointClassStreaming = "streaming"
+	EndpointClassLogs      = "logs"
+	// EndpointClassStats covers /stats/summary and /metrics/resource.
+	EndpointClassStats      = "stats"
+	EndpointClassPods       = "pods"
+	EndpointClassCheckpoint = "checkpoint"
+)
+
+// Limits reported in the limited requests metric.
+const (
+	limitRate        = "rate"
+	limitStreams     = "streams"
+	limitUserStreams = "user_streams"
+	limitPodStreams  = "pod_streams"
+)
+
+const (
+	// streamRetryAfter is the Retry-After of requests over stream limits, there's no telling when a stream ends.
+	streamRetryAfter = time.Second
+	// maxTrackedRateLimiters is the number of per user rate limiters above which idle ones are forgotten.
+	maxTrackedRateLimiters = 1024
+)
+
+var limitedRequestsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "api",
+	Name:      "limited_requests_total",
+	Help:      "Number of kubelet API requests rejected because of request limits, by endpoint class and limit.",
+}, []string{"class", "limit"})
+
+func init() {
+	metrics.Registry.MustRegister(limitedRequestsMetric)
+}
+
+// RateLimit is the rate of requests allowed, with bursts of up to Burst requests.
+// A QPS of zero or less means no limit.
+type RateLimit struct {
+	QPS   float64
+	Burst int
+}
+
+// RequestLimits configures limits on the requests to the kubelet API.
+// Requests over a limit are rejected with a 429 status. Zero values mean no limit.
+//
+// Streams are exec, attach and port-forward requests, as well as container logs requests which follow the logs.
+// Users are identified by the user authenticated by the handler, see nodeutil.WithAuth, requests without user share
+// the same limits.
+type RequestLimits struct {
+	// MaxStreams is the maximum number of concurrent streams served by the node.
+	MaxStreams int
+	// MaxStreamsPerUser is the maximum number of concurrent streams for a user.
+	MaxStreamsPerUser int
+	// MaxStreamsPerPod is the maximum number of concurrent streams to the containers of a pod.
+	MaxStreamsPerPod int
+	// RequestRates are the rates of requests allowed per user, by endpoint class.
+	RequestRates map[string]RateLimit
+}
+
+type rateLimiterKey struct {
+	class, user string
+}
+
+// requestLimiter enforces RequestLimits.
+type requestLimiter struct {
+	limits RequestLimits
+
+	mu          sync.Mutex
+	streams     int
+	userStreams map[string]int
+	podStreams  map[string]int
+	rates       map[rateLimiterKey]*rate.Limiter
+}
+
+// newRequestLimiter creates a limiter, it returns nil when there are no limits.
+func newRequestLimiter(limits RequestLimits) *requestLimiter {
+	if limits.MaxStreams <= 0 && limits.MaxStreamsPerUser <= 0 && limits.MaxStreamsPerPod <= 0 && len(limits.RequestRates) == 0 {
+		return nil
+	}
+	return &requestLimiter{
+		limits:      limits,
+		userStreams: make(map[string]int),
+		podStreams:  make(map[string]int),
+		rates:       make(map[rateLimiterKey]*rate.Limiter),
+	}
+}
+
+// wrap applies the limits to the handler, it is returned as is when the limiter is nil.
+func (l *requestLimiter) wrap(class string, h http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		userName := user.Anonymous
+		if u, ok := request.UserFrom(req.Context()); ok {
+			userName = u.GetName()
+		}
+
+		if err := l.allow(class, userName); err != nil {
+			handleError(func(http.ResponseWriter, *http.Request) error { return err })(w, req)
+			return
+		}
+
+		if isStreamRequest(class, req) {
+			// Pod watches are not for a single pod, they only count against the global and per user limits.
+			var pod string
+			if vars := podPathParams(req); vars["pod"] != "" {
+				pod = vars["namespace"] + "/" + vars["pod"]
+			}
+			release, err := l.acquireStream(class, userName, pod)
+			if err != nil {
+				handleError(func(http.ResponseWriter, *http.Request) error { return err })(w, req)
+				return
+			}
+			defer release()
+		}
+
+		h(w, req)
+	}
+}
+
+// isStreamRequest returns whether the request is a long lived stream: exec, attach, port-forward and run requests,
+// followed container logs and pod watches.
+// Invalid follow and watch parameters are not streams, the handlers reject them.
+func isStreamRequest(class string, req *http.Request) bool {
+	switch class {
+	case EndpointClassStreaming:
+		return true
+	case EndpointClassLogs:
+		follow, _ := parseFollow(req.URL.Query())
+		return follow
+	case EndpointClassPods:
+		return isWatchRequest(req.URL.Query())
+	}
+	return false
+}
+
+// allow checks the request rate of the user for the class.
+func (l *requestLimiter) allow(class, userName string) error {
+	rl, ok := l.limits.RequestRates[class]
+	if !ok || rl.QPS <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	key := rateLimiterKey{class: class, user: userName}
+	lim, ok := l.rates[key]
+	if !ok {
+		if len(l.rates) >= maxTrackedRateLimiters {
+			l.forgetIdleRateLimiters()
+		}
+		burst := rl.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		lim = rate.NewLimiter(rate.Limit(rl.QPS), burst)
+		l.rates[key] = lim
+	}
+	l.mu.Unlock()
+
+	r := lim.Reserve()
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		limitedRequestsMetric.WithLabelValues(class, limitRate).Inc()
+		return errdefs.TooManyRequestsf(delay, "too many %s requests from user %q", class, userName)
+	}
+	return nil
+}
+
+// forgetIdleRateLimiters removes the rate limiters which are full, keeping them would not change the outcome of the
+// next request.
+// The caller must hold l.mu.
+func (l *requestLimiter) forgetIdleRateLimiters() {
+	now := time.Now()
+	for k, lim := range l.rates {
+		if lim.TokensAt(now) >= float64(lim.Burst()) {
+			delete(l.rates, k)
+		}
+	}
+}
+
+// acquireStream counts a new stream against the stream limits, the returned function must be called when the stream
+// ends.
+func (l *requestLimiter) acquireStream(class, userName, pod string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var limit string
+	switch {
+	case l.limits.MaxStreams > 0 && l.streams >= l.limits.MaxStreams:
+		limit = limitStreams
+	case l.limits.MaxStreamsPerUser > 0 && l.userStreams[userName] >= l.limits.MaxStreamsPerUser:
+		limit = limitUserStreams
+	case pod != "" && l.limits.MaxStreamsPerPod > 0 && l.podStreams[pod] >= l.limits.MaxStreamsPerPod:
+		limit = limitPodStreams
+	}
+	if limit != "" {
+		limitedRequestsMetric.WithLabelValues(class, limit).Inc()
+		switch limit {
+		case limitUserStreams:
+			return nil, errdefs.TooManyRequestsf(streamRetryAfter, "too many concurrent streams for user %q", userName)
+		case limitPodStreams:
+			return nil, errdefs.TooManyRequestsf(streamRetryAfter, "too many concurrent streams for pod %s", pod)
+		default:
+			return nil, errdefs.TooManyRequests("too many concurrent streams", streamRetryAfter)
+		}
+	}
+
+	l.streams++
+	l.userStreams[userName]++
+	if pod != "" {
+		l.podStreams[pod]++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.streams--
+			decrementOrDelete(l.userStreams, userName)
+			if pod != "" {
+				decrementOrDelete(l.podStreams, pod)
+			}
+		})
+	}, nil
+}
+
+func decrementOrDelete(m map[string]int, k string) {
+	if m[k] <= 1 {
+		delete(m, k)
+		return
+	}
+	m[k]--
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestRequestLimits(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := PodHandler(PodHandlerConfig{
+		GetContainerLogs: func(_ context.Context, _, _, _ string, opts ContainerLogOpts) (io.ReadCloser, error) {
+			if !opts.Follow {
+				return io.NopCloser(strings.NewReader("hello\n")), nil
+			}
+			started <- struct{}{}
+			<-release
+			return io.NopCloser(strings.NewReader("bye\n")), nil
+		},
+		GetPods: func(context.Context) ([]*v1.Pod, error) {
+			return nil, nil
+		},
+		Limits: RequestLimits{
+			MaxStreams:        3,
+			MaxStreamsPerUser: 2,
+			MaxStreamsPerPod:  1,
+			RequestRates: map[string]RateLimit{
+				EndpointClassPods: {QPS: 0.1, Burst: 2},
+			},
+		},
+	}, true)
+
+	do := func(userName, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if userName != "" {
+			req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: userName}))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	done := make(chan int, 3)
+	follow := func(userName, pod string) {
+		go func() {
+			done <- do(userName, "/containerLogs/default/"+pod+"/app?follow=true").Code
+		}()
+		<-started
+	}
+	metric := func(class, limit string) float64 {
+		return testutil.ToFloat64(limitedRequestsMetric.WithLabelValues(class, limit))
+	}
+	podStreams, userStreams, streams := metric(EndpointClassLogs, limitPodStreams), metric(EndpointClassLogs, limitUserStreams), metric(EndpointClassLogs, limitStreams)
+
+	follow("alice", "web-1")
+
+	w := do("bob", "/containerLogs/default/web-1/app?follow=true")
+	assert.Check(t, is.Equal(w.Code, http.StatusTooManyRequests))
+	assert.Check(t, is.Equal(w.Header().Get("Retry-After"), "1"))
+	assert.Check(t, is.Equal(metric(EndpointClassLogs, limitPodStreams), podStreams+1))
+
+	// Logs which are not followed are not streams.
+	assert.Check(t, is.Equal(do("bob", "/containerLogs/default/web-1/app").Code, http.StatusOK))
+
+	follow("alice", "web-2")
+	assert.Check(t, is.Equal(do("alice", "/containerLogs/default/web-3/app?follow=true").Code, http.StatusTooManyRequests))
+	assert.Check(t, is.Equal(metric(EndpointClassLogs, limitUserStreams), userStreams+1))
+
+	follow("", "web-3")
+	assert.Check(t, is.Equal(do("bob", "/containerLogs/default/web-4/app?follow=true").Code, http.StatusTooManyRequests))
+	assert.Check(t, is.Equal(metric(EndpointClassLogs, limitStreams), streams+1))
+
+	// Streams are released when they end.
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.Check(t, is.Equal(<-done, http.StatusOK))
+	}
+	go func() {
+		<-started
+	}()
+	assert.Check(t, is.Equal(do("bob", "/containerLogs/default/web-1/app?follow=true").Code, http.StatusOK))
+
+	// Request rates are per user.
+	rates := metric(EndpointClassPods, limitRate)
+	assert.Check(t, is.Equal(do("alice", "/runningpods/").Code, http.StatusOK))
+	assert.Check(t, is.Equal(do("alice", "/runningpods/").Code, http.StatusOK))
+	w = do("alice", "/runningpods/")
+	assert.Check(t, is.Equal(w.Code, http.StatusTooManyRequests))
+	assert.Check(t, w.Header().Get("Retry-After") != "")
+	assert.Check(t, is.Equal(metric(EndpointClassPods, limitRate), rates+1))
+	assert.Check(t, is.Equal(do("bob", "/runningpods/").Code, http.StatusOK))
+}
+
+func TestRequestLimitsZeroQPS(t *testing.T) {
+	h := PodHandler(PodHandlerConfig{
+		GetPods: func(context.Context) ([]*v1.Pod, error) {
+			return nil, nil
+		},
+		Limits: RequestLimits{
+			RequestRates: map[string]RateLimit{
+				EndpointClassPods: {QPS: 0, Burst: 1},
+			},
+		},
+	}, true)
+
+	// A zero rate does not limit requests, rather than allowing the burst only.
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/runningpods/", nil))
+		assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	}
+}
+
+func TestRequestLimitsStreamParameters(t *testing.T) {
+	started := make(chan struct{})
+	h := PodHandler(PodHandlerConfig{
+		GetContainerLogs: func(ctx context.Context, _, _, _ string, opts ContainerLogOpts) (io.ReadCloser, error) {
+			started <- struct{}{}
+			<-ctx.Done()
+			return io.NopCloser(strings.NewReader("")), nil
+		},
+		GetPodsFromKubernetes: func(context.Context) ([]*v1.Pod, error) {
+			return nil, nil
+		},
+		WatchPodsFromKubernetes: func(context.Context) (watch.Interface, error) {
+			started <- struct{}{}
+			return watch.NewFake(), nil
+		},
+		Limits: RequestLimits{MaxStreamsPerUser: 1},
+	}, true)
+
+	do := func(ctx context.Context, userName, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(request.WithUser(ctx, &user.DefaultInfo{Name: userName}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	// start starts a stream for the user, which runs until the returned func is called.
+	start := func(userName, path string) func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			do(ctx, userName, path)
+		}()
+		<-started
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+
+	// Follow is parsed like the logs handler does, any true value follows the logs.
+	stop := start("alice", "/containerLogs/default/web-1/app?follow=1")
+	assert.Check(t, is.Equal(do(context.Background(), "alice", "/containerLogs/default/web-2/app?follow=True"), http.StatusTooManyRequests))
+	stop()
+
+	// Pod watches are streams too, they are not counted against a pod.
+	stop = start("bob", "/pods?watch=1")
+	assert.Check(t, is.Equal(do(context.Background(), "bob", "/pods?watch=true"), http.StatusTooManyRequests))
+	assert.Check(t, is.Equal(do(context.Background(), "bob", "/pods"), http.StatusOK))
+	stop()
+	stop = start("bob", "/pods?watch=true")
+	stop()
+}
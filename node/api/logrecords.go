@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+// ErrLogHistoryEnd is returned once by ContainerLogRecordReader.Next when following logs, after all records which
+// existed at the time the logs were requested have been returned.
+var ErrLogHistoryEnd = errors.New("end of log history")
+
+// ContainerLogRecord is a single, timestamped, record from a container log.
+type ContainerLogRecord struct {
+	// Timestamp is the time the record was written by the container.
+	Timestamp time.Time
+	// Message is the content of the record. A trailing newline is added if it is missing, unless the record is partial.
+	Message []byte
+	// Partial is set when the record is only part of a line and the next record continues it.
+	Partial bool
+}
+
+// ContainerLogRecordReader is a stream of container log records, ordered from oldest to newest.
+type ContainerLogRecordReader interface {
+	// Next returns the next record.
+	// It returns io.EOF once there are no more records.
+	//
+	// When following logs, Next first returns all existing records followed by ErrLogHistoryEnd, and then blocks until
+	// new records are available, until the context is cancelled or the container exits.
+	Next(context.Context) (ContainerLogRecord, error)
+	Close() error
+}
+
+// ContainerLogRecordsFunc is used in place of backend implementations for getting container logs as records.
+//
+// Implementations must honor opts.Follow and opts.Previous. All other options (tail, since, limit and timestamps) are
+// applied to the returned records by the handler, implementations may use them as hints to avoid producing records
+// which will be discarded.
+type ContainerLogRecordsFunc func(ctx context.Context, namespace, podName, containerName string, opts ContainerLogOpts) (ContainerLogRecordReader, error)
+
+// HandleContainerLogRecords creates an http handler function from a provider to serve logs from a pod.
+// Unlike HandleContainerLogs, the provider only produces log records and the log options are applied by the handler
+// the same way the kubelet does.
+func HandleContainerLogRecords(h ContainerLogRecordsFunc) http.HandlerFunc {
+	if h == nil {
+		return NotImplemented
+	}
+	return handleError(func(w http.ResponseWriter, req *http.Request) error {
+		vars := podPathParams(req)
+		if len(vars) != 3 {
+			return errdefs.NotFound("not found")
+		}
+
+		ctx, cancel := streamSessionFrom(req.Context()).withDrain(req.Context())
+		defer cancel()
+
+		query := req.URL.Query()
+		opts, err := parseLogOptions(query)
+		if err != nil {
+			return err
+		}
+
+		// A zero tail is a valid request for no lines, so unset needs to be told apart.
+		tail := -1
+		if query.Has("tailLines") {
+			tail = opts.Tail
+		}
+
+		records, err := h(ctx, vars["namespace"], vars["pod"], vars["container"], opts)
+		if err != nil {
+			return errors.Wrap(err, "error getting container logs")
+		}
+		defer records.Close()
+
+		if err := writeLogRecords(ctx, flushOnWrite(w), records, opts, tail, time.Now()); err != nil && !isShuttingDown(ctx) {
+			return errors.Wrap(err, "error writing response to client")
+		}
+		return nil
+	})
+}
+
+// errLogLimitReached is used internally to stop writing logs once limitBytes has been written.
+var errLogLimitReached = errors.New("log limit reached")
+
+type logRecordWriter struct {
+	w          io.Writer
+	since      time.Time
+	timestamps bool
+	limited    bool
+	remaining  int
+}
+
+// write writes the record to the client, it returns errLogLimitReached when no more records should be written.
+func (lw *logRecordWriter) write(rec *ContainerLogRecord) error {
+	if !lw.since.IsZero() && rec.Timestamp.Before(lw.since) {
+		return nil
+	}
+
+	var line []byte
+	if lw.timestamps {
+		line = append(line, rec.Timestamp.UTC().Format(time.RFC3339Nano)...)
+		line = append(line, ' ')
+	}
+	line = append(line, rec.Message...)
+	if !rec.Partial && (len(rec.Message) == 0 || rec.Message[len(rec.Message)-1] != '\n') {
+		line = append(line, '\n')
+	}
+
+	truncated := false
+	if lw.limited && len(line) >= lw.remaining {
+		line = line[:lw.remaining]
+		truncated = true
+	}
+	n, err := lw.w.Write(line)
+	lw.remaining -= n
+	if err != nil {
+		return err
+	}
+	if truncated {
+		return errLogLimitReached
+	}
+	return nil
+}
+
+// writeLogRecords applies the log options to the records and writes them out in the same format as the kubelet.
+// tail is the number of most recent lines to write, or -1 for all lines.
+func writeLogRecords(ctx context.Context, w io.Writer, records ContainerLogRecordReader, opts ContainerLogOpts, tail int, now time.Time) error {
+	lw := &logRecordWriter{
+		w:          w,
+		since:      opts.SinceTime,
+		timestamps: opts.Timestamps,
+		limited:    opts.LimitBytes > 0,
+		remaining:  opts.LimitBytes,
+	}
+	if opts.SinceSeconds > 0 {
+		lw.since = now.Add(-time.Duration(opts.SinceSeconds) * time.Second)
+	}
+
+	err := writeLogHistory(ctx, lw, records, opts.Follow, tail, now)
+	if err == nil && opts.Follow {
+		err = followLogs(ctx, lw, records)
+	}
+	if err == errLogLimitReached || err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// writeLogHistory writes the records which existed when the logs were requested.
+// Tail is applied to these records only, just like the kubelet applies it to the log file before following it.
+// Tail counts lines rather than records: partial records are kept together with the rest of their line, and the
+// unfinished line at the end of the history, if any, is written after the last tail lines.
+func writeLogHistory(ctx context.Context, lw *logRecordWriter, records ContainerLogRecordReader, follow bool, tail int, now time.Time) error {
+	var (
+		lines   [][]ContainerLogRecord
+		current []ContainerLogRecord
+	)
+	flush := func() error {
+		for _, line := range append(lines, current) {
+			for i := range line {
+				if err := lw.write(&line[i]); err != nil {
+					return err
+				}
+			}
+		}
+		lines, current = nil, nil
+		return nil
+	}
+
+	for {
+		rec, err := records.Next(ctx)
+		if errors.Is(err, ErrLogHistoryEnd) {
+			return flush()
+		}
+		if err != nil {
+			if ferr := flush(); ferr != nil {
+				return ferr
+			}
+			return err
+		}
+
+		if follow && rec.Timestamp.After(now) {
+			// The provider did not mark the end of the history, but this record was written after the request.
+			if err := flush(); err != nil {
+				return err
+			}
+			return lw.write(&rec)
+		}
+
+		if tail < 0 {
+			if err := lw.write(&rec); err != nil {
+				return err
+			}
+			continue
+		}
+		current = append(current, rec)
+		if rec.Partial {
+			continue
+		}
+		if tail > 0 {
+			if len(lines) == tail {
+				copy(lines, lines[1:])
+				lines = lines[:tail-1]
+			}
+			lines = append(lines, current)
+		}
+		current = nil
+	}
+}
+
+// followLogs writes new records as they are produced until the stream ends.
+func followLogs(ctx context.Context, lw *logRecordWriter, records ContainerLogRecordReader) error {
+	for {
+		rec, err := records.Next(ctx)
+		if errors.Is(err, ErrLogHistoryEnd) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := lw.write(&rec); err != nil {
+			return err
+		}
+	}
+}
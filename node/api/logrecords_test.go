@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+// sliceLogRecords is a ContainerLogRecordReader returning a fixed list of records.
+// Records after historyEnd are only returned when following.
+type sliceLogRecords struct {
+	records    []ContainerLogRecord
+	historyEnd int
+	follow     bool
+	pos        int
+	sentEnd    bool
+}
+
+func (s *sliceLogRecords) Next(context.Context) (ContainerLogRecord, error) {
+	if s.pos == s.historyEnd && s.follow && !s.sentEnd {
+		s.sentEnd = true
+		return ContainerLogRecord{}, ErrLogHistoryEnd
+	}
+	if s.pos >= len(s.records) || (!s.follow && s.pos >= s.historyEnd) {
+		return ContainerLogRecord{}, io.EOF
+	}
+	s.pos++
+	return s.records[s.pos-1], nil
+}
+
+func (s *sliceLogRecords) Close() error {
+	return nil
+}
+
+func TestWriteLogRecords(t *testing.T) {
+	now := time.Date(2020, 3, 20, 21, 7, 34, 0, time.UTC)
+	records := []ContainerLogRecord{
+		{Timestamp: now.Add(-3 * time.Minute), Message: []byte("one")},
+		{Timestamp: now.Add(-2 * time.Minute), Message: []byte("two\n")},
+		{Timestamp: now.Add(-time.Minute), Message: []byte("thr"), Partial: true},
+		{Timestamp: now.Add(-time.Minute), Message: []byte("ee")},
+		{Timestamp: now.Add(time.Minute), Message: []byte("four")},
+	}
+
+	testCases := []struct {
+		name   string
+		opts   ContainerLogOpts
+		tail   int
+		expect string
+	}{
+		{name: "all", tail: -1, expect: "one\ntwo\nthree\n"},
+		{name: "tail", tail: 2, expect: "two\nthree\n"},
+		{name: "tail partial line", tail: 1, expect: "three\n"},
+		{name: "tail zero", tail: 0, expect: ""},
+		{name: "since seconds", opts: ContainerLogOpts{SinceSeconds: 150}, tail: -1, expect: "two\nthree\n"},
+		{name: "since time", opts: ContainerLogOpts{SinceTime: now.Add(-time.Minute)}, tail: -1, expect: "three\n"},
+		{name: "limit bytes", opts: ContainerLogOpts{LimitBytes: 6}, tail: -1, expect: "one\ntw"},
+		{
+			name:   "timestamps",
+			opts:   ContainerLogOpts{Timestamps: true},
+			tail:   2,
+			expect: "2020-03-20T21:05:34Z two\n2020-03-20T21:06:34Z thr2020-03-20T21:06:34Z ee\n",
+		},
+		{name: "follow", opts: ContainerLogOpts{Follow: true}, tail: 1, expect: "three\nfour\n"},
+		{name: "follow with limit", opts: ContainerLogOpts{Follow: true, LimitBytes: 8}, tail: -1, expect: "one\ntwo\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &sliceLogRecords{records: records, historyEnd: len(records) - 1, follow: tc.opts.Follow}
+			var buf bytes.Buffer
+			err := writeLogRecords(context.Background(), &buf, r, tc.opts, tc.tail, now)
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(buf.String(), tc.expect))
+		})
+	}
+
+	t.Run("follow without history end", func(t *testing.T) {
+		// The history ends at the first record written after the request.
+		r := &sliceLogRecords{records: records, historyEnd: len(records)}
+		var buf bytes.Buffer
+		err := writeLogRecords(context.Background(), &buf, r, ContainerLogOpts{Follow: true}, 1, now)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(buf.String(), "three\nfour\n"))
+	})
+
+	t.Run("tail with unfinished line", func(t *testing.T) {
+		// The line the container is still writing is not counted, but is written after the tail lines.
+		records := []ContainerLogRecord{
+			{Timestamp: now.Add(-3 * time.Minute), Message: []byte("one")},
+			{Timestamp: now.Add(-2 * time.Minute), Message: []byte("t"), Partial: true},
+			{Timestamp: now.Add(-2 * time.Minute), Message: []byte("w"), Partial: true},
+			{Timestamp: now.Add(-2 * time.Minute), Message: []byte("o")},
+			{Timestamp: now.Add(-time.Minute), Message: []byte("thr"), Partial: true},
+			{Timestamp: now.Add(time.Minute), Message: []byte("ee")},
+		}
+		for tail, expect := range map[int]string{1: "two\nthr", 2: "one\ntwo\nthr", 3: "one\ntwo\nthr"} {
+			r := &sliceLogRecords{records: records, historyEnd: len(records) - 1}
+			var buf bytes.Buffer
+			err := writeLogRecords(context.Background(), &buf, r, ContainerLogOpts{}, tail, now)
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(buf.String(), expect), "tail %d", tail)
+		}
+
+		r := &sliceLogRecords{records: records, historyEnd: len(records) - 1, follow: true}
+		var buf bytes.Buffer
+		err := writeLogRecords(context.Background(), &buf, r, ContainerLogOpts{Follow: true}, 1, now)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(buf.String(), "two\nthree\n"))
+	})
+}
+
+func TestHandleContainerLogRecords(t *testing.T) {
+	var gotOpts ContainerLogOpts
+	h := HandleContainerLogRecords(func(_ context.Context, namespace, pod, container string, opts ContainerLogOpts) (ContainerLogRecordReader, error) {
+		gotOpts = opts
+		now := time.Now()
+		return &sliceLogRecords{historyEnd: 2, records: []ContainerLogRecord{
+			{Timestamp: now, Message: []byte(namespace + "/" + pod)},
+			{Timestamp: now, Message: []byte(container)},
+		}}, nil
+	})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/containerLogs/{namespace}/{pod}/{container}", h)
+
+	req := httptest.NewRequest(http.MethodGet, "/containerLogs/default/foo/bar?tailLines=0", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Body.String(), ""))
+
+	req = httptest.NewRequest(http.MethodGet, "/containerLogs/default/foo/bar?limitBytes=100", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Body.String(), "default/foo\nbar\n"))
+	assert.Check(t, is.Equal(gotOpts.LimitBytes, 100))
+
+	req = httptest.NewRequest(http.MethodGet, "/containerLogs/default/foo/bar?tailLines=-1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Check(t, is.Equal(w.Code, http.StatusBadRequest))
+}
@@ -22,7 +22,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
@@ -43,6 +42,19 @@ type ContainerLogOpts struct {
 	SinceTime    time.Time
 }
 
+// parseFollow parses the follow parameter of log requests.
+func parseFollow(q url.Values) (bool, error) {
+	follow := q.Get("follow")
+	if follow == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(follow)
+	if err != nil {
+		return false, errdefs.AsInvalidInput(errors.Wrap(err, "could not parse \"follow\""))
+	}
+	return b, nil
+}
+
 func parseLogOptions(q url.Values) (opts ContainerLogOpts, err error) {
 	if tailLines := q.Get("tailLines"); tailLines != "" {
 		opts.Tail, err = strconv.Atoi(tailLines)
@@ -53,11 +65,9 @@ func parseLogOptions(q url.Values) (opts ContainerLogOpts, err error) {
 			return opts, errdefs.InvalidInputf("\"tailLines\" is %d", opts.Tail)
 		}
 	}
-	if follow := q.Get("follow"); follow != "" {
-		opts.Follow, err = strconv.ParseBool(follow)
-		if err != nil {
-			return opts, errdefs.AsInvalidInput(errors.Wrap(err, "could not parse \"follow\""))
-		}
+	opts.Follow, err = parseFollow(q)
+	if err != nil {
+		return opts, err
 	}
 	if limitBytes := q.Get("limitBytes"); limitBytes != "" {
 		opts.LimitBytes, err = strconv.Atoi(limitBytes)
@@ -107,12 +117,13 @@ func HandleContainerLogs(h ContainerLogsHandlerFunc) http.HandlerFunc {
 		return NotImplemented
 	}
 	return handleError(func(w http.ResponseWriter, req *http.Request) error {
-		vars := mux.Vars(req)
+		vars := podPathParams(req)
 		if len(vars) != 3 {
 			return errdefs.NotFound("not found")
 		}
 
-		ctx := req.Context()
+		ctx, cancel := streamSessionFrom(req.Context()).withDrain(req.Context())
+		defer cancel()
 
 		namespace := vars["namespace"]
 		pod := vars["pod"]
@@ -137,7 +148,7 @@ func HandleContainerLogs(h ContainerLogsHandlerFunc) http.HandlerFunc {
 			log.G(ctx).Debug("http response writer does not support flushes")
 		}
 
-		if _, err := io.Copy(flushOnWrite(w), logs); err != nil {
+		if _, err := io.Copy(flushOnWrite(w), logs); err != nil && !isShuttingDown(ctx) {
 			return errors.Wrap(err, "error writing response to client")
 		}
 		return nil
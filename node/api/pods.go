@@ -17,20 +17,60 @@ package api
 import (
 	"context"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/runtime/serializer/streaming"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/endpoints/handlers/negotiation"
 )
 
 type PodListerFunc func(context.Context) ([]*v1.Pod, error) //nolint:golint
 
-func HandleRunningPods(getPods PodListerFunc) http.HandlerFunc { //nolint:golint
+// PodWatcherFunc defines the handler for watching changes to the pods listed by a PodListerFunc.
+type PodWatcherFunc func(context.Context) (watch.Interface, error)
+
+// PodListHandlerConfig is used to configure the pod list handler.
+type PodListHandlerConfig struct {
+	// WatchPods is used to serve watch requests (`?watch=true`). Watch requests are rejected when it is not set.
+	WatchPods PodWatcherFunc
+}
+
+// PodListHandlerOption configures a PodListHandlerConfig
+// It is used as functional options passed to `HandleRunningPods`
+type PodListHandlerOption func(*PodListHandlerConfig)
+
+// WithPodWatcher sets the func used to serve watch requests
+func WithPodWatcher(f PodWatcherFunc) PodListHandlerOption {
+	return func(cfg *PodListHandlerConfig) {
+		cfg.WatchPods = f
+	}
+}
+
+// HandleRunningPods makes an HTTP handler for listing, and optionally watching, pods.
+//
+// Like the kubelet /pods endpoint, it supports the `labelSelector` and `fieldSelector` query parameters and encodes the
+// pods as JSON or protobuf depending on the Accept header.
+func HandleRunningPods(getPods PodListerFunc, opts ...PodListHandlerOption) http.HandlerFunc { //nolint:golint
 	if getPods == nil {
 		return NotImplemented
 	}
 
+	var cfg PodListHandlerConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
 	scheme := runtime.NewScheme()
 	/* #nosec */
 	v1.SchemeBuilder.AddToScheme(scheme) //nolint:errcheck
@@ -39,6 +79,34 @@ func HandleRunningPods(getPods PodListerFunc) http.HandlerFunc { //nolint:golint
 	return handleError(func(w http.ResponseWriter, req *http.Request) error {
 		ctx := req.Context()
 		ctx = log.WithLogger(ctx, log.L)
+
+		q := req.URL.Query()
+		selector, err := parsePodSelector(q)
+		if err != nil {
+			return err
+		}
+
+		if isWatchRequest(q) {
+			if cfg.WatchPods == nil {
+				return errdefs.NotImplemented("watching pods is not supported")
+			}
+			if s := q.Get("timeoutSeconds"); s != "" {
+				timeout, err := strconv.ParseInt(s, 10, 64)
+				if err != nil || timeout < 0 {
+					return errdefs.InvalidInputf("invalid timeoutSeconds %q", s)
+				}
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+				defer cancel()
+			}
+			return servePodWatch(ctx, w, req, codecs, getPods, cfg.WatchPods, selector)
+		}
+
+		_, info, err := negotiation.NegotiateOutputMediaType(req, codecs, negotiation.DefaultEndpointRestrictions)
+		if err != nil {
+			return err
+		}
+
 		pods, err := getPods(ctx)
 		if err != nil {
 			return err
@@ -49,15 +117,17 @@ func HandleRunningPods(getPods PodListerFunc) http.HandlerFunc { //nolint:golint
 		// PodList.
 		podList := new(v1.PodList)
 		for _, pod := range pods {
-			podList.Items = append(podList.Items, *pod)
+			if selector.matches(pod) {
+				podList.Items = append(podList.Items, *pod)
+			}
 		}
-		codec := codecs.LegacyCodec(v1.SchemeGroupVersion)
+		codec := codecs.EncoderForVersion(info.Serializer, v1.SchemeGroupVersion)
 		data, err := runtime.Encode(codec, podList)
 		if err != nil {
 			return err
 		}
 
-		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Type", info.MediaType)
 		_, err = w.Write(data)
 		if err != nil {
 			return err
@@ -65,3 +135,129 @@ func HandleRunningPods(getPods PodListerFunc) http.HandlerFunc { //nolint:golint
 		return nil
 	})
 }
+
+// isWatchRequest returns whether the pods are watched rather than listed.
+func isWatchRequest(q url.Values) bool {
+	watching, _ := strconv.ParseBool(q.Get("watch"))
+	return watching
+}
+
+// servePodWatch streams the pods matching the selector as watch events, starting with an added event for each
+// existing pod.
+func servePodWatch(ctx context.Context, w http.ResponseWriter, req *http.Request, codecs serializer.CodecFactory, getPods PodListerFunc, watchPods PodWatcherFunc, selector podSelector) error {
+	info, err := negotiation.NegotiateOutputMediaTypeStream(req, codecs, negotiation.DefaultEndpointRestrictions)
+	if err != nil {
+		return err
+	}
+
+	// Start watching before listing so changes in between are not missed.
+	watcher, err := watchPods(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error watching pods")
+	}
+	defer watcher.Stop()
+
+	pods, err := getPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	embedded := codecs.EncoderForVersion(info.Serializer, v1.SchemeGroupVersion)
+	enc := streaming.NewEncoder(info.StreamSerializer.Framer.NewFrameWriter(flushOnWrite(w)), info.StreamSerializer.Serializer)
+	send := func(t watch.EventType, obj runtime.Object) error {
+		raw, err := runtime.Encode(embedded, obj)
+		if err != nil {
+			return errors.Wrap(err, "error encoding watch event object")
+		}
+		return enc.Encode(&metav1.WatchEvent{Type: string(t), Object: runtime.RawExtension{Raw: raw}})
+	}
+
+	w.Header().Set("Content-Type", info.MediaType+";stream=watch")
+	w.WriteHeader(http.StatusOK)
+
+	// The response has started, errors from here on can only be logged.
+	logger := log.G(ctx)
+	for _, pod := range pods {
+		if !selector.matches(pod) {
+			continue
+		}
+		if err := send(watch.Added, pod); err != nil {
+			logger.WithError(err).Debug("Error writing pod watch event")
+			return nil
+		}
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-watcher.ResultChan():
+			if !ok {
+				return nil
+			}
+			if status, ok := e.Object.(*metav1.Status); ok && e.Type == watch.Error {
+				// The watch was ended, such as when the watcher fell behind, the client needs to list the pods again.
+				if err := send(e.Type, status); err != nil {
+					logger.WithError(err).Debug("Error writing pod watch event")
+				}
+				return nil
+			}
+			pod, ok := e.Object.(*v1.Pod)
+			if !ok || !selector.matches(pod) {
+				continue
+			}
+			if err := send(e.Type, pod); err != nil {
+				logger.WithError(err).Debug("Error writing pod watch event")
+				return nil
+			}
+		}
+	}
+}
+
+// podSelector selects pods by label and field selectors.
+type podSelector struct {
+	labels labels.Selector
+	fields fields.Selector
+}
+
+// parsePodSelector parses the `labelSelector` and `fieldSelector` query parameters.
+func parsePodSelector(q url.Values) (podSelector, error) {
+	ls, err := labels.Parse(q.Get("labelSelector"))
+	if err != nil {
+		return podSelector{}, errdefs.AsInvalidInput(errors.Wrap(err, "invalid label selector"))
+	}
+	fs, err := fields.ParseSelector(q.Get("fieldSelector"))
+	if err != nil {
+		return podSelector{}, errdefs.AsInvalidInput(errors.Wrap(err, "invalid field selector"))
+	}
+	for _, r := range fs.Requirements() {
+		if _, ok := podFields(&v1.Pod{})[r.Field]; !ok {
+			return podSelector{}, errdefs.InvalidInputf("field label not supported: %s", r.Field)
+		}
+	}
+	return podSelector{labels: ls, fields: fs}, nil
+}
+
+func (s podSelector) matches(pod *v1.Pod) bool {
+	return s.labels.Matches(labels.Set(pod.Labels)) && s.fields.Matches(podFields(pod))
+}
+
+// podFields returns the fields of the pod which can be used in field selectors, these are the same as for the pods
+// API in the API server.
+func podFields(pod *v1.Pod) fields.Set {
+	return fields.Set{
+		"metadata.name":            pod.Name,
+		"metadata.namespace":       pod.Namespace,
+		"spec.nodeName":            pod.Spec.NodeName,
+		"spec.restartPolicy":       string(pod.Spec.RestartPolicy),
+		"spec.schedulerName":       pod.Spec.SchedulerName,
+		"spec.serviceAccountName":  pod.Spec.ServiceAccountName,
+		"spec.hostNetwork":         strconv.FormatBool(pod.Spec.HostNetwork),
+		"status.phase":             string(pod.Status.Phase),
+		"status.podIP":             pod.Status.PodIP,
+		"status.nominatedNodeName": pod.Status.NominatedNodeName,
+	}
+}
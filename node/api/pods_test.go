@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func newTestPod(name, app string, phase v1.PodPhase) *v1.Pod {
+	pod := &v1.Pod{}
+	pod.Namespace = "default"
+	pod.Name = name
+	pod.Labels = map[string]string{"app": app}
+	pod.Status.Phase = phase
+	return pod
+}
+
+func TestHandleRunningPods(t *testing.T) {
+	pods := []*v1.Pod{
+		newTestPod("web-0", "web", v1.PodRunning),
+		newTestPod("web-1", "web", v1.PodPending),
+		newTestPod("db-0", "db", v1.PodRunning),
+	}
+	h := HandleRunningPods(func(context.Context) ([]*v1.Pod, error) {
+		return pods, nil
+	})
+
+	list := func(query string) []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pods"+query, nil))
+		assert.Assert(t, is.Equal(w.Code, http.StatusOK), w.Body.String())
+		assert.Check(t, is.Equal(w.Header().Get("Content-Type"), "application/json"))
+		var podList v1.PodList
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &podList))
+		assert.Check(t, is.Equal(podList.Kind, "PodList"))
+		var names []string
+		for _, pod := range podList.Items {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	assert.Check(t, is.DeepEqual(list(""), []string{"web-0", "web-1", "db-0"}))
+	assert.Check(t, is.DeepEqual(list("?labelSelector=app%3Dweb"), []string{"web-0", "web-1"}))
+	assert.Check(t, is.DeepEqual(list("?labelSelector=app%3Dweb&fieldSelector=status.phase%3DRunning"), []string{"web-0"}))
+	assert.Check(t, is.DeepEqual(list("?fieldSelector=metadata.name!%3Dweb-0,metadata.namespace%3Ddefault"), []string{"web-1", "db-0"}))
+
+	for _, query := range []string{"?fieldSelector=spec.foo%3Dbar", "?labelSelector=app%3D%3D%3D"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pods"+query, nil))
+		assert.Check(t, is.Equal(w.Code, http.StatusBadRequest), query)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/pods?labelSelector=app%3Ddb", nil)
+	req.Header.Set("Accept", runtime.ContentTypeProtobuf)
+	h.ServeHTTP(w, req)
+	assert.Assert(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Header().Get("Content-Type"), runtime.ContentTypeProtobuf))
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(w.Body.Bytes(), nil, nil)
+	assert.NilError(t, err)
+	podList := obj.(*v1.PodList)
+	assert.Assert(t, is.Len(podList.Items, 1))
+	assert.Check(t, is.Equal(podList.Items[0].Name, "db-0"))
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/pods", nil)
+	req.Header.Set("Accept", "application/xml")
+	h.ServeHTTP(w, req)
+	assert.Check(t, is.Equal(w.Code, http.StatusNotAcceptable))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pods?watch=true", nil))
+	assert.Check(t, is.Equal(w.Code, http.StatusNotImplemented))
+}
+
+func TestHandleRunningPodsWatch(t *testing.T) {
+	fw := watch.NewFake()
+	srv := httptest.NewServer(HandleRunningPods(func(context.Context) ([]*v1.Pod, error) {
+		return []*v1.Pod{newTestPod("web-0", "web", v1.PodPending), newTestPod("db-0", "db", v1.PodRunning)}, nil
+	}, WithPodWatcher(func(context.Context) (watch.Interface, error) {
+		return fw, nil
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/pods?watch=true&labelSelector=app%3Dweb")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	assert.Assert(t, is.Equal(resp.StatusCode, http.StatusOK))
+	assert.Check(t, is.Equal(resp.Header.Get("Content-Type"), "application/json;stream=watch"))
+
+	dec := json.NewDecoder(resp.Body)
+	next := func() (string, *v1.Pod) {
+		t.Helper()
+		var e metav1.WatchEvent
+		assert.NilError(t, dec.Decode(&e))
+		var pod v1.Pod
+		assert.NilError(t, json.Unmarshal(e.Object.Raw, &pod))
+		return e.Type, &pod
+	}
+
+	typ, pod := next()
+	assert.Check(t, is.Equal(typ, string(watch.Added)))
+	assert.Check(t, is.Equal(pod.Name, "web-0"))
+
+	fw.Modify(newTestPod("db-0", "db", v1.PodSucceeded))
+	fw.Modify(newTestPod("web-0", "web", v1.PodRunning))
+	typ, pod = next()
+	assert.Check(t, is.Equal(typ, string(watch.Modified)))
+	assert.Check(t, is.Equal(pod.Name, "web-0"))
+	assert.Check(t, is.Equal(pod.Status.Phase, v1.PodRunning))
+
+	fw.Delete(newTestPod("web-0", "web", v1.PodRunning))
+	typ, _ = next()
+	assert.Check(t, is.Equal(typ, string(watch.Deleted)))
+
+	fw.Stop()
+	var e metav1.WatchEvent
+	assert.Check(t, dec.Decode(&e) != nil, "stream should end when the watch is closed")
+}
+
+func TestHandleRunningPodsWatchExpired(t *testing.T) {
+	fw := watch.NewFake()
+	srv := httptest.NewServer(HandleRunningPods(func(context.Context) ([]*v1.Pod, error) {
+		return nil, nil
+	}, WithPodWatcher(func(context.Context) (watch.Interface, error) {
+		return fw, nil
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/pods?watch=true")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	assert.Assert(t, is.Equal(resp.StatusCode, http.StatusOK))
+
+	status := apierrors.NewResourceExpired("pod watch fell behind").ErrStatus
+	fw.Error(&status)
+
+	dec := json.NewDecoder(resp.Body)
+	var e metav1.WatchEvent
+	assert.NilError(t, dec.Decode(&e))
+	assert.Check(t, is.Equal(e.Type, string(watch.Error)))
+	var got metav1.Status
+	assert.NilError(t, json.Unmarshal(e.Object.Raw, &got))
+	assert.Check(t, is.Equal(got.Code, int32(http.StatusGone)))
+	assert.Check(t, is.Equal(got.Reason, metav1.StatusReasonExpired))
+	assert.Check(t, dec.Decode(&e) != nil, "stream should end after the error event")
+}
@@ -21,7 +21,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/internal/kubernetes/portforward"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -30,6 +30,44 @@ import (
 // portforward, passing through the original dataStream
 type PortForwardHandlerFunc func(ctx context.Context, namespace, pod string, port int32, stream io.ReadWriteCloser) error
 
+// PortForwardProtocol is the protocol of a port forwarding target, named like the networks of package net.
+type PortForwardProtocol string
+
+const (
+	PortForwardProtocolTCP      PortForwardProtocol = portforward.ProtocolTCP
+	PortForwardProtocolUDP      PortForwardProtocol = portforward.ProtocolUDP
+	PortForwardProtocolUnix     PortForwardProtocol = portforward.ProtocolUnix
+	PortForwardProtocolUnixgram PortForwardProtocol = portforward.ProtocolUnixgram
+)
+
+// PortForwardMaxDatagramSize is the maximum size of the datagrams forwarded to udp and unixgram targets.
+const PortForwardMaxDatagramSize = portforward.MaxDatagramSize
+
+// PortForwardTarget is where a port forwarding stream goes to in the pod.
+//
+// Clients set the protocol with the "protocol" stream header, or query parameter for websocket connections, which is
+// tcp when it is not set. The port is set by the "port" header and the socket path of the unix protocols by the
+// "path" header.
+type PortForwardTarget struct {
+	Protocol PortForwardProtocol
+	// Port is set for the tcp and udp protocols.
+	Port int32
+	// Path is the path of the socket in the pod, it is set for the unix and unixgram protocols.
+	Path string
+}
+
+// Datagram returns whether the target uses a datagram protocol, udp or unixgram.
+func (t PortForwardTarget) Datagram() bool {
+	return t.Protocol == PortForwardProtocolUDP || t.Protocol == PortForwardProtocolUnixgram
+}
+
+// PortForwardStreamHandlerFunc defines the handler function used to portforward to targets of any protocol.
+//
+// For datagram targets, the datagrams are framed on the data stream by their length and the stream passed to the
+// handler reads and writes whole datagrams: each Read returns a single datagram, and each Write sends one, of at most
+// PortForwardMaxDatagramSize bytes.
+type PortForwardStreamHandlerFunc func(ctx context.Context, namespace, pod string, target PortForwardTarget, stream io.ReadWriteCloser) error
+
 // PortForwardHandlerConfig is used to pass options to options to the container exec handler.
 type PortForwardHandlerConfig struct {
 	// StreamIdleTimeout is the maximum time a streaming connection
@@ -58,11 +96,32 @@ func WithPortForwardCreationTimeout(dur time.Duration) PortForwardHandlerOption
 }
 
 // HandlePortForward makes an http handler func from a Provider which forward ports to a container
-// Note that this handler currently depends on gorrilla/mux to get url parts as variables.
+// The namespace and pod are read from the path parameters of the request, see PathParam.
+// Only TCP ports are forwarded, see HandlePortForwardStream for other protocols.
 func HandlePortForward(h PortForwardHandlerFunc, opts ...PortForwardHandlerOption) http.HandlerFunc {
 	if h == nil {
 		return NotImplemented
 	}
+	return HandlePortForwardStream(tcpPortForward(h), opts...)
+}
+
+// tcpPortForward adapts a PortForwardHandlerFunc, rejecting targets which are not TCP ports.
+func tcpPortForward(h PortForwardHandlerFunc) PortForwardStreamHandlerFunc {
+	return func(ctx context.Context, namespace, pod string, target PortForwardTarget, stream io.ReadWriteCloser) error {
+		if target.Protocol != PortForwardProtocolTCP {
+			return errdefs.InvalidInputf("port forwarding over %s is not supported", target.Protocol)
+		}
+		return h(ctx, namespace, pod, target.Port, stream)
+	}
+}
+
+// HandlePortForwardStream makes an http handler func from a Provider which forwards ports and sockets of any
+// protocol to a container.
+// The namespace and pod are read from the path parameters of the request, see PathParam.
+func HandlePortForwardStream(h PortForwardStreamHandlerFunc, opts ...PortForwardHandlerOption) http.HandlerFunc {
+	if h == nil {
+		return NotImplemented
+	}
 
 	var cfg PortForwardHandlerConfig
 	for _, o := range opts {
@@ -77,7 +136,7 @@ func HandlePortForward(h PortForwardHandlerFunc, opts ...PortForwardHandlerOptio
 	}
 
 	return handleError(func(w http.ResponseWriter, req *http.Request) error {
-		vars := mux.Vars(req)
+		vars := podPathParams(req)
 
 		namespace := vars["namespace"]
 
@@ -85,14 +144,20 @@ func HandlePortForward(h PortForwardHandlerFunc, opts ...PortForwardHandlerOptio
 
 		supportedStreamProtocols := strings.Split(req.Header.Get("X-Stream-Protocol-Version"), ",")
 
-		portfwd := &portForwardContext{h: h, pod: pod, namespace: namespace}
+		// The options are only used for websocket connections.
+		wsOpts, err := portforward.NewV4Options(req)
+		if err != nil {
+			return errdefs.AsInvalidInput(err)
+		}
+
+		portfwd := &portForwardContext{h: h, pod: pod, namespace: namespace, audit: auditEventFrom(req.Context()), session: streamSessionFrom(req.Context())}
 		portforward.ServePortForward(
 			w,
 			req,
 			portfwd,
 			pod,
 			"",
-			&portforward.V4Options{}, // This is only used for websocket connection
+			wsOpts,
 			cfg.StreamIdleTimeout,
 			cfg.StreamCreationTimeout,
 			supportedStreamProtocols,
@@ -104,13 +169,24 @@ func HandlePortForward(h PortForwardHandlerFunc, opts ...PortForwardHandlerOptio
 }
 
 type portForwardContext struct {
-	h         PortForwardHandlerFunc
+	h         PortForwardStreamHandlerFunc
 	pod       string
 	namespace string
+	audit     *AuditEvent
+	session   *streamSession
 }
 
 // PortForward Implements portforward.Portforwarder
 // This is called by portforward.ServePortForward
-func (p *portForwardContext) PortForward(ctx context.Context, name string, uid types.UID, port int32, stream io.ReadWriteCloser) error {
-	return p.h(ctx, p.namespace, p.pod, port, stream)
+func (p *portForwardContext) PortForward(ctx context.Context, name string, uid types.UID, t portforward.Target, stream io.ReadWriteCloser) error {
+	target := PortForwardTarget{Protocol: PortForwardProtocol(t.Protocol), Port: t.Port, Path: t.Path}
+	p.audit.recordPortForward(target)
+	ctx, cancel := p.session.withDrain(ctx)
+	defer cancel()
+	err := p.h(ctx, p.namespace, p.pod, target, stream)
+	if isShuttingDown(ctx) {
+		err = errShuttingDown
+	}
+	p.audit.recordError(err)
+	return err
 }
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/internal/kubernetes/portforward"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// dialPortForward opens a port forwarding connection to the pod handler served at srvURL.
+func dialPortForward(t *testing.T, srvURL string) httpstream.Connection {
+	t.Helper()
+	rt, upgrader, err := spdy.RoundTripperFor(&restclient.Config{Host: srvURL})
+	assert.NilError(t, err)
+	u, err := url.Parse(srvURL + "/portForward/default/web")
+	assert.NilError(t, err)
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: rt}, http.MethodPost, u)
+	conn, _, err := dialer.Dial(portforward.ProtocolV1Name)
+	assert.NilError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// forwardStreams creates the error and data streams of a port forwarding request with the target headers.
+func forwardStreams(t *testing.T, conn httpstream.Connection, requestID string, target map[string]string) (errorStream, dataStream httpstream.Stream) {
+	t.Helper()
+	headers := http.Header{}
+	for k, v := range target {
+		headers.Set(k, v)
+	}
+	headers.Set(corev1.PortForwardRequestIDHeader, requestID)
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeError)
+	errorStream, err := conn.CreateStream(headers)
+	assert.NilError(t, err)
+	assert.NilError(t, errorStream.Close())
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeData)
+	dataStream, err = conn.CreateStream(headers)
+	assert.NilError(t, err)
+	return errorStream, dataStream
+}
+
+func TestHandlePortForwardStream(t *testing.T) {
+	targets := make(chan PortForwardTarget, 1)
+	srv := httptest.NewServer(PodHandler(PodHandlerConfig{
+		PortForwardStream: func(_ context.Context, namespace, pod string, target PortForwardTarget, stream io.ReadWriteCloser) error {
+			targets <- target
+			buf := make([]byte, PortForwardMaxDatagramSize)
+			for {
+				n, err := stream.Read(buf)
+				if err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+				// Echo the datagrams, or the stream chunks for tcp targets, in upper case.
+				if _, err := stream.Write(bytes.ToUpper(buf[:n])); err != nil {
+					return err
+				}
+			}
+		},
+	}, false))
+	defer srv.Close()
+	conn := dialPortForward(t, srv.URL)
+
+	_, data := forwardStreams(t, conn, "0", map[string]string{"protocol": "udp", "port": "53"})
+	assert.Check(t, is.Equal(<-targets, PortForwardTarget{Protocol: PortForwardProtocolUDP, Port: 53}))
+	for _, d := range []string{"hello", "world"} {
+		frame := binary.BigEndian.AppendUint16(nil, uint16(len(d)))
+		_, err := data.Write(append(frame, d...))
+		assert.NilError(t, err)
+
+		reply := make([]byte, 2+len(d))
+		_, err = io.ReadFull(data, reply)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(binary.BigEndian.Uint16(reply), uint16(len(d))))
+		assert.Check(t, is.Equal(string(reply[2:]), strings.ToUpper(d)))
+	}
+	assert.NilError(t, data.Close())
+
+	_, data = forwardStreams(t, conn, "1", map[string]string{"protocol": "unix", "path": "/run/app.sock"})
+	assert.Check(t, is.Equal(<-targets, PortForwardTarget{Protocol: PortForwardProtocolUnix, Path: "/run/app.sock"}))
+	_, err := data.Write([]byte("ping"))
+	assert.NilError(t, err)
+	reply := make([]byte, 4)
+	_, err = io.ReadFull(data, reply)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(reply), "PING"))
+	assert.NilError(t, data.Close())
+}
+
+func TestHandlePortForwardTCPOnly(t *testing.T) {
+	ports := make(chan int32, 1)
+	srv := httptest.NewServer(PodHandler(PodHandlerConfig{
+		PortForward: func(_ context.Context, namespace, pod string, port int32, stream io.ReadWriteCloser) error {
+			ports <- port
+			_, err := io.Copy(stream, stream)
+			return err
+		},
+	}, false))
+	defer srv.Close()
+	conn := dialPortForward(t, srv.URL)
+
+	// Streams without a protocol are forwarded to a TCP port.
+	_, data := forwardStreams(t, conn, "0", map[string]string{"port": "8080"})
+	assert.Check(t, is.Equal(<-ports, int32(8080)))
+	_, err := data.Write([]byte("ping"))
+	assert.NilError(t, err)
+	reply := make([]byte, 4)
+	_, err = io.ReadFull(data, reply)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(reply), "ping"))
+	assert.NilError(t, data.Close())
+
+	errorStream, data := forwardStreams(t, conn, "1", map[string]string{"protocol": "udp", "port": "53"})
+	msg, err := io.ReadAll(errorStream)
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(msg), "error forwarding udp port 53 to pod web"))
+	assert.Check(t, is.Contains(string(msg), "port forwarding over udp is not supported"))
+	data.Close()
+	assert.Check(t, is.Len(ports, 0))
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ProxyRoutePrefix is the prefix of the routes of ProxyRoutes.
+const ProxyRoutePrefix = "/proxy/"
+
+// ProxyRoutes is a registry of http handlers served under /proxy/<name>/ on the node, for providers to expose their
+// own endpoints such as diagnostics.
+// Like the other routes of the node, they are reachable through the node proxy of the API server, at
+// /api/v1/nodes/<node>/proxy/proxy/<name>/.
+//
+// The handlers get the requests with the /proxy/<name> prefix removed from the path.
+// Requests to /proxy/ list the registered names.
+type ProxyRoutes struct {
+	mu     sync.RWMutex
+	routes map[string]http.Handler
+}
+
+// NewProxyRoutes creates an empty ProxyRoutes.
+func NewProxyRoutes() *ProxyRoutes {
+	return &ProxyRoutes{routes: make(map[string]http.Handler)}
+}
+
+// Register adds a handler under /proxy/<name>/, name must be a DNS label.
+func (r *ProxyRoutes) Register(name string, h http.Handler) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return errdefs.InvalidInputf("invalid proxy route name %q: %s", name, strings.Join(errs, ", "))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.routes[name]; ok {
+		return errdefs.Conflictf("proxy route %q is already registered", name)
+	}
+	r.routes[name] = h
+	return nil
+}
+
+// Unregister removes the handler registered under name, if any.
+func (r *ProxyRoutes) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.routes, name)
+}
+
+// Names returns the sorted names of the registered handlers.
+func (r *ProxyRoutes) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.routes))
+	for name := range r.routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP dispatches requests to the registered handlers.
+func (r *ProxyRoutes) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rest, ok := strings.CutPrefix(req.URL.Path, ProxyRoutePrefix)
+	if !ok {
+		NotFound(w, req)
+		return
+	}
+	if rest == "" {
+		if req.Method != http.MethodGet {
+			NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.Names()) //nolint:errcheck
+		return
+	}
+
+	name, path, _ := strings.Cut(rest, "/")
+	r.mu.RLock()
+	h, ok := r.routes[name]
+	r.mu.RUnlock()
+	if !ok {
+		NotFound(w, req)
+		return
+	}
+
+	req2 := req.Clone(req.Context())
+	req2.URL.Path = "/" + path
+	req2.URL.RawPath = ""
+	h.ServeHTTP(w, req2)
+}
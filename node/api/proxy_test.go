@@ -0,0 +1,51 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestProxyRoutes(t *testing.T) {
+	routes := NewProxyRoutes()
+	echoPath := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, req.URL.Path) //nolint:errcheck
+	})
+	assert.NilError(t, routes.Register("diag", echoPath))
+	assert.NilError(t, routes.Register("debug", echoPath))
+	assert.Check(t, errdefs.IsConflict(routes.Register("diag", echoPath)))
+	assert.Check(t, errdefs.IsInvalidInput(routes.Register("Not/Valid", echoPath)))
+
+	for name, r := range map[string]Router{
+		"servemux": NewServeMuxRouter(),
+		"gorilla":  NewGorillaRouter(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := PodHandlerWithRouter(PodHandlerConfig{ProxyRoutes: routes}, r, false)
+			do := func(path string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				return w
+			}
+
+			w := do("/proxy/diag/goroutines")
+			assert.Check(t, is.Equal(w.Code, http.StatusOK))
+			assert.Check(t, is.Equal(w.Body.String(), "/goroutines"))
+			assert.Check(t, is.Equal(do("/proxy/diag").Body.String(), "/"))
+
+			w = do("/proxy/")
+			assert.Check(t, is.Equal(w.Code, http.StatusOK))
+			assert.Check(t, is.Equal(w.Body.String(), "[\"debug\",\"diag\"]\n"))
+
+			assert.Check(t, is.Equal(do("/proxy/unknown/").Code, http.StatusNotFound))
+		})
+	}
+
+	routes.Unregister("diag")
+	assert.Check(t, is.DeepEqual(routes.Names(), []string{"debug"}))
+}
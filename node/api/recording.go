@@ -0,0 +1,345 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// Terminal size written to the header of recordings, sessions start with this size until the client resizes the
+// terminal.
+const (
+	defaultCastWidth  = 80
+	defaultCastHeight = 24
+)
+
+// Event types of asciinema cast files.
+const (
+	CastEventOutput = "o"
+	CastEventInput  = "i"
+	CastEventResize = "r"
+)
+
+// SessionInfo describes a recorded exec or attach session.
+type SessionInfo struct {
+	// ID identifies the session, it is also set on the audit event of the request.
+	ID string
+	// Subresource is either exec or attach.
+	Subresource string
+	Namespace   string
+	Pod         string
+	Container   string
+	// Command is the command executed, for exec sessions.
+	Command []string
+	TTY     bool
+	// Time is when the session started.
+	Time time.Time
+}
+
+// SessionRecordingSink is called when an exec or attach session starts and returns where the recording of the
+// session is written to. The writer is closed when the session ends.
+//
+// Recordings are asciinema cast files (version 2): stdin, stdout and stderr are recorded as input and output events
+// and terminal resizes as resize events. Stdout and stderr are both recorded as output.
+type SessionRecordingSink func(ctx context.Context, session SessionInfo) (io.WriteCloser, error)
+
+// NewSessionRecordingDir creates a SessionRecordingSink which writes recordings to "<session ID>.cast" files in dir.
+func NewSessionRecordingDir(dir string) SessionRecordingSink {
+	return func(_ context.Context, session SessionInfo) (io.WriteCloser, error) {
+		f, err := os.OpenFile(filepath.Join(dir, session.ID+".cast"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating session recording")
+		}
+		return f, nil
+	}
+}
+
+// WithExecSessionRecording sets where exec and attach sessions are recorded.
+// Sessions fail to start when the recording cannot be created.
+func WithExecSessionRecording(sink SessionRecordingSink) ContainerExecHandlerOption {
+	return func(cfg *ContainerExecHandlerConfig) {
+		cfg.SessionRecording = sink
+	}
+}
+
+// CastHeader is the header of an asciinema cast file.
+type CastHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Command   string `json:"command,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// CastEvent is an event of an asciinema cast file.
+type CastEvent struct {
+	// Time is the time of the event since the start of the session, in seconds.
+	Time float64
+	Type string
+	Data string
+}
+
+// MarshalJSON implements json.Marshaler, events are encoded as [time, type, data].
+func (e CastEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *CastEvent) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return errors.Errorf("invalid cast event: expected 3 fields, got %d", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return errors.Wrap(err, "invalid cast event time")
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return errors.Wrap(err, "invalid cast event type")
+	}
+	if err := json.Unmarshal(fields[2], &e.Data); err != nil {
+		return errors.Wrap(err, "invalid cast event data")
+	}
+	return nil
+}
+
+// ReadCast reads a recording made with a SessionRecordingSink.
+func ReadCast(r io.Reader) (*CastHeader, []CastEvent, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var hdr CastHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return nil, nil, errors.Wrap(err, "error reading cast header")
+	}
+	if hdr.Version != 2 {
+		return nil, nil, errors.Errorf("unsupported cast version %d", hdr.Version)
+	}
+
+	var events []CastEvent
+	for dec.More() {
+		var e CastEvent
+		if err := dec.Decode(&e); err != nil {
+			return nil, nil, errors.Wrap(err, "error reading cast event")
+		}
+		events = append(events, e)
+	}
+	return &hdr, events, nil
+}
+
+// ReplayCast writes the output of a recording to w, without waiting between events.
+func ReplayCast(r io.Reader, w io.Writer) error {
+	_, events, err := ReadCast(r)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if e.Type != CastEventOutput {
+			continue
+		}
+		if _, err := io.WriteString(w, e.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionRecorder writes the events of a session to a cast file.
+// All methods are safe to call on a nil recorder, which records nothing.
+type sessionRecorder struct {
+	id    string
+	start time.Time
+
+	mu  sync.Mutex
+	w   io.WriteCloser
+	err error
+}
+
+// startSessionRecording starts recording the session when sink is set, replacing the streams of eio with ones which
+// record the data going through them.
+func startSessionRecording(ctx context.Context, sink SessionRecordingSink, info SessionInfo, eio *execIO) (*sessionRecorder, error) {
+	if sink == nil {
+		return nil, nil
+	}
+
+	info.ID = string(uuid.NewUUID())
+	info.TTY = eio.tty
+	info.Time = time.Now()
+	w, err := sink(ctx, info)
+	if err != nil {
+		return nil, errors.Wrap(err, "error starting session recording")
+	}
+
+	r := &sessionRecorder{id: info.ID, start: info.Time, w: w}
+	hdr := CastHeader{
+		Version:   2,
+		Width:     defaultCastWidth,
+		Height:    defaultCastHeight,
+		Timestamp: info.Time.Unix(),
+		Command:   strings.Join(info.Command, " "),
+		Title:     fmt.Sprintf("%s %s/%s/%s", info.Subresource, info.Namespace, info.Pod, info.Container),
+	}
+	if err := r.writeLine(hdr); err != nil {
+		w.Close() //nolint:errcheck
+		return nil, errors.Wrap(err, "error writing session recording header")
+	}
+
+	if eio.stdin != nil {
+		eio.stdin = &recordingReader{Reader: eio.stdin, r: r}
+	}
+	if eio.stdout != nil {
+		eio.stdout = &recordingWriter{WriteCloser: eio.stdout, r: r}
+	}
+	if eio.stderr != nil {
+		eio.stderr = &recordingWriter{WriteCloser: eio.stderr, r: r}
+	}
+	return r, nil
+}
+
+// ID returns the ID of the recorded session, or an empty string if the session is not recorded.
+func (r *sessionRecorder) ID() string {
+	if r == nil {
+		return ""
+	}
+	return r.id
+}
+
+func (r *sessionRecorder) resize(s TermSize) {
+	r.event(CastEventResize, fmt.Sprintf("%dx%d", s.Width, s.Height))
+}
+
+func (r *sessionRecorder) event(typ, data string) {
+	r.streamEvent(typ, nil, []byte(data), false)
+}
+
+// streamEvent records the data written to or read from a stream. Runes split across chunks would be replaced with
+// U+FFFD when encoded as JSON, so the bytes of an incomplete rune at the end of p are held back in pending until
+// the rest of the rune is recorded, or until the final event of the stream.
+func (r *sessionRecorder) streamEvent(typ string, pending *[]byte, p []byte, final bool) {
+	if r == nil {
+		return
+	}
+
+	// The lock is taken before the time so events of different streams are written in time order.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pending != nil {
+		if final {
+			p = append(*pending, p...)
+			*pending = nil
+		} else {
+			p = completeRunes(pending, p)
+		}
+	}
+	if len(p) == 0 {
+		return
+	}
+	// Microsecond precision is plenty for replaying and keeps recordings small.
+	t := math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+	r.writeLineLocked(CastEvent{Time: t, Type: typ, Data: string(p)}) //nolint:errcheck
+}
+
+// completeRunes returns pending followed by p, up to the last complete rune. The bytes of an incomplete rune at the
+// end are kept in pending.
+func completeRunes(pending *[]byte, p []byte) []byte {
+	b := append(*pending, p...)
+	end := len(b)
+	for i := len(b) - 1; i >= 0 && i > len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	// Copy the held back bytes, b may share its array with pending.
+	*pending = append([]byte(nil), b[end:]...)
+	return b[:end]
+}
+
+// writeLine writes v as a JSON line.
+// Once a write fails, the rest of the session is not recorded.
+func (r *sessionRecorder) writeLine(v interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.writeLineLocked(v)
+}
+
+func (r *sessionRecorder) writeLineLocked(v interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := r.w.Write(append(b, '\n')); err != nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// Close ends the recording.
+func (r *sessionRecorder) Close(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		log.G(ctx).WithError(r.err).WithField("session", r.id).Error("Session recording is incomplete")
+	}
+	if err := r.w.Close(); err != nil {
+		log.G(ctx).WithError(err).WithField("session", r.id).Error("Error closing session recording")
+	}
+	if r.err == nil {
+		r.err = os.ErrClosed
+	}
+}
+
+type recordingReader struct {
+	io.Reader
+	r       *sessionRecorder
+	pending []byte
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 || err != nil {
+		r.r.streamEvent(CastEventInput, &r.pending, p[:n], err != nil)
+	}
+	return n, err
+}
+
+type recordingWriter struct {
+	io.WriteCloser
+	r       *sessionRecorder
+	pending []byte
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	if n > 0 {
+		w.r.streamEvent(CastEventOutput, &w.pending, p[:n], false)
+	}
+	return n, err
+}
+
+func (w *recordingWriter) Close() error {
+	w.r.streamEvent(CastEventOutput, &w.pending, nil, true)
+	return w.WriteCloser.Close()
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+type testSizeQueue chan remotecommand.TerminalSize
+
+func (q testSizeQueue) Next() *remotecommand.TerminalSize {
+	s, ok := <-q
+	if !ok {
+		return nil
+	}
+	return &s
+}
+
+func TestExecSessionRecording(t *testing.T) {
+	dir := t.TempDir()
+	audited := make(chan *AuditEvent, 1)
+	h := PodHandler(PodHandlerConfig{
+		RunInContainer: func(_ context.Context, _, _, _ string, cmd []string, attach AttachIO) error {
+			// Stdin is not closed by v4 WebSocket clients, read the expected input only.
+			// It is read before waiting for the resize as WebSocket streams are read in order.
+			b := make([]byte, len("hello\n"))
+			if _, err := io.ReadFull(attach.Stdin(), b); err != nil {
+				return err
+			}
+			size := <-attach.Resize()
+			assert.Check(t, is.Equal(size, TermSize{Width: 100, Height: 40}))
+
+			_, err := attach.Stdout().Write([]byte(strings.Join(cmd, " ") + ": " + string(b)))
+			return err
+		},
+		RecordSession: NewSessionRecordingDir(dir),
+		Audit:         func(_ context.Context, e *AuditEvent) { audited <- e },
+	}, false)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	execURL := srv.URL + "/exec/default/web/app?command=echo&input=1&output=1&tty=1"
+	config := &restclient.Config{Host: srv.URL}
+	newExecutors := map[string]func() (remotecommand.Executor, error){
+		"spdy": func() (remotecommand.Executor, error) {
+			u, err := url.Parse(execURL)
+			if err != nil {
+				return nil, err
+			}
+			return remotecommand.NewSPDYExecutor(config, "POST", u)
+		},
+		"websocket": func() (remotecommand.Executor, error) {
+			return remotecommand.NewWebSocketExecutorForProtocols(config, "GET", execURL, "v4.channel.k8s.io")
+		},
+	}
+
+	for name, newExecutor := range newExecutors {
+		t.Run(name, func(t *testing.T) {
+			exec, err := newExecutor()
+			assert.NilError(t, err)
+
+			sizes := make(testSizeQueue, 1)
+			sizes <- remotecommand.TerminalSize{Width: 100, Height: 40}
+			defer close(sizes)
+
+			var stdout bytes.Buffer
+			err = exec.StreamWithContext(context.Background(), remotecommand.StreamOptions{
+				Stdin:             strings.NewReader("hello\n"),
+				Stdout:            &stdout,
+				Tty:               true,
+				TerminalSizeQueue: sizes,
+			})
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(stdout.String(), "echo: hello\n"))
+
+			// The recording is complete once the request is audited.
+			audit := <-audited
+			assert.Assert(t, audit.SessionID != "")
+
+			f, err := os.Open(filepath.Join(dir, audit.SessionID+".cast"))
+			assert.NilError(t, err)
+			defer f.Close()
+			hdr, events, err := ReadCast(f)
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(hdr.Command, "echo"))
+			assert.Check(t, is.Equal(hdr.Title, "exec default/web/app"))
+
+			var input string
+			var resized bool
+			for _, e := range events {
+				switch e.Type {
+				case CastEventInput:
+					input += e.Data
+				case CastEventResize:
+					resized = resized || e.Data == "100x40"
+				}
+			}
+			assert.Check(t, is.Equal(input, "hello\n"))
+			assert.Check(t, resized)
+
+			_, err = f.Seek(0, io.SeekStart)
+			assert.NilError(t, err)
+			var replay bytes.Buffer
+			assert.NilError(t, ReplayCast(f, &replay))
+			assert.Check(t, is.Equal(replay.String(), stdout.String()))
+		})
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestSessionRecordingSplitRunes(t *testing.T) {
+	var cast bytes.Buffer
+	sink := func(context.Context, SessionInfo) (io.WriteCloser, error) { return nopWriteCloser{&cast}, nil }
+	eio := &execIO{
+		// Read stdin one byte at a time, splitting each rune of the euro signs.
+		stdin:  iotest.OneByteReader(strings.NewReader("€€")),
+		stdout: nopWriteCloser{io.Discard},
+	}
+	r, err := startSessionRecording(context.Background(), sink, SessionInfo{}, eio)
+	assert.NilError(t, err)
+
+	_, err = io.ReadAll(eio.stdin)
+	assert.NilError(t, err)
+	out := []byte("héllo wörld")
+	for _, chunk := range [][]byte{out[:2], out[2:9], out[9:]} {
+		_, err := eio.stdout.Write(chunk)
+		assert.NilError(t, err)
+	}
+	// The incomplete rune at the end of the output is recorded when the stream is closed.
+	_, err = eio.stdout.Write([]byte{0xe2, 0x82})
+	assert.NilError(t, err)
+	assert.NilError(t, eio.stdout.Close())
+	r.Close(context.Background())
+
+	_, events, err := ReadCast(&cast)
+	assert.NilError(t, err)
+	var input, output []string
+	var last float64
+	for _, e := range events {
+		assert.Check(t, e.Time >= last, "events are not in time order")
+		last = e.Time
+		switch e.Type {
+		case CastEventInput:
+			input = append(input, e.Data)
+		case CastEventOutput:
+			output = append(output, e.Data)
+		}
+	}
+	assert.Check(t, is.DeepEqual(input, []string{"€", "€"}))
+	assert.Check(t, is.DeepEqual(output, []string{"h", "éllo w", "örld", "\ufffd\ufffd"}))
+}
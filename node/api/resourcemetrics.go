@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	statsv1alpha1 "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+)
+
+// MetricsResourceFromStatsSummary adapts a stats summary handler to a /metrics/resource handler, so providers which
+// only implement stats are usable by metrics-server.
+//
+// Like the kubelet, a failure to get the summary is reported with the scrape_error metric instead of failing the
+// request.
+func MetricsResourceFromStatsSummary(f PodStatsSummaryHandlerFunc) PodMetricsResourceHandlerFunc {
+	return func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		summary, err := f(ctx)
+		if err != nil {
+			if isCancelled(err) {
+				return nil, err
+			}
+			log.G(ctx).WithError(err).Error("Error getting stats summary for resource metrics")
+			return []*dto.MetricFamily{scrapeError(1)}, nil
+		}
+		return ResourceMetricsFromSummary(summary), nil
+	}
+}
+
+// ResourceMetricsFromSummary converts the summary to the metrics served by the kubelet on /metrics/resource.
+// Stats missing from the summary are left out of the metrics.
+func ResourceMetricsFromSummary(summary *statsv1alpha1.Summary) []*dto.MetricFamily {
+	var (
+		nodeCPU            = newMetricFamily("node_cpu_usage_seconds_total", "Cumulative cpu time consumed by the node in core-seconds", dto.MetricType_COUNTER)
+		nodeMemory         = newMetricFamily("node_memory_working_set_bytes", "Current working set of the node in bytes", dto.MetricType_GAUGE)
+		nodeSwap           = newMetricFamily("node_swap_usage_bytes", "Current swap usage of the node in bytes", dto.MetricType_GAUGE)
+		podCPU             = newMetricFamily("pod_cpu_usage_seconds_total", "Cumulative cpu time consumed by the pod in core-seconds", dto.MetricType_COUNTER)
+		podMemory          = newMetricFamily("pod_memory_working_set_bytes", "Current working set of the pod in bytes", dto.MetricType_GAUGE)
+		podSwap            = newMetricFamily("pod_swap_usage_bytes", "Current amount of the pod swap usage in bytes", dto.MetricType_GAUGE)
+		containerCPU       = newMetricFamily("container_cpu_usage_seconds_total", "Cumulative cpu time consumed by the container in core-seconds", dto.MetricType_COUNTER)
+		containerMemory    = newMetricFamily("container_memory_working_set_bytes", "Current working set of the container in bytes", dto.MetricType_GAUGE)
+		containerSwap      = newMetricFamily("container_swap_usage_bytes", "Current amount of the container swap usage in bytes", dto.MetricType_GAUGE)
+		containerStartTime = newMetricFamily("container_start_time_seconds", "Start time of the container since unix epoch in seconds", dto.MetricType_GAUGE)
+	)
+
+	addCPU(nodeCPU, summary.Node.CPU)
+	addMemory(nodeMemory, summary.Node.Memory)
+	addSwap(nodeSwap, summary.Node.Swap)
+
+	for _, pod := range summary.Pods {
+		labels := []string{"namespace", pod.PodRef.Namespace, "pod", pod.PodRef.Name}
+		addCPU(podCPU, pod.CPU, labels...)
+		addMemory(podMemory, pod.Memory, labels...)
+		addSwap(podSwap, pod.Swap, labels...)
+
+		for _, c := range pod.Containers {
+			labels := []string{"container", c.Name, "namespace", pod.PodRef.Namespace, "pod", pod.PodRef.Name}
+			addCPU(containerCPU, c.CPU, labels...)
+			addMemory(containerMemory, c.Memory, labels...)
+			addSwap(containerSwap, c.Swap, labels...)
+			if !c.StartTime.IsZero() {
+				containerStartTime.Metric = append(containerStartTime.Metric,
+					newMetric(dto.MetricType_GAUGE, float64(c.StartTime.UnixNano())/float64(time.Second), c.StartTime, labels...))
+			}
+		}
+	}
+
+	families := []*dto.MetricFamily{scrapeError(0)}
+	for _, mf := range []*dto.MetricFamily{nodeCPU, nodeMemory, nodeSwap, podCPU, podMemory, podSwap, containerCPU, containerMemory, containerSwap, containerStartTime} {
+		if len(mf.Metric) > 0 {
+			families = append(families, mf)
+		}
+	}
+	return families
+}
+
+func addCPU(mf *dto.MetricFamily, s *statsv1alpha1.CPUStats, labels ...string) {
+	if s == nil || s.UsageCoreNanoSeconds == nil {
+		return
+	}
+	v := float64(*s.UsageCoreNanoSeconds) / float64(time.Second)
+	mf.Metric = append(mf.Metric, newMetric(dto.MetricType_COUNTER, v, s.Time, labels...))
+}
+
+func addMemory(mf *dto.MetricFamily, s *statsv1alpha1.MemoryStats, labels ...string) {
+	if s == nil || s.WorkingSetBytes == nil {
+		return
+	}
+	mf.Metric = append(mf.Metric, newMetric(dto.MetricType_GAUGE, float64(*s.WorkingSetBytes), s.Time, labels...))
+}
+
+func addSwap(mf *dto.MetricFamily, s *statsv1alpha1.SwapStats, labels ...string) {
+	if s == nil || s.SwapUsageBytes == nil {
+		return
+	}
+	mf.Metric = append(mf.Metric, newMetric(dto.MetricType_GAUGE, float64(*s.SwapUsageBytes), s.Time, labels...))
+}
+
+func scrapeError(v float64) *dto.MetricFamily {
+	mf := newMetricFamily("scrape_error", "1 if there was an error while getting container metrics, 0 otherwise", dto.MetricType_GAUGE)
+	mf.Metric = []*dto.Metric{{Gauge: &dto.Gauge{Value: &v}}}
+	return mf
+}
+
+func newMetricFamily(name, help string, t dto.MetricType) *dto.MetricFamily {
+	return &dto.MetricFamily{Name: &name, Help: &help, Type: &t}
+}
+
+// newMetric creates a metric with the value and timestamp, labels are passed as name, value pairs.
+func newMetric(t dto.MetricType, value float64, ts metav1.Time, labels ...string) *dto.Metric {
+	m := &dto.Metric{}
+	if !ts.IsZero() {
+		ms := ts.UnixMilli()
+		m.TimestampMs = &ms
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		name, value := labels[i], labels[i+1]
+		m.Label = append(m.Label, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	if t == dto.MetricType_COUNTER {
+		m.Counter = &dto.Counter{Value: &value}
+	} else {
+		m.Gauge = &dto.Gauge{Value: &value}
+	}
+	return m
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	statsv1alpha1 "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+)
+
+func TestMetricsResourceFromStatsSummary(t *testing.T) {
+	ts := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	summary := &statsv1alpha1.Summary{
+		Node: statsv1alpha1.NodeStats{
+			NodeName: "node",
+			CPU:      &statsv1alpha1.CPUStats{Time: ts, UsageCoreNanoSeconds: uint64Ptr(uint64(3 * time.Second))},
+		},
+		Pods: []statsv1alpha1.PodStats{{
+			PodRef: statsv1alpha1.PodReference{Namespace: "default", Name: "foo"},
+			Memory: &statsv1alpha1.MemoryStats{Time: ts, WorkingSetBytes: uint64Ptr(1024)},
+			Containers: []statsv1alpha1.ContainerStats{{
+				Name:      "bar",
+				StartTime: ts,
+				CPU:       &statsv1alpha1.CPUStats{Time: ts, UsageCoreNanoSeconds: uint64Ptr(uint64(time.Second))},
+				// Missing values are not reported.
+				Memory: &statsv1alpha1.MemoryStats{Time: ts},
+			}},
+		}},
+	}
+
+	var getErr error
+	h := PodHandler(PodHandlerConfig{
+		GetStatsSummary: func(context.Context) (*statsv1alpha1.Summary, error) {
+			return summary, getErr
+		},
+	}, false)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, MetricsResourceRouteSuffix, nil))
+		return w
+	}
+
+	w := get()
+	assert.Assert(t, is.Equal(w.Code, http.StatusOK))
+	lines := make(map[string]bool)
+	for _, l := range strings.Split(w.Body.String(), "\n") {
+		if l != "" && !strings.HasPrefix(l, "#") {
+			lines[l] = true
+		}
+	}
+	assert.Check(t, is.DeepEqual(lines, map[string]bool{
+		"scrape_error 0": true,
+		"node_cpu_usage_seconds_total 3 1704067200000":                                                            true,
+		`pod_memory_working_set_bytes{namespace="default",pod="foo"} 1024 1704067200000`:                          true,
+		`container_cpu_usage_seconds_total{container="bar",namespace="default",pod="foo"} 1 1704067200000`:        true,
+		`container_start_time_seconds{container="bar",namespace="default",pod="foo"} 1.7040672e+09 1704067200000`: true,
+	}))
+
+	getErr = errors.New("provider is down")
+	w = get()
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Contains(w.Body.String(), "scrape_error 1"))
+
+	getErr = context.Canceled
+	_, err := MetricsResourceFromStatsSummary(func(context.Context) (*statsv1alpha1.Summary, error) {
+		return nil, getErr
+	})(context.Background())
+	assert.Check(t, is.ErrorContains(err, "canceled"))
+}
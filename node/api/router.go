@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Router is used by PodHandlerWithRouter to register the routes of the pod handlers.
+//
+// Routes are patterns with path parameters written as {name}, for example "/exec/{namespace}/{pod}/{container}".
+// The handlers read the parameters with PathParam, routers other than the ones of NewServeMuxRouter and
+// NewGorillaRouter need to make them available with WithPathParams.
+type Router interface {
+	// Handle registers the handler for requests to the route with one of the methods, or any method if none is passed.
+	Handle(route string, h http.Handler, methods ...string)
+	// HandlePrefix registers the handler for requests to any path starting with the prefix, which ends with a slash.
+	HandlePrefix(prefix string, h http.Handler)
+	http.Handler
+}
+
+// PathParamFunc returns the value of the path parameter of the request, or an empty string if it is not set.
+type PathParamFunc func(req *http.Request, name string) string
+
+type pathParamKey struct{}
+
+// WithPathParams makes the handlers read the path parameters of the request with f, to use them with routers which
+// are not supported by PathParam.
+func WithPathParams(f PathParamFunc, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), pathParamKey{}, f)))
+	})
+}
+
+// ServeMuxPathParam reads the path parameters set by the patterns of http.ServeMux.
+func ServeMuxPathParam(req *http.Request, name string) string {
+	return req.PathValue(name)
+}
+
+// GorillaPathParam reads the path parameters set by the routes of gorilla/mux.
+func GorillaPathParam(req *http.Request, name string) string {
+	return mux.Vars(req)[name]
+}
+
+// PathParam returns the value of the path parameter of the request.
+// Parameters are read with the function set by WithPathParams, or else from the patterns of http.ServeMux and the
+// routes of gorilla/mux.
+func PathParam(req *http.Request, name string) string {
+	if f, ok := req.Context().Value(pathParamKey{}).(PathParamFunc); ok {
+		return f(req, name)
+	}
+	if v := ServeMuxPathParam(req, name); v != "" {
+		return v
+	}
+	return GorillaPathParam(req, name)
+}
+
+// podPathParams returns the pod path parameters which are set on the request.
+func podPathParams(req *http.Request) map[string]string {
+	params := make(map[string]string, 3)
+	for _, name := range []string{"namespace", "pod", "container"} {
+		if v := PathParam(req, name); v != "" {
+			params[name] = v
+		}
+	}
+	return params
+}
+
+type serveMuxRouter struct {
+	mux *http.ServeMux
+}
+
+// NewServeMuxRouter returns a Router using an http.ServeMux.
+// Routes ending with a slash only match that exact path, unlike ServeMux patterns which match the whole subtree, and
+// requests which don't match any route get a NotFound status.
+func NewServeMuxRouter() Router {
+	m := http.NewServeMux()
+	m.Handle("/", http.HandlerFunc(NotFound))
+	return &serveMuxRouter{mux: m}
+}
+
+func (r *serveMuxRouter) Handle(route string, h http.Handler, methods ...string) {
+	if strings.HasSuffix(route, "/") {
+		route += "{$}"
+	}
+	if len(methods) == 0 {
+		r.mux.Handle(route, h)
+		return
+	}
+	for _, m := range methods {
+		r.mux.Handle(m+" "+route, h)
+	}
+}
+
+func (r *serveMuxRouter) HandlePrefix(prefix string, h http.Handler) {
+	r.mux.Handle(prefix, h)
+}
+
+func (r *serveMuxRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+type gorillaRouter struct {
+	r *mux.Router
+}
+
+// NewGorillaRouter returns a Router using gorilla/mux, which redirects requests with or without a trailing slash to
+// the route registered for the other, like the reference kubelet.
+// Requests which don't match any route get a NotFound status.
+func NewGorillaRouter() Router {
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+	r.NotFoundHandler = http.HandlerFunc(NotFound)
+	return &gorillaRouter{r: r}
+}
+
+func (r *gorillaRouter) Handle(route string, h http.Handler, methods ...string) {
+	rt := r.r.Handle(route, h)
+	if len(methods) > 0 {
+		rt.Methods(methods...)
+	}
+}
+
+func (r *gorillaRouter) HandlePrefix(prefix string, h http.Handler) {
+	r.r.PathPrefix(prefix).Handler(h)
+}
+
+func (r *gorillaRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.r.ServeHTTP(w, req)
+}
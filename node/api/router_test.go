@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPodHandlerWithRouter(t *testing.T) {
+	cfg := PodHandlerConfig{
+		GetContainerLogs: func(_ context.Context, namespace, pod, container string, _ ContainerLogOpts) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(namespace + "/" + pod + "/" + container)), nil
+		},
+		GetPods: func(context.Context) ([]*v1.Pod, error) {
+			return nil, nil
+		},
+	}
+
+	for name, r := range map[string]Router{
+		"servemux": NewServeMuxRouter(),
+		"gorilla":  NewGorillaRouter(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := PodHandlerWithRouter(cfg, r, true)
+			do := func(method, path string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+				return w
+			}
+
+			w := do(http.MethodGet, "/containerLogs/default/web/app")
+			assert.Check(t, is.Equal(w.Code, http.StatusOK))
+			assert.Check(t, is.Equal(w.Body.String(), "default/web/app"))
+
+			assert.Check(t, is.Equal(do(http.MethodGet, "/runningpods/").Code, http.StatusOK))
+			assert.Check(t, is.Equal(do(http.MethodGet, "/runningpods/other").Code, http.StatusNotFound))
+			assert.Check(t, is.Equal(do(http.MethodGet, "/containerLogs/default/web").Code, http.StatusNotFound))
+			assert.Check(t, is.Equal(do(http.MethodGet, "/unknown").Code, http.StatusNotFound))
+			assert.Check(t, do(http.MethodPost, "/containerLogs/default/web/app").Code >= 400)
+		})
+	}
+}
+
+func TestWithPathParams(t *testing.T) {
+	h := HandleContainerLogs(func(_ context.Context, namespace, pod, container string, _ ContainerLogOpts) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(namespace + "/" + pod + "/" + container)), nil
+	})
+
+	// A router which passes the parameters in the query.
+	params := func(req *http.Request, name string) string {
+		return req.URL.Query().Get(name)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/logs?namespace=default&pod=web&container=app", nil)
+	WithPathParams(params, h).ServeHTTP(w, req)
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Body.String(), "default/web/app"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Check(t, is.Equal(w.Code, http.StatusNotFound), "parameters are not found without the adapter")
+}
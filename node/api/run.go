@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+// runCommand returns the command of a /run request, which like the kubelet is passed as a single cmd parameter.
+func runCommand(req *http.Request) []string {
+	return strings.Fields(req.FormValue("cmd"))
+}
+
+// HandleContainerRun makes an http handler func from a Provider which runs a command in a pod's container and
+// responds with its combined output, like the /run endpoint of the kubelet.
+// The command is executed through the exec handler of the provider without stdin or tty.
+// The command is read from the cmd query or form parameter, so both GET and POST requests can be handled.
+// The namespace, pod and container are read from the path parameters of the request, see PathParam.
+func HandleContainerRun(h ContainerExecHandlerFunc) http.HandlerFunc {
+	if h == nil {
+		return NotImplemented
+	}
+	return handleError(func(w http.ResponseWriter, req *http.Request) error {
+		params := podPathParams(req)
+		if len(params) != 3 {
+			return errdefs.NotFound("not found")
+		}
+
+		cmd := runCommand(req)
+		if len(cmd) == 0 {
+			return errdefs.InvalidInput("missing cmd parameter")
+		}
+
+		out := &runOutput{}
+		eio := &execIO{stdout: out, stderr: out}
+		if err := h(req.Context(), params["namespace"], params["pod"], params["container"], cmd, eio); err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		_, err := w.Write(out.Bytes())
+		return err
+	})
+}
+
+// runOutput combines stdout and stderr, which may be written concurrently.
+type runOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *runOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+// Bytes returns a copy of the output, providers may still write to it after returning.
+func (o *runOutput) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]byte(nil), o.buf.Bytes()...)
+}
+
+func (o *runOutput) Close() error {
+	return nil
+}
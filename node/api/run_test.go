@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestHandleContainerRun(t *testing.T) {
+	h := PodHandler(PodHandlerConfig{
+		RunInContainer: func(_ context.Context, namespace, pod, container string, cmd []string, attach AttachIO) error {
+			assert.Check(t, attach.Stdin() == nil)
+			assert.Check(t, !attach.TTY())
+			if cmd[0] == "missing" {
+				return errdefs.NotFoundf("command %s not found", cmd[0])
+			}
+			io.WriteString(attach.Stdout(), namespace+"/"+pod+"/"+container+": ") //nolint:errcheck
+			io.WriteString(attach.Stderr(), strings.Join(cmd, " "))               //nolint:errcheck
+			return nil
+		},
+	}, false)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := do(http.MethodPost, "/run/default/web/app?cmd=echo+hello")
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Body.String(), "default/web/app: echo hello"))
+
+	w = do(http.MethodPost, "/run/default/web/1234/app?cmd=ls")
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Body.String(), "default/web/app: ls"))
+
+	assert.Check(t, is.Equal(do(http.MethodPost, "/run/default/web/app").Code, http.StatusBadRequest))
+	assert.Check(t, is.Equal(do(http.MethodPost, "/run/default/web/app?cmd=missing").Code, http.StatusNotFound))
+
+	// GET requests are sent by `kubectl get --raw` through the API server proxy.
+	w = do(http.MethodGet, "/run/default/web/app?cmd=ls")
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Equal(w.Body.String(), "default/web/app: ls"))
+	w = do(http.MethodGet, "/run/default/web/1234/app?cmd=ls")
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, do(http.MethodPut, "/run/default/web/app?cmd=ls").Code >= 400)
+
+	h = PodHandler(PodHandlerConfig{}, false)
+	assert.Check(t, is.Equal(do(http.MethodPost, "/run/default/web/app?cmd=ls").Code, http.StatusNotImplemented))
+}
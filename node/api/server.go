@@ -37,13 +37,32 @@ type PodHandlerConfig struct { //nolint:golint
 	RunInContainer    ContainerExecHandlerFunc
 	AttachToContainer ContainerAttachHandlerFunc
 	PortForward       PortForwardHandlerFunc
+	// PortForwardStream, when set, is used instead of PortForward to forward ports and sockets of any protocol.
+	PortForwardStream PortForwardStreamHandlerFunc
 	GetContainerLogs  ContainerLogsHandlerFunc
+	// GetContainerLogRecords, when set, is used to serve container logs instead of GetContainerLogs, with the log
+	// options applied to the records by the handler.
+	GetContainerLogRecords ContainerLogRecordsFunc
 	// GetPods is meant to enumerate the pods that the provider knows about
 	GetPods PodListerFunc
 	// GetPodsFromKubernetes is meant to enumerate the pods that the node is meant to be running
 	GetPodsFromKubernetes PodListerFunc
-	GetStatsSummary       PodStatsSummaryHandlerFunc
-	GetMetricsResource    PodMetricsResourceHandlerFunc
+	// WatchPodsFromKubernetes is meant to watch changes to the pods returned by GetPodsFromKubernetes
+	WatchPodsFromKubernetes PodWatcherFunc
+	GetStatsSummary         PodStatsSummaryHandlerFunc
+	// GetMetricsResource serves /metrics/resource. When not set it is derived from GetStatsSummary.
+	GetMetricsResource  PodMetricsResourceHandlerFunc
+	CheckpointContainer ContainerCheckpointHandlerFunc
+	// RecordSession, when set, is used to record exec and attach sessions.
+	RecordSession SessionRecordingSink
+	// Audit, when set, is called with an audit event for each exec, attach, port-forward, run and container logs request.
+	Audit AuditFunc
+	// Limits are the limits on concurrent streams and request rates.
+	Limits RequestLimits
+	// Sessions, when set, tracks the streaming sessions so they can be drained on shutdown.
+	Sessions *StreamSessions
+	// ProxyRoutes, when set, are served under /proxy/.
+	ProxyRoutes           *ProxyRoutes
 	StreamIdleTimeout     time.Duration
 	StreamCreationTimeout time.Duration
 }
@@ -51,53 +70,81 @@ type PodHandlerConfig struct { //nolint:golint
 const MetricsResourceRouteSuffix = "/metrics/resource"
 
 // PodHandler creates an http handler for interacting with pods/containers.
+// The routes are served with gorilla/mux, see PodHandlerWithRouter.
 func PodHandler(p PodHandlerConfig, debug bool) http.Handler {
-	r := mux.NewRouter()
+	return PodHandlerWithRouter(p, NewGorillaRouter(), debug)
+}
 
-	// This matches the behaviour in the reference kubelet
-	r.StrictSlash(true)
+// PodHandlerWithRouter registers the routes for interacting with pods/containers on the router and returns it.
+func PodHandlerWithRouter(p PodHandlerConfig, r Router, debug bool) http.Handler {
+	limits := newRequestLimiter(p.Limits)
 	if debug {
-		r.HandleFunc("/runningpods/", HandleRunningPods(p.GetPods)).Methods("GET")
+		r.Handle("/runningpods/", limits.wrap(EndpointClassPods, HandleRunningPods(p.GetPods)), "GET")
+	}
+	r.Handle("/pods", limits.wrap(EndpointClassPods, HandleRunningPods(p.GetPodsFromKubernetes, WithPodWatcher(p.WatchPodsFromKubernetes))), "GET")
+	logsHandler := HandleContainerLogs(p.GetContainerLogs)
+	if p.GetContainerLogRecords != nil {
+		logsHandler = HandleContainerLogRecords(p.GetContainerLogRecords)
 	}
-	r.HandleFunc("/pods", HandleRunningPods(p.GetPodsFromKubernetes)).Methods("GET")
-	r.HandleFunc("/containerLogs/{namespace}/{pod}/{container}", HandleContainerLogs(p.GetContainerLogs)).Methods("GET")
-	r.HandleFunc(
+	r.Handle("/containerLogs/{namespace}/{pod}/{container}", withAudit("log", p.Audit, limits.wrap(EndpointClassLogs, p.Sessions.track(EndpointClassLogs, logsHandler))), "GET")
+	r.Handle(
 		"/exec/{namespace}/{pod}/{container}",
-		HandleContainerExec(
+		withAudit("exec", p.Audit, limits.wrap(EndpointClassStreaming, p.Sessions.track(EndpointClassStreaming, HandleContainerExec(
 			p.RunInContainer,
 			WithExecStreamCreationTimeout(p.StreamCreationTimeout),
 			WithExecStreamIdleTimeout(p.StreamIdleTimeout),
-		),
-	).Methods("POST", "GET")
-	r.HandleFunc(
+			WithExecSessionRecording(p.RecordSession),
+		)))),
+		"POST", "GET",
+	)
+	r.Handle(
 		"/attach/{namespace}/{pod}/{container}",
-		HandleContainerAttach(
+		withAudit("attach", p.Audit, limits.wrap(EndpointClassStreaming, p.Sessions.track(EndpointClassStreaming, HandleContainerAttach(
 			p.AttachToContainer,
 			WithExecStreamCreationTimeout(p.StreamCreationTimeout),
 			WithExecStreamIdleTimeout(p.StreamIdleTimeout),
-		),
-	).Methods("POST", "GET")
-	r.HandleFunc(
+			WithExecSessionRecording(p.RecordSession),
+		)))),
+		"POST", "GET",
+	)
+	portForwardOpts := []PortForwardHandlerOption{
+		WithPortForwardStreamIdleTimeout(p.StreamCreationTimeout),
+		WithPortForwardCreationTimeout(p.StreamIdleTimeout),
+	}
+	portForwardHandler := HandlePortForward(p.PortForward, portForwardOpts...)
+	if p.PortForwardStream != nil {
+		portForwardHandler = HandlePortForwardStream(p.PortForwardStream, portForwardOpts...)
+	}
+	r.Handle(
 		"/portForward/{namespace}/{pod}",
-		HandlePortForward(
-			p.PortForward,
-			WithPortForwardStreamIdleTimeout(p.StreamCreationTimeout),
-			WithPortForwardCreationTimeout(p.StreamIdleTimeout),
-		),
-	).Methods("POST", "GET")
+		withAudit("portforward", p.Audit, limits.wrap(EndpointClassStreaming, p.Sessions.track(EndpointClassStreaming, portForwardHandler))),
+		"POST", "GET",
+	)
+	runHandler := withAudit("run", p.Audit, limits.wrap(EndpointClassStreaming, HandleContainerRun(p.RunInContainer)))
+	// GET is accepted too for requests proxied by the API server, such as `kubectl get --raw`.
+	r.Handle("/run/{namespace}/{pod}/{container}", runHandler, "POST", "GET")
+	r.Handle("/run/{namespace}/{pod}/{uid}/{container}", runHandler, "POST", "GET")
+	r.Handle("/checkpoint/{namespace}/{pod}/{container}", limits.wrap(EndpointClassCheckpoint, HandleContainerCheckpoint(p.CheckpointContainer)), "POST")
 
 	if p.GetStatsSummary != nil {
-		f := HandlePodStatsSummary(p.GetStatsSummary)
-		r.HandleFunc("/stats/summary", f).Methods("GET")
-		r.HandleFunc("/stats/summary/", f).Methods("GET")
+		f := limits.wrap(EndpointClassStats, HandlePodStatsSummary(p.GetStatsSummary))
+		r.Handle("/stats/summary", f, "GET")
+		r.Handle("/stats/summary/", f, "GET")
 	}
 
-	if p.GetMetricsResource != nil {
-		f := HandlePodMetricsResource(p.GetMetricsResource)
-		r.HandleFunc(MetricsResourceRouteSuffix, f).Methods("GET")
-		r.HandleFunc(MetricsResourceRouteSuffix+"/", f).Methods("GET")
+	if p.ProxyRoutes != nil {
+		r.HandlePrefix(ProxyRoutePrefix, p.ProxyRoutes)
+	}
+
+	getMetricsResource := p.GetMetricsResource
+	if getMetricsResource == nil && p.GetStatsSummary != nil {
+		getMetricsResource = MetricsResourceFromStatsSummary(p.GetStatsSummary)
+	}
+	if getMetricsResource != nil {
+		f := limits.wrap(EndpointClassStats, HandlePodMetricsResource(getMetricsResource))
+		r.Handle(MetricsResourceRouteSuffix, f, "GET")
+		r.Handle(MetricsResourceRouteSuffix+"/", f, "GET")
 	}
-	r.NotFoundHandler = http.HandlerFunc(NotFound)
 	return r
 }
 
@@ -165,15 +212,19 @@ type PodMetricsConfig struct {
 // Callers should take care to namespace the serve mux as they see fit, however
 // these routes get called by the Kubernetes API server.
 func AttachPodMetricsRoutes(p PodMetricsConfig, mux ServeMux) {
+	getMetricsResource := p.GetMetricsResource
+	if getMetricsResource == nil && p.GetStatsSummary != nil {
+		getMetricsResource = MetricsResourceFromStatsSummary(p.GetStatsSummary)
+	}
 	mux.Handle("/", InstrumentHandler(HandlePodStatsSummary(p.GetStatsSummary)))
-	mux.Handle("/", InstrumentHandler(HandlePodMetricsResource(p.GetMetricsResource)))
+	mux.Handle("/", InstrumentHandler(HandlePodMetricsResource(getMetricsResource)))
 }
 
 func instrumentRequest(r *http.Request) *http.Request {
 	ctx := r.Context()
 	logger := log.G(ctx).WithFields(log.Fields{
 		"uri":  r.RequestURI,
-		"vars": mux.Vars(r),
+		"vars": podPathParams(r),
 	})
 	ctx = log.WithLogger(ctx, logger)
 
@@ -195,11 +246,11 @@ func InstrumentHandler(h http.Handler) http.Handler {
 // NotFound provides a handler for cases where the requested endpoint doesn't exist
 func NotFound(w http.ResponseWriter, r *http.Request) {
 	log.G(r.Context()).Debug("404 request not found")
-	http.Error(w, "404 request not found", http.StatusNotFound)
+	writeStatus(w, r, newStatus(http.StatusNotFound, "404 request not found"))
 }
 
 // NotImplemented provides a handler for cases where a provider does not implement a given API
 func NotImplemented(w http.ResponseWriter, r *http.Request) {
 	log.G(r.Context()).Debug("501 not implemented")
-	http.Error(w, "501 not implemented", http.StatusNotImplemented)
+	writeStatus(w, r, newStatus(http.StatusNotImplemented, "501 not implemented"))
 }
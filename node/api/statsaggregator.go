@@ -0,0 +1,355 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	statsv1alpha1 "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+)
+
+// DefaultStatsCollectionInterval is the default minimum time between two collections of stats samples.
+const DefaultStatsCollectionInterval = 10 * time.Second
+
+// PodStatsSample is a point in time sample of the resource usage of a pod, as reported by a provider.
+// Counters are cumulative since the start of the pod or container, rates are computed by the StatsAggregator.
+type PodStatsSample struct {
+	PodRef    statsv1alpha1.PodReference
+	StartTime time.Time
+	// Time is when the sample was taken, it defaults to the time the samples were collected.
+	Time       time.Time
+	Containers []ContainerStatsSample
+	// Network has the network counters of the pod, the first interface is the default interface.
+	Network []NetworkInterfaceSample
+}
+
+// ContainerStatsSample is a point in time sample of the resource usage of a container.
+type ContainerStatsSample struct {
+	Name      string
+	StartTime time.Time
+	// CPUUsageCoreNanoSeconds is the cumulative CPU time consumed by the container.
+	CPUUsageCoreNanoSeconds uint64
+	MemoryUsageBytes        uint64
+	MemoryWorkingSetBytes   uint64
+	MemoryRSSBytes          uint64
+}
+
+// NetworkInterfaceSample has the cumulative counters of a network interface.
+type NetworkInterfaceSample struct {
+	Name     string
+	RxBytes  uint64
+	RxErrors uint64
+	TxBytes  uint64
+	TxErrors uint64
+}
+
+// PodStatsSamplesFunc defines the handler for getting resource usage samples for all pods of a provider.
+type PodStatsSamplesFunc func(context.Context) ([]PodStatsSample, error)
+
+// StatsAggregator builds the /stats/summary and /metrics/resource responses from the samples of a provider.
+//
+// Node stats are the totals of the pod stats. Node counters include the usage of pods and containers which no longer
+// exist so they do not go backwards. CPU usage in nanocores is computed from the cumulative counters of consecutive
+// collections.
+//
+// Samples are collected at most once per collection interval and shared between GetStatsSummary and
+// GetMetricsResource so both report the same values.
+type StatsAggregator struct {
+	nodeName  string
+	startTime time.Time
+	samples   PodStatsSamplesFunc
+	interval  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	collected time.Time
+	summary   *statsv1alpha1.Summary
+	// containers has the previous CPU sample of each container, by pod UID and container name.
+	containers map[containerKey]cpuSample
+	// interfaces has the previous counters of each network interface, by pod UID and interface name.
+	interfaces map[interfaceKey]NetworkInterfaceSample
+	// retiredCPU is the CPU time consumed by containers which no longer exist or have restarted.
+	retiredCPU uint64
+	// retiredNetwork has the counters of pod interfaces which no longer exist or were reset, by interface name.
+	retiredNetwork map[string]NetworkInterfaceSample
+}
+
+type containerKey struct {
+	podUID    string
+	container string
+}
+
+type interfaceKey struct {
+	podUID string
+	name   string
+}
+
+type cpuSample struct {
+	time      time.Time
+	startTime time.Time
+	usage     uint64
+}
+
+// StatsAggregatorOpt sets options on the StatsAggregator.
+type StatsAggregatorOpt func(*StatsAggregator)
+
+// WithStatsCollectionInterval sets the minimum time between two collections of stats samples.
+// Requests in between are served from the last collection.
+func WithStatsCollectionInterval(d time.Duration) StatsAggregatorOpt {
+	return func(a *StatsAggregator) {
+		a.interval = d
+	}
+}
+
+// NewStatsAggregator creates a StatsAggregator for the node which collects samples using the passed in func.
+func NewStatsAggregator(nodeName string, startTime time.Time, f PodStatsSamplesFunc, opts ...StatsAggregatorOpt) *StatsAggregator {
+	a := &StatsAggregator{
+		nodeName:       nodeName,
+		startTime:      startTime,
+		samples:        f,
+		interval:       DefaultStatsCollectionInterval,
+		now:            time.Now,
+		containers:     make(map[containerKey]cpuSample),
+		interfaces:     make(map[interfaceKey]NetworkInterfaceSample),
+		retiredNetwork: make(map[string]NetworkInterfaceSample),
+	}
+	for _, o := range opts {
+		o(a)
+	}
+	return a
+}
+
+// GetStatsSummary returns the stats summary of the node and its pods.
+// It can be used as the PodStatsSummaryHandlerFunc.
+//
+// The summary is shared by all callers until the next collection and must not be modified.
+func (a *StatsAggregator) GetStatsSummary(ctx context.Context) (*statsv1alpha1.Summary, error) {
+	return a.collect(ctx)
+}
+
+// GetMetricsResource returns the resource metrics of the node, its pods and containers, see
+// MetricsResourceFromStatsSummary. It can be used as the PodMetricsResourceHandlerFunc.
+func (a *StatsAggregator) GetMetricsResource(ctx context.Context) ([]*dto.MetricFamily, error) {
+	return MetricsResourceFromStatsSummary(a.GetStatsSummary)(ctx)
+}
+
+// collect returns the summary of the last collection, collecting new samples if it is older than the interval.
+func (a *StatsAggregator) collect(ctx context.Context) (*statsv1alpha1.Summary, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if a.summary != nil && now.Sub(a.collected) < a.interval {
+		return a.summary, nil
+	}
+
+	samples, err := a.samples(ctx)
+	if err != nil {
+		if isCancelled(err) {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "error getting stats samples from provider")
+	}
+
+	a.summary = a.aggregate(samples, now)
+	a.collected = now
+	return a.summary, nil
+}
+
+// aggregate builds the summary from the samples and updates the state used to compute rates and node totals.
+func (a *StatsAggregator) aggregate(samples []PodStatsSample, now time.Time) *statsv1alpha1.Summary {
+	var (
+		containers = make(map[containerKey]cpuSample)
+		interfaces = make(map[interfaceKey]NetworkInterfaceSample)
+
+		nodeCPU       statsv1alpha1.CPUStats
+		nodeMemory    statsv1alpha1.MemoryStats
+		nodeNanoCores *uint64
+		nodeCPUUsage  = a.retiredCPU
+		nodeNetwork   = make(map[string]NetworkInterfaceSample, len(a.retiredNetwork))
+		defaultIface  string
+	)
+	for name, s := range a.retiredNetwork {
+		nodeNetwork[name] = s
+	}
+
+	summary := &statsv1alpha1.Summary{
+		Pods: make([]statsv1alpha1.PodStats, 0, len(samples)),
+	}
+	for _, ps := range samples {
+		t := ps.Time
+		if t.IsZero() {
+			t = now
+		}
+		podStats := statsv1alpha1.PodStats{
+			PodRef:    ps.PodRef,
+			StartTime: metav1.NewTime(ps.StartTime),
+			CPU:       &statsv1alpha1.CPUStats{Time: metav1.NewTime(t), UsageCoreNanoSeconds: new(uint64)},
+			Memory: &statsv1alpha1.MemoryStats{
+				Time:            metav1.NewTime(t),
+				UsageBytes:      new(uint64),
+				WorkingSetBytes: new(uint64),
+				RSSBytes:        new(uint64),
+			},
+		}
+
+		for _, cs := range ps.Containers {
+			key := containerKey{podUID: ps.PodRef.UID, container: cs.Name}
+			cur := cpuSample{time: t, startTime: cs.StartTime, usage: cs.CPUUsageCoreNanoSeconds}
+			containers[key] = cur
+
+			cpu := &statsv1alpha1.CPUStats{Time: metav1.NewTime(t), UsageCoreNanoSeconds: uint64Ptr(cur.usage)}
+			if prev, ok := a.containers[key]; ok {
+				if restarted(prev, cur) {
+					a.retiredCPU += prev.usage
+					nodeCPUUsage += prev.usage
+				} else if d := cur.time.Sub(prev.time); d > 0 {
+					cpu.UsageNanoCores = uint64Ptr(uint64(float64(cur.usage-prev.usage) / d.Seconds()))
+				}
+			}
+
+			podStats.Containers = append(podStats.Containers, statsv1alpha1.ContainerStats{
+				Name:      cs.Name,
+				StartTime: metav1.NewTime(cs.StartTime),
+				CPU:       cpu,
+				Memory: &statsv1alpha1.MemoryStats{
+					Time:            metav1.NewTime(t),
+					UsageBytes:      uint64Ptr(cs.MemoryUsageBytes),
+					WorkingSetBytes: uint64Ptr(cs.MemoryWorkingSetBytes),
+					RSSBytes:        uint64Ptr(cs.MemoryRSSBytes),
+				},
+			})
+
+			*podStats.CPU.UsageCoreNanoSeconds += cur.usage
+			if cpu.UsageNanoCores != nil {
+				podStats.CPU.UsageNanoCores = addUint64(podStats.CPU.UsageNanoCores, *cpu.UsageNanoCores)
+			}
+			*podStats.Memory.UsageBytes += cs.MemoryUsageBytes
+			*podStats.Memory.WorkingSetBytes += cs.MemoryWorkingSetBytes
+			*podStats.Memory.RSSBytes += cs.MemoryRSSBytes
+		}
+
+		if len(ps.Network) > 0 {
+			podStats.Network = &statsv1alpha1.NetworkStats{Time: metav1.NewTime(t)}
+			for _, is := range ps.Network {
+				key := interfaceKey{podUID: ps.PodRef.UID, name: is.Name}
+				interfaces[key] = is
+				if prev, ok := a.interfaces[key]; ok && (is.RxBytes < prev.RxBytes || is.TxBytes < prev.TxBytes) {
+					a.retiredNetwork[is.Name] = addInterface(a.retiredNetwork[is.Name], prev)
+					nodeNetwork[is.Name] = addInterface(nodeNetwork[is.Name], prev)
+				}
+				nodeNetwork[is.Name] = addInterface(nodeNetwork[is.Name], is)
+				podStats.Network.Interfaces = append(podStats.Network.Interfaces, interfaceStats(is))
+			}
+			podStats.Network.InterfaceStats = podStats.Network.Interfaces[0]
+			if defaultIface == "" {
+				defaultIface = ps.Network[0].Name
+			}
+		}
+
+		nodeCPUUsage += *podStats.CPU.UsageCoreNanoSeconds
+		if podStats.CPU.UsageNanoCores != nil {
+			nodeNanoCores = addUint64(nodeNanoCores, *podStats.CPU.UsageNanoCores)
+		}
+		nodeMemory.UsageBytes = addUint64(nodeMemory.UsageBytes, *podStats.Memory.UsageBytes)
+		nodeMemory.WorkingSetBytes = addUint64(nodeMemory.WorkingSetBytes, *podStats.Memory.WorkingSetBytes)
+		nodeMemory.RSSBytes = addUint64(nodeMemory.RSSBytes, *podStats.Memory.RSSBytes)
+
+		summary.Pods = append(summary.Pods, podStats)
+	}
+
+	// Containers and interfaces which are gone still count towards the node counters.
+	for key, prev := range a.containers {
+		if _, ok := containers[key]; !ok {
+			a.retiredCPU += prev.usage
+			nodeCPUUsage += prev.usage
+		}
+	}
+	for key, prev := range a.interfaces {
+		if _, ok := interfaces[key]; !ok {
+			a.retiredNetwork[key.name] = addInterface(a.retiredNetwork[key.name], prev)
+			nodeNetwork[key.name] = addInterface(nodeNetwork[key.name], prev)
+		}
+	}
+	a.containers = containers
+	a.interfaces = interfaces
+
+	nodeCPU.Time = metav1.NewTime(now)
+	nodeCPU.UsageCoreNanoSeconds = uint64Ptr(nodeCPUUsage)
+	nodeCPU.UsageNanoCores = nodeNanoCores
+	nodeMemory.Time = metav1.NewTime(now)
+	if nodeMemory.UsageBytes == nil {
+		nodeMemory.UsageBytes = new(uint64)
+		nodeMemory.WorkingSetBytes = new(uint64)
+		nodeMemory.RSSBytes = new(uint64)
+	}
+
+	summary.Node = statsv1alpha1.NodeStats{
+		NodeName:  a.nodeName,
+		StartTime: metav1.NewTime(a.startTime),
+		CPU:       &nodeCPU,
+		Memory:    &nodeMemory,
+	}
+	if len(nodeNetwork) > 0 {
+		network := &statsv1alpha1.NetworkStats{Time: metav1.NewTime(now)}
+		for _, name := range sortedKeys(nodeNetwork) {
+			stats := interfaceStats(nodeNetwork[name])
+			network.Interfaces = append(network.Interfaces, stats)
+			if name == defaultIface || defaultIface == "" && network.InterfaceStats.Name == "" {
+				network.InterfaceStats = stats
+			}
+		}
+		summary.Node.Network = network
+	}
+	return summary
+}
+
+// restarted returns whether the container was restarted between the two samples, resetting its counters.
+func restarted(prev, cur cpuSample) bool {
+	return cur.usage < prev.usage || !cur.startTime.Equal(prev.startTime)
+}
+
+func addInterface(a, b NetworkInterfaceSample) NetworkInterfaceSample {
+	return NetworkInterfaceSample{
+		Name:     b.Name,
+		RxBytes:  a.RxBytes + b.RxBytes,
+		RxErrors: a.RxErrors + b.RxErrors,
+		TxBytes:  a.TxBytes + b.TxBytes,
+		TxErrors: a.TxErrors + b.TxErrors,
+	}
+}
+
+func interfaceStats(s NetworkInterfaceSample) statsv1alpha1.InterfaceStats {
+	return statsv1alpha1.InterfaceStats{
+		Name:     s.Name,
+		RxBytes:  uint64Ptr(s.RxBytes),
+		RxErrors: uint64Ptr(s.RxErrors),
+		TxBytes:  uint64Ptr(s.TxBytes),
+		TxErrors: uint64Ptr(s.TxErrors),
+	}
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func addUint64(p *uint64, v uint64) *uint64 {
+	if p == nil {
+		return uint64Ptr(v)
+	}
+	*p += v
+	return p
+}
+
+func sortedKeys(m map[string]NetworkInterfaceSample) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
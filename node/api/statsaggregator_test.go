@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	statsv1alpha1 "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+)
+
+func TestStatsAggregator(t *testing.T) {
+	var (
+		now     = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		start   = now.Add(-time.Hour)
+		samples []PodStatsSample
+		calls   int
+	)
+	pod := func(uid string, cpu, workingSet, rx uint64) PodStatsSample {
+		return PodStatsSample{
+			PodRef:     statsv1alpha1.PodReference{Namespace: "default", Name: "pod-" + uid, UID: uid},
+			StartTime:  start,
+			Containers: []ContainerStatsSample{{Name: "app", StartTime: start, CPUUsageCoreNanoSeconds: cpu, MemoryWorkingSetBytes: workingSet}},
+			Network:    []NetworkInterfaceSample{{Name: "eth0", RxBytes: rx}},
+		}
+	}
+
+	a := NewStatsAggregator("node", start, func(context.Context) ([]PodStatsSample, error) {
+		calls++
+		return samples, nil
+	})
+	a.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	samples = []PodStatsSample{pod("a", uint64(time.Second), 100, 1000)}
+	summary, err := a.GetStatsSummary(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(summary.Node.NodeName, "node"))
+	assert.Check(t, is.Equal(*summary.Node.CPU.UsageCoreNanoSeconds, uint64(time.Second)))
+	assert.Check(t, is.Nil(summary.Node.CPU.UsageNanoCores), "no rate without a previous sample")
+	assert.Check(t, is.Equal(*summary.Node.Memory.WorkingSetBytes, uint64(100)))
+
+	// Within the collection interval the last collection is reused.
+	now = now.Add(time.Second)
+	_, err = a.GetMetricsResource(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(calls, 1))
+
+	// Half a core used over 10 seconds.
+	now = now.Add(9 * time.Second)
+	samples = []PodStatsSample{pod("a", uint64(6*time.Second), 200, 3000)}
+	summary, err = a.GetStatsSummary(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(calls, 2))
+	assert.Assert(t, summary.Node.CPU.UsageNanoCores != nil)
+	assert.Check(t, is.Equal(*summary.Node.CPU.UsageNanoCores, uint64(500000000)))
+	assert.Check(t, is.Equal(*summary.Pods[0].Containers[0].CPU.UsageNanoCores, uint64(500000000)))
+	assert.Check(t, is.Equal(*summary.Node.Network.RxBytes, uint64(3000)))
+
+	// Node counters keep the usage of pods which are gone.
+	now = now.Add(10 * time.Second)
+	samples = []PodStatsSample{pod("b", uint64(time.Second), 50, 10)}
+	summary, err = a.GetStatsSummary(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(*summary.Node.CPU.UsageCoreNanoSeconds, uint64(7*time.Second)))
+	assert.Check(t, is.Nil(summary.Node.CPU.UsageNanoCores))
+	assert.Check(t, is.Equal(*summary.Node.Memory.WorkingSetBytes, uint64(50)))
+	assert.Check(t, is.Equal(*summary.Node.Network.RxBytes, uint64(3010)))
+
+	metrics, err := a.GetMetricsResource(ctx)
+	assert.NilError(t, err)
+	values := make(map[string]float64)
+	for _, mf := range metrics {
+		for _, m := range mf.Metric {
+			if m.Counter != nil {
+				values[mf.GetName()] += m.Counter.GetValue()
+			} else {
+				values[mf.GetName()] += m.Gauge.GetValue()
+			}
+		}
+	}
+	assert.Check(t, is.Equal(values["node_cpu_usage_seconds_total"], 7.0))
+	assert.Check(t, is.Equal(values["node_memory_working_set_bytes"], 50.0))
+	assert.Check(t, is.Equal(values["container_cpu_usage_seconds_total"], 1.0))
+	assert.Check(t, is.Equal(values["pod_memory_working_set_bytes"], 50.0))
+
+	h := PodHandler(PodHandlerConfig{GetStatsSummary: a.GetStatsSummary, GetMetricsResource: a.GetMetricsResource}, false)
+	for _, path := range []string{"/stats/summary", MetricsResourceRouteSuffix} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Check(t, is.Equal(w.Code, http.StatusOK), path)
+	}
+	assert.Check(t, is.Equal(calls, 3))
+}
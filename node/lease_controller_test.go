@@ -1,13 +1,175 @@
 package node
 
 import (
+	"context"
 	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
 )
 
 func TestNotReadyError(t *testing.T) {
 	n := newNodeNotReadyError(nil)
 	assert.Assert(t, errors.Is(n, &nodeNotReadyError{}))
 }
+
+func TestLeaseStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := testclient.NewSimpleClientset()
+	var failUpdates atomic.Bool
+	c.PrependReactor("update", "leases", func(ktesting.Action) (bool, runtime.Object, error) {
+		if failUpdates.Load() {
+			return true, nil, errors.New("injected lease update failure")
+		}
+		return false, nil, nil
+	})
+
+	n, err := c.CoreV1().Nodes().Create(ctx, testNode(t), metav1.CreateOptions{})
+	assert.NilError(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	nc, err := NewNodeController(&NaiveNodeProvider{}, n, c.CoreV1().Nodes(),
+		WithNodeEventRecorder(recorder),
+		WithNodeLeaseRenewalMarginThreshold(30*time.Second),
+	)
+	assert.NilError(t, err)
+
+	clock := testingclock.NewFakeClock(time.Now())
+	lc, err := newLeaseControllerWithRenewInterval(clock, c.CoordinationV1().Leases(corev1.NamespaceNodeLease), 40, 10*time.Second, nc)
+	assert.NilError(t, err)
+	nc.leaseController = lc
+	go nc.nodePingController.Run(ctx)
+
+	status, ok := nc.LeaseStatus()
+	assert.Assert(t, ok)
+	assert.Check(t, status.LastRenewTime.IsZero())
+
+	// Create the lease, then renew it.
+	lc.sync(ctx)
+	lc.sync(ctx)
+	status, _ = nc.LeaseStatus()
+	assert.Check(t, is.Equal(status.LastRenewTime, clock.Now()))
+	assert.Check(t, is.Equal(status.ConsecutiveFailures, 0))
+	assert.Check(t, is.Equal(status.Remaining, 40*time.Second))
+	assert.Check(t, !status.Degraded)
+
+	failUpdates.Store(true)
+	clock.Step(5 * time.Second)
+	lc.sync(ctx)
+	status, _ = nc.LeaseStatus()
+	assert.Check(t, is.Equal(status.ConsecutiveFailures, 1))
+	assert.Check(t, is.Equal(status.Remaining, 35*time.Second))
+	assert.Check(t, !status.Degraded)
+
+	clock.Step(10 * time.Second)
+	lc.sync(ctx)
+	status, _ = nc.LeaseStatus()
+	assert.Check(t, is.Equal(status.ConsecutiveFailures, 2))
+	assert.Check(t, is.Equal(status.Remaining, 25*time.Second))
+	assert.Check(t, status.Degraded)
+	assert.Check(t, is.Len(nc.chLeaseStatus, 1))
+	e := <-recorder.Events
+	assert.Check(t, strings.HasPrefix(e, "Warning LeaseRenewalMarginLow"), e)
+
+	node := n.DeepCopy()
+	nc.setLeaseCondition(node)
+	assert.Assert(t, is.Len(node.Status.Conditions, 1))
+	assert.Check(t, is.Equal(node.Status.Conditions[0].Type, NodeConditionLeaseRenewalDegraded))
+	assert.Check(t, is.Equal(node.Status.Conditions[0].Status, corev1.ConditionTrue))
+
+	failUpdates.Store(false)
+	lc.sync(ctx)
+	status, _ = nc.LeaseStatus()
+	assert.Check(t, is.Equal(status.ConsecutiveFailures, 0))
+	assert.Check(t, !status.Degraded)
+	e = <-recorder.Events
+	assert.Check(t, strings.HasPrefix(e, "Normal LeaseRenewalRecovered"), e)
+
+	nc.setLeaseCondition(node)
+	assert.Assert(t, is.Len(node.Status.Conditions, 1))
+	assert.Check(t, is.Equal(node.Status.Conditions[0].Status, corev1.ConditionFalse))
+}
+
+func TestLeaseStatusAPIServerUnavailable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := testclient.NewSimpleClientset()
+	var unavailable atomic.Bool
+	c.PrependReactor("*", "leases", func(ktesting.Action) (bool, runtime.Object, error) {
+		if unavailable.Load() {
+			return true, nil, errors.New("injected API server outage")
+		}
+		return false, nil, nil
+	})
+
+	n, err := c.CoreV1().Nodes().Create(ctx, testNode(t), metav1.CreateOptions{})
+	assert.NilError(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	nc, err := NewNodeController(&NaiveNodeProvider{}, n, c.CoreV1().Nodes(),
+		WithNodeEventRecorder(recorder),
+		WithNodeLeaseRenewalMarginThreshold(30*time.Second),
+	)
+	assert.NilError(t, err)
+
+	clock := testingclock.NewFakeClock(time.Now())
+	lc, err := newLeaseControllerWithRenewInterval(clock, c.CoordinationV1().Leases(corev1.NamespaceNodeLease), 40, 10*time.Second, nc)
+	assert.NilError(t, err)
+	nc.leaseController = lc
+	go nc.nodePingController.Run(ctx)
+
+	lc.sync(ctx)
+	status, _ := nc.LeaseStatus()
+	assert.Assert(t, !status.LastRenewTime.IsZero())
+
+	// Every lease request fails, so the sync keeps retrying with backoff and never completes.
+	unavailable.Store(true)
+	go lc.Run(ctx)
+
+	deadline := time.After(10 * time.Second)
+	for {
+		clock.Step(time.Second)
+		select {
+		case e := <-recorder.Events:
+			assert.Check(t, strings.HasPrefix(e, "Warning LeaseRenewalMarginLow"), e)
+			status, _ = nc.LeaseStatus()
+			assert.Check(t, status.Degraded)
+			assert.Check(t, status.Remaining < 30*time.Second)
+			return
+		case <-deadline:
+			t.Fatal("no event while the lease could not be renewed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestLeaseServerTime(t *testing.T) {
+	lease := &coordinationv1.Lease{}
+	_, ok := leaseServerTime(lease)
+	assert.Check(t, !ok)
+
+	now := time.Now().Truncate(time.Second)
+	lease.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Time: &metav1.Time{Time: now.Add(-time.Minute)}},
+		{Time: &metav1.Time{Time: now}},
+		{},
+	}
+	serverTime, ok := leaseServerTime(lease)
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(serverTime, now))
+}
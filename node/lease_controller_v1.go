@@ -19,6 +19,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/virtual-kubelet/virtual-kubelet/log"
@@ -54,8 +55,36 @@ const (
 
 	// DefaultLeaseDuration is from upstream kubelet, where the default lease duration is 40 seconds
 	DefaultLeaseDuration = 40
+
+	// NodeConditionLeaseRenewalDegraded is the node condition which is set to true when the time left before the node
+	// lease expires falls below the configured threshold (see WithNodeLeaseRenewalMarginThreshold).
+	NodeConditionLeaseRenewalDegraded corev1.NodeConditionType = "LeaseRenewalDegraded"
+
+	// maxClockSkew is the clock skew between the local clock and the API server above which a warning is logged.
+	maxClockSkew = 5 * time.Second
+	// leaseMarginCheckInterval is how often the time left before the lease expires is checked against the renewal
+	// margin threshold, independently of renewals which can be stuck retrying while the API server is unavailable.
+	leaseMarginCheckInterval = time.Second
 )
 
+// LeaseStatus describes the health of the node lease as observed by the node controller.
+type LeaseStatus struct {
+	// LastRenewTime is the (local) time of the last successful lease renewal.
+	// It is the zero value if the lease was never renewed.
+	LastRenewTime time.Time
+	// ConsecutiveFailures is the number of lease renewals which have failed since the last successful renewal.
+	ConsecutiveFailures int
+	// Remaining is the time left before the lease expires, as of the time the status was computed.
+	// It is negative if the lease already expired.
+	Remaining time.Duration
+	// ClockSkew is the observed difference between the API server clock and the local clock when the lease was last
+	// renewed. A positive value means the API server clock is ahead of the local clock.
+	// The API server only records timestamps with second precision, so the skew is only accurate to about a second.
+	ClockSkew time.Duration
+	// Degraded is true when Remaining is below the configured renewal margin threshold.
+	Degraded bool
+}
+
 // leaseController is a v1 lease controller and responsible for maintaining a server-side lease as long as the node
 // is healthy
 type leaseController struct {
@@ -66,6 +95,9 @@ type leaseController struct {
 	nodeController       *NodeController
 	// latestLease is the latest node lease which Kubelet updated or created
 	latestLease *coordinationv1.Lease
+
+	statusMu sync.Mutex
+	status   LeaseStatus
 }
 
 // newLeaseControllerWithRenewInterval constructs and returns a v1 lease controller with a specific interval of how often to
@@ -100,6 +132,9 @@ func newLeaseControllerWithRenewInterval(
 
 // Run runs the controller
 func (c *leaseController) Run(ctx context.Context) {
+	if c.nodeController.leaseMarginThreshold > 0 {
+		go c.monitorRenewalMargin(ctx)
+	}
 	c.sync(ctx)
 	wait.UntilWithContext(ctx, c.sync, c.renewInterval)
 }
@@ -111,6 +146,11 @@ func (c *leaseController) sync(ctx context.Context) {
 	ctx, span := trace.StartSpan(ctx, "lease.sync")
 	defer span.End()
 
+	var renewed bool
+	defer func() {
+		c.observeRenewal(ctx, renewed)
+	}()
+
 	pingResult, err := c.nodeController.nodePingController.getResult(ctx)
 	if err != nil {
 		log.G(ctx).WithError(err).Error("Could not get ping status")
@@ -145,6 +185,7 @@ func (c *leaseController) sync(ctx context.Context) {
 		err := c.retryUpdateLease(ctx, node, c.newLease(ctx, node, c.latestLease))
 		if err == nil {
 			span.SetStatus(err)
+			renewed = true
 			return
 		}
 		log.G(ctx).WithError(err).Info("failed to update lease using latest lease, fallback to ensure lease")
@@ -157,8 +198,129 @@ func (c *leaseController) sync(ctx context.Context) {
 		if err := c.retryUpdateLease(ctx, node, lease); err != nil {
 			log.G(ctx).WithError(err).WithField("renewInterval", c.renewInterval).Errorf("Will retry after")
 			span.SetStatus(err)
+			return
+		}
+	}
+	renewed = lease != nil
+}
+
+// observeRenewal records the outcome of a lease sync and checks the renewal margin.
+func (c *leaseController) observeRenewal(ctx context.Context, renewed bool) {
+	if ctx.Err() != nil {
+		// The controller is shutting down, this is not a renewal failure.
+		return
+	}
+	if renewed {
+		leaseRenewalsMetric.WithLabelValues("success").Inc()
+	} else {
+		leaseRenewalsMetric.WithLabelValues("error").Inc()
+	}
+
+	c.statusMu.Lock()
+	if renewed {
+		c.status.LastRenewTime = c.clock.Now()
+		c.status.ConsecutiveFailures = 0
+		if c.latestLease != nil && c.latestLease.Spec.RenewTime != nil {
+			if serverTime, ok := leaseServerTime(c.latestLease); ok {
+				c.status.ClockSkew = serverTime.Sub(c.latestLease.Spec.RenewTime.Time.Truncate(time.Second))
+			}
+		}
+	} else {
+		c.status.ConsecutiveFailures++
+	}
+	skew := c.status.ClockSkew
+	c.statusMu.Unlock()
+
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		log.G(ctx).WithField("clockSkew", skew).Warn("Clock skew between the local clock and the API server is high, the lease may expire earlier than expected")
+	}
+	c.checkRenewalMargin(ctx)
+}
+
+// monitorRenewalMargin checks the renewal margin until the context is cancelled.
+// Syncs can keep retrying for as long as the API server is unavailable, which is when the margin matters most, so
+// the margin is not only checked once a sync completes.
+func (c *leaseController) monitorRenewalMargin(ctx context.Context) {
+	timer := c.clock.NewTimer(leaseMarginCheckInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			c.checkRenewalMargin(ctx)
+			timer.Reset(leaseMarginCheckInterval)
+		}
+	}
+}
+
+// checkRenewalMargin raises an event (and node condition) when the time left before the lease expires crosses the
+// configured threshold.
+func (c *leaseController) checkRenewalMargin(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	c.statusMu.Lock()
+	status := c.computeStatus(c.clock.Now())
+	wasDegraded := c.status.Degraded
+	c.status.Degraded = status.Degraded
+	c.statusMu.Unlock()
+
+	if status.Degraded == wasDegraded {
+		return
+	}
+	ctx = log.WithLogger(ctx, log.G(ctx).WithFields(log.Fields{
+		"consecutiveFailures": status.ConsecutiveFailures,
+		"remaining":           status.Remaining,
+		"clockSkew":           status.ClockSkew,
+	}))
+	if status.Degraded {
+		log.G(ctx).Warn("Node lease is about to expire")
+	} else {
+		log.G(ctx).Info("Node lease renewal recovered")
+	}
+	c.nodeController.leaseDegradedChanged(ctx, status)
+}
+
+// computeStatus returns the lease status as of the passed in time.
+// statusMu must be held.
+func (c *leaseController) computeStatus(now time.Time) LeaseStatus {
+	status := c.status
+	if status.LastRenewTime.IsZero() {
+		return status
+	}
+	status.Remaining = time.Duration(c.leaseDurationSeconds)*time.Second - now.Sub(status.LastRenewTime) - skewPenalty(status.ClockSkew)
+	threshold := c.nodeController.leaseMarginThreshold
+	status.Degraded = threshold > 0 && status.Remaining < threshold
+	return status
+}
+
+// getStatus returns the current lease status.
+func (c *leaseController) getStatus() LeaseStatus {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	return c.computeStatus(c.clock.Now())
+}
+
+// skewPenalty is how much earlier the lease expires from the point of view of the API server due to clock skew.
+// When the API server clock is ahead of ours, the renew time we write looks older than it is.
+func skewPenalty(skew time.Duration) time.Duration {
+	if skew > 0 {
+		return skew
+	}
+	return 0
+}
+
+// leaseServerTime returns the time at which the API server last wrote the lease, as recorded in its managed fields.
+func leaseServerTime(lease *coordinationv1.Lease) (time.Time, bool) {
+	var latest time.Time
+	for _, f := range lease.ManagedFields {
+		if f.Time != nil && f.Time.After(latest) {
+			latest = f.Time.Time
 		}
 	}
+	return latest, !latest.IsZero()
 }
 
 // backoffEnsureLease attempts to create the lease if it does not exist,
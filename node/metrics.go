@@ -0,0 +1,83 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/virtual-kubelet/virtual-kubelet/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	providerDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "provider",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of calls from the pod controller to the provider, by operation and error class.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2.5, 12),
+	}, []string{"operation", "error_class"})
+	leaseRenewalsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "node",
+		Name:      "lease_renewals_total",
+		Help:      "Number of node lease renewal attempts, by result.",
+	}, []string{"result"})
+	statusUpdatesMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "node",
+		Name:      "status_updates_total",
+		Help:      "Number of node status patches sent to the API server, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(providerDurationMetric, leaseRenewalsMetric, statusUpdatesMetric)
+}
+
+// observeProviderCall records the latency and outcome of a call to the provider.
+// It is meant to be deferred with the time the call started: `defer observeProviderCall("CreatePod", time.Now(), &err)`.
+func observeProviderCall(operation string, start time.Time, err *error) {
+	providerDurationMetric.WithLabelValues(operation, metrics.ErrorClass(*err)).Observe(time.Since(start).Seconds())
+}
+
+// instrumentedProvider records metrics for all calls to the wrapped provider.
+type instrumentedProvider struct {
+	PodLifecycleHandler
+}
+
+// instrumentedAsyncProvider is an instrumentedProvider for providers which notify about pod status changes.
+type instrumentedAsyncProvider struct {
+	*instrumentedProvider
+	PodNotifier
+}
+
+func (p *instrumentedProvider) CreatePod(ctx context.Context, pod *corev1.Pod) (err error) {
+	defer observeProviderCall("CreatePod", time.Now(), &err)
+	return p.PodLifecycleHandler.CreatePod(ctx, pod)
+}
+
+func (p *instrumentedProvider) UpdatePod(ctx context.Context, pod *corev1.Pod) (err error) {
+	defer observeProviderCall("UpdatePod", time.Now(), &err)
+	return p.PodLifecycleHandler.UpdatePod(ctx, pod)
+}
+
+func (p *instrumentedProvider) DeletePod(ctx context.Context, pod *corev1.Pod) (err error) {
+	defer observeProviderCall("DeletePod", time.Now(), &err)
+	return p.PodLifecycleHandler.DeletePod(ctx, pod)
+}
+
+func (p *instrumentedProvider) GetPod(ctx context.Context, namespace, name string) (_ *corev1.Pod, err error) {
+	defer observeProviderCall("GetPod", time.Now(), &err)
+	return p.PodLifecycleHandler.GetPod(ctx, namespace, name)
+}
+
+func (p *instrumentedProvider) GetPodStatus(ctx context.Context, namespace, name string) (_ *corev1.PodStatus, err error) {
+	defer observeProviderCall("GetPodStatus", time.Now(), &err)
+	return p.PodLifecycleHandler.GetPodStatus(ctx, namespace, name)
+}
+
+func (p *instrumentedProvider) GetPods(ctx context.Context) (_ []*corev1.Pod, err error) {
+	defer observeProviderCall("GetPods", time.Now(), &err)
+	return p.PodLifecycleHandler.GetPods(ctx)
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pkgerrors "github.com/pkg/errors"
@@ -32,6 +33,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	coordclientset "k8s.io/client-go/kubernetes/typed/coordination/v1"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/clock"
 )
@@ -80,11 +82,14 @@ type NodeProvider interface { //nolint:revive
 // underlying options, the last NodeControllerOpt will win.
 func NewNodeController(p NodeProvider, node *corev1.Node, nodes v1.NodeInterface, opts ...NodeControllerOpt) (*NodeController, error) {
 	n := &NodeController{
-		p:          p,
-		serverNode: node,
-		nodes:      nodes,
-		chReady:    make(chan struct{}),
-		chDone:     make(chan struct{}),
+		p:             p,
+		serverNode:    node,
+		nodes:         nodes,
+		chReady:       make(chan struct{}),
+		chDone:        make(chan struct{}),
+		chLeaseStatus: make(chan struct{}, 1),
+		chReconcile:   make(chan struct{}, 1),
+		clock:         clock.RealClock{},
 	}
 	for _, o := range opts {
 		if err := o(n); err != nil {
@@ -139,7 +144,7 @@ func WithNodeEnableLeaseV1WithRenewInterval(client coordclientset.LeaseInterface
 		}
 
 		leaseController, err := newLeaseControllerWithRenewInterval(
-			&clock.RealClock{},
+			n.clock,
 			client,
 			leaseDurationSeconds,
 			interval,
@@ -200,6 +205,26 @@ func WithNodeStatusUpdateErrorHandler(h ErrorHandler) NodeControllerOpt {
 	}
 }
 
+// WithNodeEventRecorder sets the event recorder used to record events about the node.
+func WithNodeEventRecorder(r record.EventRecorder) NodeControllerOpt {
+	return func(n *NodeController) error {
+		n.recorder = r
+		return nil
+	}
+}
+
+// WithNodeLeaseRenewalMarginThreshold sets the minimum amount of time that should be left before the node lease
+// expires. When the time left falls below the threshold, because lease renewals are failing, a warning event is
+// recorded for the node and the NodeConditionLeaseRenewalDegraded condition is set to true.
+//
+// This has no effect unless leases are enabled.
+func WithNodeLeaseRenewalMarginThreshold(d time.Duration) NodeControllerOpt {
+	return func(n *NodeController) error {
+		n.leaseMarginThreshold = d
+		return nil
+	}
+}
+
 // ErrorHandler is a type of function used to allow callbacks for handling errors.
 // It is expected that if a nil error is returned that the error is handled and
 // progress can continue (or a retry is possible).
@@ -216,11 +241,35 @@ type NodeController struct { //nolint:revive
 	serverNode     *corev1.Node
 	nodes          v1.NodeInterface
 
-	leaseController *leaseController
+	leaseController      *leaseController
+	leaseMarginThreshold time.Duration
+	// chLeaseStatus is used by the lease controller to signal a change in lease health
+	chLeaseStatus chan struct{}
+
+	// reconcile enables watching the node for changes made by others
+	reconcile   bool
+	chReconcile chan struct{}
+	// watchedNode is set by the node watch when reconciliation is enabled.
+	watchedNode atomic.Pointer[watchedNode]
+
+	recorder record.EventRecorder
 
 	pingInterval   time.Duration
 	statusInterval time.Duration
+	heartbeat      *HeartbeatConfig
+	heartbeatState heartbeatState
+	clock          clock.Clock
 	chStatusUpdate chan *corev1.Node
+	chNodeUpdate   chan *NodeUpdate
+	// manageTaints is set once the provider has sent taints in a NodeUpdate, from then on the node taints are
+	// reconciled along with the node status.
+	manageTaints bool
+	// taintsSynced is set once the taints of the provider node were applied, it is reset whenever they may differ
+	// from the node in the API server again so taints are not fetched and updated with every status update.
+	taintsSynced bool
+	// nodeUpdates holds the resources, labels and annotations set through NodeUpdates. Status updates replace the
+	// status and metadata of the provider node, these values are merged back on top so they are not lost.
+	nodeUpdates NodeUpdate
 
 	nodeStatusUpdateErrorHandler ErrorHandler
 
@@ -234,6 +283,8 @@ type NodeController struct { //nolint:revive
 	nodePingController *nodePingController
 	pingTimeout        *time.Duration
 
+	shutdown nodeShutdownConfig
+
 	group wait.Group
 }
 
@@ -255,6 +306,9 @@ const (
 // node status update (because some things still expect the node to be updated
 // periodically), otherwise it will only use node status update with the configured
 // ping interval.
+//
+// When the context is cancelled, the configured shutdown policy (see WithNodeDeleteOnShutdown and
+// WithNodeCordonOnShutdown) is applied to the node before Run returns.
 func (n *NodeController) Run(ctx context.Context) (retErr error) {
 	defer func() {
 		n.errMu.Lock()
@@ -267,6 +321,12 @@ func (n *NodeController) Run(ctx context.Context) (retErr error) {
 	n.p.NotifyNodeStatus(ctx, func(node *corev1.Node) {
 		n.chStatusUpdate <- node
 	})
+	n.chNodeUpdate = make(chan *NodeUpdate, 1)
+	if un, ok := n.p.(NodeUpdateNotifier); ok {
+		un.NotifyNodeUpdate(ctx, func(update *NodeUpdate) {
+			n.chNodeUpdate <- update
+		})
+	}
 
 	n.group.StartWithContext(ctx, n.nodePingController.Run)
 
@@ -274,16 +334,35 @@ func (n *NodeController) Run(ctx context.Context) (retErr error) {
 	providerNode := n.serverNode.DeepCopy()
 	n.serverNodeLock.Unlock()
 
+	if n.reconcile {
+		n.manageTaints = true
+	}
+
 	if err := n.ensureNode(ctx, providerNode); err != nil {
 		return err
 	}
 
+	if n.reconcile {
+		name := providerNode.Name
+		n.group.StartWithContext(ctx, func(ctx context.Context) {
+			n.runNodeWatch(ctx, name)
+		})
+	}
+
 	if n.leaseController != nil {
 		log.G(ctx).WithField("leaseController", n.leaseController).Debug("Starting leasecontroller")
 		n.group.StartWithContext(ctx, n.leaseController.Run)
 	}
 
-	return n.controlLoop(ctx, providerNode)
+	if err := n.controlLoop(ctx, providerNode); err != nil {
+		return err
+	}
+
+	if err := n.shutdownNode(ctx); err != nil {
+		log.G(ctx).WithError(err).Error("Error cleaning up node on shutdown")
+		return err
+	}
+	return nil
 }
 
 // Done signals to the caller when the controller is done and the control loop is exited.
@@ -310,7 +389,16 @@ func (n *NodeController) ensureNode(ctx context.Context, providerNode *corev1.No
 	}()
 
 	err = n.updateStatus(ctx, providerNode, true)
-	if err == nil || !errors.IsNotFound(err) {
+	if err == nil {
+		// The node already exists, keep its UID to detect when it is re-created.
+		n.serverNodeLock.Lock()
+		if n.serverNode != nil {
+			providerNode.ObjectMeta.UID = n.serverNode.UID
+		}
+		n.serverNodeLock.Unlock()
+		return nil
+	}
+	if !errors.IsNotFound(err) {
 		return err
 	}
 
@@ -359,7 +447,7 @@ func (n *NodeController) controlLoop(ctx context.Context, providerNode *corev1.N
 
 		var timer *time.Timer
 		ctx = span.WithField(ctx, "sleepTime", n.pingInterval)
-		timer = time.NewTimer(sleepInterval)
+		timer = time.NewTimer(n.statusUpdateDelay(sleepInterval))
 		defer timer.Stop()
 
 		select {
@@ -371,10 +459,36 @@ func (n *NodeController) controlLoop(ctx context.Context, providerNode *corev1.N
 			providerNode.Status = updated.Status
 			providerNode.ObjectMeta.Annotations = updated.Annotations
 			providerNode.ObjectMeta.Labels = updated.Labels
+			applyNodeUpdate(providerNode, &n.nodeUpdates)
 			if err := n.updateStatus(ctx, providerNode, false); err != nil {
 				log.G(ctx).WithError(err).Error("Error handling node status update")
 			}
+		case update := <-n.chNodeUpdate:
+			log.G(ctx).Debug("Received node update")
+
+			mergeNodeUpdate(&n.nodeUpdates, update)
+			if applyNodeUpdate(providerNode, update) {
+				n.manageTaints = true
+				n.taintsSynced = false
+			}
+			if err := n.updateStatus(ctx, providerNode, false); err != nil {
+				log.G(ctx).WithError(err).Error("Error handling node update")
+			}
+		case <-n.chReconcile:
+			if err := n.reconcileNode(ctx, providerNode); err != nil {
+				log.G(ctx).WithError(err).Error("Error reconciling node")
+			}
+		case <-n.chLeaseStatus:
+			log.G(ctx).Debug("Received lease status change")
+
+			if err := n.updateStatus(ctx, providerNode, false); err != nil {
+				log.G(ctx).WithError(err).Error("Error handling lease status change")
+			}
 		case <-timer.C:
+			if n.skipStatusUpdate(providerNode) {
+				log.G(ctx).Debug("Skipping node status update, nothing changed")
+				return false
+			}
 			if err := n.updateStatus(ctx, providerNode, false); err != nil {
 				log.G(ctx).WithError(err).Error("Error handling node status update")
 			}
@@ -404,6 +518,11 @@ func (n *NodeController) updateStatus(ctx context.Context, providerNode *corev1.
 		return fmt.Errorf("Not updating node status because node ping failed: %w", result.error)
 	}
 
+	defer func() {
+		n.observeStatusUpdate(providerNode, err)
+	}()
+
+	n.setLeaseCondition(providerNode)
 	updateNodeStatusHeartbeat(providerNode)
 
 	node, err := updateNodeStatus(ctx, n.nodes, providerNode)
@@ -416,16 +535,98 @@ func (n *NodeController) updateStatus(ctx context.Context, providerNode *corev1.
 		}
 
 		// This might have recreated the node, which may cause problems with our leases until a node update succeeds
+		n.taintsSynced = false
 		node, err = updateNodeStatus(ctx, n.nodes, providerNode)
 		if err != nil {
 			return err
 		}
 	}
 
+	if n.manageTaints && !n.taintsSynced {
+		if updated, taintErr := updateNodeTaints(ctx, n.nodes, providerNode); taintErr != nil {
+			err = pkgerrors.Wrap(taintErr, "error updating node taints")
+		} else {
+			node = updated
+			n.taintsSynced = true
+		}
+	}
+
 	n.serverNodeLock.Lock()
 	n.serverNode = node
 	n.serverNodeLock.Unlock()
-	return nil
+	return err
+}
+
+// LeaseStatus returns the health of the node lease.
+// The second return value is false if leases are not enabled.
+func (n *NodeController) LeaseStatus() (LeaseStatus, bool) {
+	if n.leaseController == nil {
+		return LeaseStatus{}, false
+	}
+	return n.leaseController.getStatus(), true
+}
+
+// PingStatus returns the result of the last ping of the node provider.
+// The second return value is false if the provider has not been pinged yet.
+func (n *NodeController) PingStatus() (PingStatus, bool) {
+	result := n.nodePingController.lastResult()
+	if result == nil {
+		return PingStatus{}, false
+	}
+	return PingStatus{Time: result.time, Err: result.error}, true
+}
+
+// leaseDegradedChanged is called by the lease controller whenever the lease becomes degraded or recovers.
+func (n *NodeController) leaseDegradedChanged(ctx context.Context, status LeaseStatus) {
+	n.serverNodeLock.Lock()
+	name := n.serverNode.Name
+	n.serverNodeLock.Unlock()
+
+	if status.Degraded {
+		n.recordNodeEvent(name, corev1.EventTypeWarning, "LeaseRenewalMarginLow",
+			fmt.Sprintf("Node lease expires in %s, it was last renewed at %s", status.Remaining.Round(time.Second), status.LastRenewTime.Format(time.RFC3339)))
+	} else {
+		n.recordNodeEvent(name, corev1.EventTypeNormal, "LeaseRenewalRecovered", "Node lease renewed")
+	}
+
+	select {
+	case n.chLeaseStatus <- struct{}{}:
+	default:
+	}
+}
+
+// setLeaseCondition sets the lease renewal condition on the node, if it is enabled.
+func (n *NodeController) setLeaseCondition(node *corev1.Node) {
+	if n.leaseController == nil || n.leaseMarginThreshold <= 0 {
+		return
+	}
+	status := n.leaseController.getStatus()
+
+	cond := corev1.NodeCondition{
+		Type:    NodeConditionLeaseRenewalDegraded,
+		Status:  corev1.ConditionFalse,
+		Reason:  "LeaseRenewing",
+		Message: "Node lease is being renewed",
+	}
+	if status.Degraded {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "LeaseRenewalMarginLow"
+		cond.Message = fmt.Sprintf("Node lease was last renewed at %s", status.LastRenewTime.Format(time.RFC3339))
+	}
+
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type != cond.Type {
+			continue
+		}
+		cond.LastTransitionTime = node.Status.Conditions[i].LastTransitionTime
+		if node.Status.Conditions[i].Status != cond.Status {
+			cond.LastTransitionTime = metav1.Now()
+		}
+		node.Status.Conditions[i] = cond
+		return
+	}
+	cond.LastTransitionTime = metav1.Now()
+	node.Status.Conditions = append(node.Status.Conditions, cond)
 }
 
 // Returns a copy of the server node object
@@ -629,6 +830,9 @@ func (n NaiveNodeProvider) NotifyNodeStatus(_ context.Context, _ func(*corev1.No
 type NaiveNodeProviderV2 struct {
 	notify      func(*corev1.Node)
 	updateReady chan struct{}
+
+	notifyUpdate    func(*NodeUpdate)
+	nodeUpdateReady chan struct{}
 }
 
 // Ping just implements the NodeProvider interface.
@@ -660,12 +864,31 @@ func (n *NaiveNodeProviderV2) UpdateStatus(ctx context.Context, node *corev1.Nod
 	return nil
 }
 
+// NotifyNodeUpdate implements the NodeUpdateNotifier interface.
+func (n *NaiveNodeProviderV2) NotifyNodeUpdate(_ context.Context, f func(*NodeUpdate)) {
+	n.notifyUpdate = f
+	close(n.nodeUpdateReady)
+}
+
+// UpdateNode sends a partial node update to the node controller
+func (n *NaiveNodeProviderV2) UpdateNode(ctx context.Context, update *NodeUpdate) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.nodeUpdateReady:
+	}
+
+	n.notifyUpdate(update)
+	return nil
+}
+
 // NewNaiveNodeProvider creates a new NaiveNodeProviderV2
 // You must use this to create a NaiveNodeProviderV2 if you want to be able to send node status updates to the node
 // controller.
 func NewNaiveNodeProvider() *NaiveNodeProviderV2 {
 	return &NaiveNodeProviderV2{
-		updateReady: make(chan struct{}),
+		updateReady:     make(chan struct{}),
+		nodeUpdateReady: make(chan struct{}),
 	}
 }
 
@@ -709,7 +932,7 @@ func simplestObjectMetadata(baseObjectMeta, objectMetaWithLabelsAndAnnotations *
 		if objectMetaWithLabelsAndAnnotations.Annotations != nil {
 			// We want to copy over all annotations except the special embedded ones.
 			for key := range objectMetaWithLabelsAndAnnotations.Annotations {
-				if key == virtualKubeletLastNodeAppliedNodeStatus || key == virtualKubeletLastNodeAppliedObjectMeta {
+				if key == virtualKubeletLastNodeAppliedNodeStatus || key == virtualKubeletLastNodeAppliedObjectMeta || key == virtualKubeletLastNodeAppliedTaints {
 					continue
 				}
 				ret.Annotations[key] = objectMetaWithLabelsAndAnnotations.Annotations[key]
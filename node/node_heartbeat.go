@@ -0,0 +1,160 @@
+package node
+
+import (
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Defaults for the adaptive heartbeat.
+const (
+	// DefaultHeartbeatJitterFactor spreads status updates over an additional 20% of the status update interval.
+	DefaultHeartbeatJitterFactor = 0.2
+	// DefaultHeartbeatMaxStaleness matches the interval at which the kubelet reports an unchanged node status.
+	DefaultHeartbeatMaxStaleness = 5 * time.Minute
+	// DefaultHeartbeatMaxBackoff is the longest the node controller waits between status updates when it is
+	// throttled by the API server.
+	DefaultHeartbeatMaxBackoff = 2 * time.Minute
+)
+
+// HeartbeatConfig configures the adaptive heartbeat of the node controller.
+//
+// With the adaptive heartbeat, periodic node status updates are jittered and are skipped when nothing but the
+// heartbeat timestamps would change, since the node lease already tells Kubernetes the node is alive.
+// When the API server throttles requests (HTTP 429), updates are backed off.
+type HeartbeatConfig struct {
+	// JitterFactor is the maximum fraction of the status update interval added to each interval.
+	// If this is 0, DefaultHeartbeatJitterFactor is used. Set to a negative value to disable jitter.
+	JitterFactor float64
+	// MaxStaleness is the longest time the node status may go without being updated, even if it has not changed.
+	// If this is 0, DefaultHeartbeatMaxStaleness is used.
+	MaxStaleness time.Duration
+	// MaxBackoff is the longest time to wait between status updates when the API server throttles requests.
+	// If this is 0, DefaultHeartbeatMaxBackoff is used.
+	MaxBackoff time.Duration
+}
+
+// WithNodeAdaptiveHeartbeat enables the adaptive heartbeat for periodic node status updates.
+//
+// Skipping unchanged status updates requires leases, so that part only takes effect when leases are enabled. The
+// MaxStaleness bound holds as long as the API server accepts updates, jitter and backoff never delay an update past it.
+func WithNodeAdaptiveHeartbeat(cfg HeartbeatConfig) NodeControllerOpt {
+	return func(n *NodeController) error {
+		if cfg.JitterFactor == 0 {
+			cfg.JitterFactor = DefaultHeartbeatJitterFactor
+		}
+		if cfg.MaxStaleness == 0 {
+			cfg.MaxStaleness = DefaultHeartbeatMaxStaleness
+		}
+		if cfg.MaxBackoff == 0 {
+			cfg.MaxBackoff = DefaultHeartbeatMaxBackoff
+		}
+		if cfg.MaxStaleness < 0 || cfg.MaxBackoff < 0 {
+			return pkgerrors.New("heartbeat staleness and backoff must not be negative")
+		}
+		n.heartbeat = &cfg
+		return nil
+	}
+}
+
+// heartbeatState tracks what was last written to the API server.
+// It is only accessed from the control loop.
+type heartbeatState struct {
+	lastUpdate  time.Time
+	lastApplied *corev1.Node
+	// throttled is the number of consecutive status updates rejected with a 429
+	throttled  int
+	retryAfter time.Duration
+}
+
+// statusUpdateDelay returns how long to wait before the next periodic status update.
+func (n *NodeController) statusUpdateDelay(interval time.Duration) time.Duration {
+	if n.heartbeat == nil {
+		return interval
+	}
+
+	d := interval
+	if n.heartbeat.JitterFactor > 0 {
+		d = wait.Jitter(interval, n.heartbeat.JitterFactor)
+	}
+	if n.heartbeatState.throttled == 0 {
+		return n.capStaleness(d)
+	}
+
+	for i := 0; i < n.heartbeatState.throttled && d < n.heartbeat.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d < n.heartbeatState.retryAfter {
+		d = n.heartbeatState.retryAfter
+	}
+	if d > n.heartbeat.MaxBackoff {
+		d = n.heartbeat.MaxBackoff
+	}
+	return n.capStaleness(d)
+}
+
+// capStaleness caps the delay so the next status update happens before the status goes stale. Once it is stale,
+// the delay is not capped so throttled updates keep backing off.
+func (n *NodeController) capStaleness(d time.Duration) time.Duration {
+	if n.heartbeatState.lastUpdate.IsZero() {
+		return d
+	}
+	if left := n.heartbeat.MaxStaleness - n.clock.Since(n.heartbeatState.lastUpdate); left > 0 && d > left {
+		return left
+	}
+	return d
+}
+
+// skipStatusUpdate returns true if a periodic status update can be skipped because nothing but the heartbeat would
+// change and the status is not yet stale.
+func (n *NodeController) skipStatusUpdate(providerNode *corev1.Node) bool {
+	if n.heartbeat == nil || n.leaseController == nil || n.heartbeatState.lastApplied == nil {
+		return false
+	}
+	if n.clock.Since(n.heartbeatState.lastUpdate) >= n.heartbeat.MaxStaleness {
+		return false
+	}
+
+	// The lease condition is set right before the update, make sure a change to it is not missed.
+	node := heartbeatComparable(providerNode)
+	n.setLeaseCondition(node)
+	last := n.heartbeatState.lastApplied
+	return equality.Semantic.DeepEqual(node.Labels, last.Labels) &&
+		equality.Semantic.DeepEqual(node.Annotations, last.Annotations) &&
+		equality.Semantic.DeepEqual(node.Spec.Taints, last.Spec.Taints) &&
+		equality.Semantic.DeepEqual(node.Status, last.Status)
+}
+
+// observeStatusUpdate records the outcome of a status update.
+func (n *NodeController) observeStatusUpdate(providerNode *corev1.Node, err error) {
+	statusUpdatesMetric.WithLabelValues(metrics.Result(err)).Inc()
+	if err == nil {
+		n.heartbeatState.lastUpdate = n.clock.Now()
+		n.heartbeatState.lastApplied = heartbeatComparable(providerNode)
+		n.heartbeatState.throttled = 0
+		n.heartbeatState.retryAfter = 0
+		return
+	}
+	if errors.IsTooManyRequests(err) {
+		n.heartbeatState.throttled++
+		n.heartbeatState.retryAfter = 0
+		if seconds, ok := errors.SuggestsClientDelay(err); ok {
+			n.heartbeatState.retryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+}
+
+// heartbeatComparable returns a copy of the node with all heartbeat timestamps cleared.
+func heartbeatComparable(node *corev1.Node) *corev1.Node {
+	node = node.DeepCopy()
+	for i := range node.Status.Conditions {
+		node.Status.Conditions[i].LastHeartbeatTime = metav1.Time{}
+	}
+	return node
+}
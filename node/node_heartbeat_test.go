@@ -0,0 +1,134 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestAdaptiveHeartbeatSkipsUnchangedStatus(t *testing.T) {
+	t.Run("NotStale", func(t *testing.T) {
+		patches := countStatusPatches(t, HeartbeatConfig{MaxStaleness: time.Hour})
+		// Only the initial update from registering the node is expected.
+		assert.Check(t, is.Equal(patches, 1))
+	})
+	t.Run("Stale", func(t *testing.T) {
+		patches := countStatusPatches(t, HeartbeatConfig{MaxStaleness: time.Nanosecond})
+		assert.Check(t, atLeast(patches, 5))
+	})
+}
+
+// countStatusPatches runs a node controller with a short status update interval for a little while and returns the
+// number of node status patches sent to the API server.
+func countStatusPatches(t *testing.T, cfg HeartbeatConfig) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := testclient.NewSimpleClientset()
+	interval := 5 * time.Millisecond
+	node, err := NewNodeController(&NaiveNodeProvider{}, testNode(t), c.CoreV1().Nodes(),
+		WithNodePingInterval(interval),
+		WithNodeStatusUpdateInterval(interval),
+		WithNodeEnableLeaseV1WithRenewInterval(c.CoordinationV1().Leases(corev1.NamespaceNodeLease), 40, time.Second),
+		WithNodeAdaptiveHeartbeat(cfg),
+	)
+	assert.NilError(t, err)
+
+	go node.Run(ctx) //nolint:errcheck
+
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for node to be ready")
+	case <-node.Done():
+		t.Fatalf("node.Run returned earlier than expected: %v", node.Err())
+	case <-node.Ready():
+	}
+
+	time.Sleep(50 * interval)
+	cancel()
+	<-node.Done()
+	assert.NilError(t, node.Err())
+
+	var patches int
+	for _, a := range c.Actions() {
+		if a.GetVerb() == "patch" && a.GetResource().Resource == "nodes" && a.GetSubresource() == "status" {
+			patches++
+		}
+	}
+	return patches
+}
+
+func TestAdaptiveHeartbeatBackoff(t *testing.T) {
+	n := &NodeController{clock: clock.RealClock{}}
+	assert.NilError(t, WithNodeAdaptiveHeartbeat(HeartbeatConfig{JitterFactor: -1, MaxBackoff: time.Minute})(n))
+
+	interval := 10 * time.Second
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), interval))
+
+	node := testNode(t)
+	throttled := errors.NewTooManyRequests("slow down", 0)
+	n.observeStatusUpdate(node, throttled)
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), 2*interval))
+	n.observeStatusUpdate(node, throttled)
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), 4*interval))
+	n.observeStatusUpdate(node, throttled)
+	n.observeStatusUpdate(node, throttled)
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), time.Minute))
+
+	// Other errors do not change the backoff.
+	n.observeStatusUpdate(node, errors.NewServiceUnavailable("unavailable"))
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), time.Minute))
+
+	n.observeStatusUpdate(node, nil)
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), interval))
+
+	// The delay suggested by the API server is honored.
+	n.observeStatusUpdate(node, errors.NewTooManyRequests("slow down", 45))
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), 45*time.Second))
+}
+
+func TestAdaptiveHeartbeatJitter(t *testing.T) {
+	n := &NodeController{clock: clock.RealClock{}}
+	assert.NilError(t, WithNodeAdaptiveHeartbeat(HeartbeatConfig{JitterFactor: 0.5})(n))
+
+	interval := 10 * time.Second
+	for i := 0; i < 100; i++ {
+		d := n.statusUpdateDelay(interval)
+		assert.Assert(t, d >= interval && d <= interval+interval/2, d)
+	}
+}
+
+func TestAdaptiveHeartbeatMaxStaleness(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	n := &NodeController{clock: fakeClock}
+	assert.NilError(t, WithNodeAdaptiveHeartbeat(HeartbeatConfig{JitterFactor: 0.5, MaxStaleness: time.Minute, MaxBackoff: 10 * time.Minute})(n))
+
+	interval := 30 * time.Second
+	node := testNode(t)
+	n.observeStatusUpdate(node, nil)
+	fakeClock.Step(50 * time.Second)
+
+	// Jitter does not push the update past the staleness bound.
+	for i := 0; i < 100; i++ {
+		d := n.statusUpdateDelay(interval)
+		assert.Assert(t, d <= 10*time.Second, d)
+	}
+
+	// Neither does the backoff when throttled.
+	n.observeStatusUpdate(node, errors.NewTooManyRequests("slow down", 0))
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), 10*time.Second))
+	n.observeStatusUpdate(node, errors.NewTooManyRequests("slow down", 300))
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), 10*time.Second))
+
+	// Once the status is stale, throttled updates keep backing off.
+	fakeClock.Step(10 * time.Second)
+	assert.Check(t, is.Equal(n.statusUpdateDelay(interval), 5*time.Minute))
+}
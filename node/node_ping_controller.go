@@ -26,6 +26,14 @@ type pingResult struct {
 	error error
 }
 
+// PingStatus is the result of the last ping of the node provider.
+type PingStatus struct {
+	// Time is when the ping was started. It is the zero value if the ping timed out.
+	Time time.Time
+	// Err is the error returned by the ping, or context.DeadlineExceeded if it timed out.
+	Err error
+}
+
 // newNodePingController creates a new node ping controller. pingInterval must be non-zero. Optionally, a timeout may be specfied on
 // how long to wait for the provider to respond
 func newNodePingController(node NodeProvider, pingInterval time.Duration, timeout *time.Duration) *nodePingController {
@@ -112,3 +120,12 @@ func (npc *nodePingController) getResult(ctx context.Context) (*pingResult, erro
 
 	return sub.Value().Value.(*pingResult), nil
 }
+
+// lastResult returns the current ping result without waiting. It returns nil if the first ping has not completed yet.
+func (npc *nodePingController) lastResult() *pingResult {
+	v := npc.cond.Subscribe().Value()
+	if v.Version == 0 {
+		return nil
+	}
+	return v.Value.(*pingResult)
+}
@@ -0,0 +1,241 @@
+package node
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WithNodeReconciliation makes the node controller watch the node object in Kubernetes and immediately correct
+// changes made to it by others: if the node is deleted it is re-created, and labels, annotations, taints, status
+// conditions and addresses set by virtual-kubelet which were removed or modified are restored. An event explaining the correction is recorded for
+// the node (see WithNodeEventRecorder).
+//
+// With reconciliation enabled, the taints the node was registered with are owned by virtual-kubelet and are restored
+// if removed.
+func WithNodeReconciliation() NodeControllerOpt {
+	return func(n *NodeController) error {
+		n.reconcile = true
+		return nil
+	}
+}
+
+// runNodeWatch watches the node object and signals the control loop whenever it changes.
+func (n *NodeController) runNodeWatch(ctx context.Context, name string) {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector
+			return n.nodes.List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector
+			return n.nodes.Watch(ctx, opts)
+		},
+	}
+
+	notify := func(node *corev1.Node) {
+		n.watchedNode.Store(&watchedNode{node: node})
+		select {
+		case n.chReconcile <- struct{}{}:
+		default:
+		}
+	}
+	_, informer := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: lw,
+		ObjectType:    &corev1.Node{},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if node, ok := obj.(*corev1.Node); ok {
+					notify(node)
+				}
+			},
+			UpdateFunc: func(_, obj interface{}) {
+				if node, ok := obj.(*corev1.Node); ok {
+					notify(node)
+				}
+			},
+			DeleteFunc: func(interface{}) { notify(nil) },
+		},
+	})
+	informer.Run(ctx.Done())
+}
+
+// watchedNode is the last node object seen by the node watch, node is nil once the node was deleted.
+type watchedNode struct {
+	node *corev1.Node
+}
+
+// reconcileNode compares the node in Kubernetes with the node the provider wants and corrects any drift.
+// It must only be called from the control loop.
+func (n *NodeController) reconcileNode(ctx context.Context, providerNode *corev1.Node) (retErr error) {
+	ctx, span := trace.StartSpan(ctx, "node.reconcileNode")
+	defer span.End()
+	defer func() {
+		span.SetStatus(retErr)
+	}()
+
+	// Most events are status updates, including our own heartbeats, only check the node in the API server when the
+	// watched node drifted as the watch may lag behind our own updates.
+	if w := n.watchedNode.Load(); w != nil && w.node != nil && len(nodeDrift(providerNode, w.node)) == 0 {
+		return nil
+	}
+
+	apiServerNode, err := n.nodes.Get(ctx, providerNode.Name, emptyGetOptions)
+	if errors.IsNotFound(err) {
+		return n.recreateNode(ctx, providerNode)
+	}
+	if err != nil {
+		return err
+	}
+
+	corrections := nodeDrift(providerNode, apiServerNode)
+	// Take over the node if it was deleted and created again by someone else.
+	providerNode.UID = apiServerNode.UID
+	if len(corrections) == 0 {
+		return nil
+	}
+
+	msg := "Restored node: " + strings.Join(corrections, "; ")
+	log.G(ctx).Warn(msg)
+	n.recordNodeEvent(providerNode.Name, corev1.EventTypeWarning, "NodeDriftCorrected", msg)
+	n.taintsSynced = false
+	return n.updateStatus(ctx, providerNode, false)
+}
+
+// nodeDrift describes how the node in the API server differs from the node the provider wants.
+func nodeDrift(providerNode, apiServerNode *corev1.Node) []string {
+	var corrections []string
+	// The UID is not known until the node was created or updated once.
+	if providerNode.UID != "" && apiServerNode.UID != providerNode.UID {
+		corrections = append(corrections, "node was re-created with UID "+string(apiServerNode.UID))
+	}
+	if _, ok := apiServerNode.Annotations[virtualKubeletLastNodeAppliedObjectMeta]; !ok {
+		corrections = append(corrections, "last applied configuration was removed")
+	}
+	if missing := driftedKeys(providerNode.Labels, apiServerNode.Labels); len(missing) > 0 {
+		corrections = append(corrections, "labels "+strings.Join(missing, ", ")+" were removed or modified")
+	}
+	if missing := driftedKeys(providerNode.Annotations, apiServerNode.Annotations); len(missing) > 0 {
+		corrections = append(corrections, "annotations "+strings.Join(missing, ", ")+" were removed or modified")
+	}
+	var missingTaints []corev1.Taint
+	for i := range providerNode.Spec.Taints {
+		if !taintIn(&providerNode.Spec.Taints[i], apiServerNode.Spec.Taints) {
+			missingTaints = append(missingTaints, providerNode.Spec.Taints[i])
+		}
+	}
+	if len(missingTaints) > 0 {
+		corrections = append(corrections, "taints "+taintsStringer(missingTaints).String()+" were removed")
+	}
+	if drifted := driftedConditions(providerNode.Status.Conditions, apiServerNode.Status.Conditions); len(drifted) > 0 {
+		corrections = append(corrections, "conditions "+strings.Join(drifted, ", ")+" were removed or modified")
+	}
+	if !sameAddresses(providerNode.Status.Addresses, apiServerNode.Status.Addresses) {
+		corrections = append(corrections, "addresses were modified")
+	}
+	return corrections
+}
+
+// driftedConditions returns the sorted types of the conditions in want which are missing or have a different status
+// or reason in have. Heartbeat and transition times are not compared, they change with every status update.
+func driftedConditions(want, have []corev1.NodeCondition) []string {
+	var ret []string
+	for _, wc := range want {
+		drifted := true
+		for _, hc := range have {
+			if hc.Type == wc.Type {
+				drifted = hc.Status != wc.Status || hc.Reason != wc.Reason
+				break
+			}
+		}
+		if drifted {
+			ret = append(ret, string(wc.Type))
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// sameAddresses returns whether both lists have the same addresses, in any order.
+func sameAddresses(a, b []corev1.NodeAddress) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[corev1.NodeAddress]int, len(a))
+	for _, addr := range a {
+		seen[addr]++
+	}
+	for _, addr := range b {
+		if seen[addr] == 0 {
+			return false
+		}
+		seen[addr]--
+	}
+	return true
+}
+
+// recreateNode creates the node again after it was deleted while the controller was running.
+func (n *NodeController) recreateNode(ctx context.Context, providerNode *corev1.Node) error {
+	toCreate := providerNode.DeepCopy()
+	toCreate.ResourceVersion = ""
+	toCreate.UID = ""
+	toCreate.ManagedFields = nil
+
+	node, err := n.nodes.Create(ctx, toCreate, metav1.CreateOptions{})
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			// Someone else created it in the meantime, it will be reconciled on the next event.
+			return nil
+		}
+		return pkgerrors.Wrap(err, "error re-creating node")
+	}
+	providerNode.UID = node.UID
+
+	n.serverNodeLock.Lock()
+	n.serverNode = node
+	n.serverNodeLock.Unlock()
+
+	log.G(ctx).Warn("Node was deleted, re-created it")
+	n.recordNodeEvent(node.Name, corev1.EventTypeWarning, "NodeRecreated", "Node was deleted while virtual-kubelet is running, re-created it")
+	n.taintsSynced = false
+	return n.updateStatus(ctx, providerNode, false)
+}
+
+// recordNodeEvent records an event for the node if an event recorder is configured.
+func (n *NodeController) recordNodeEvent(name, eventType, reason, msg string) {
+	if n.recorder == nil {
+		return
+	}
+	ref := &corev1.ObjectReference{
+		Kind: "Node",
+		Name: name,
+		// Node events use the node name as UID, just like the kubelet.
+		UID: types.UID(name),
+	}
+	n.recorder.Event(ref, eventType, reason, msg)
+}
+
+// driftedKeys returns the sorted keys in want which are missing or have a different value in have.
+func driftedKeys(want, have map[string]string) []string {
+	var ret []string
+	for k, v := range want {
+		if hv, ok := have[k]; !ok || hv != v {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,181 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+)
+
+func TestNodeReconciliation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := testclient.NewSimpleClientset()
+	nodes := c.CoreV1().Nodes()
+	recorder := record.NewFakeRecorder(10)
+
+	taint := corev1.Taint{Key: "virtual-kubelet.io/provider", Value: "mock", Effect: corev1.TaintEffectNoSchedule}
+	testNode := testNode(t)
+	testNode.Labels = map[string]string{"type": "virtual-kubelet"}
+	testNode.Spec.Taints = []corev1.Taint{taint}
+	name := testNode.Name
+
+	node, err := NewNodeController(&NaiveNodeProvider{}, testNode, nodes,
+		WithNodePingInterval(10*time.Millisecond),
+		WithNodeEventRecorder(recorder),
+		WithNodeReconciliation(),
+	)
+	assert.NilError(t, err)
+
+	defer func() {
+		cancel()
+		<-node.Done()
+		assert.NilError(t, node.Err())
+	}()
+
+	go node.Run(ctx) //nolint:errcheck
+
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for node to be ready")
+	case <-node.Done():
+		t.Fatalf("node.Run returned earlier than expected: %v", node.Err())
+	case <-node.Ready():
+	}
+
+	nw := makeWatch(ctx, t, nodes, name)
+	defer nw.Stop()
+
+	t.Log("Removing label and taint")
+	assert.NilError(t, retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		n, err := nodes.Get(ctx, name, emptyGetOptions)
+		if err != nil {
+			return err
+		}
+		delete(n.Labels, "type")
+		n.Labels["external"] = "value"
+		n.Spec.Taints = nil
+		_, err = nodes.Update(ctx, n, metav1.UpdateOptions{})
+		return err
+	}))
+
+	assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+		n := e.Object.(*corev1.Node)
+		return n.Labels["type"] == "virtual-kubelet" && taintIn(&taint, n.Spec.Taints)
+	}))
+	n, err := nodes.Get(ctx, name, emptyGetOptions)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n.Labels["external"], "value"))
+	e := <-recorder.Events
+	assert.Check(t, strings.HasPrefix(e, "Warning NodeDriftCorrected"), e)
+	assert.Check(t, strings.Contains(e, "labels type were removed"), e)
+
+	t.Log("Deleting node")
+	assert.NilError(t, nodes.Delete(ctx, name, metav1.DeleteOptions{}))
+	assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+		if e.Type != watch.Added {
+			return false
+		}
+		n := e.Object.(*corev1.Node)
+		return n.Labels["type"] == "virtual-kubelet" && taintIn(&taint, n.Spec.Taints)
+	}))
+	e = <-recorder.Events
+	assert.Check(t, strings.HasPrefix(e, "Warning NodeRecreated"), e)
+}
+
+func TestDriftedKeys(t *testing.T) {
+	assert.Check(t, is.Len(driftedKeys(nil, map[string]string{"a": "b"}), 0))
+	assert.Check(t, is.DeepEqual(driftedKeys(map[string]string{"a": "1", "b": "2", "c": "3"}, map[string]string{"a": "1", "b": "other"}), []string{"b", "c"}))
+}
+
+func TestNodeReconciliationExistingNode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	existing := testNode(t)
+	existing.UID = "existing-uid"
+	c := testclient.NewSimpleClientset(existing)
+	nodes := c.CoreV1().Nodes()
+	recorder := record.NewFakeRecorder(10)
+
+	testNode := testNode(t)
+	testNode.Labels = map[string]string{"type": "virtual-kubelet"}
+	node, err := NewNodeController(&NaiveNodeProvider{}, testNode, nodes,
+		WithNodePingInterval(10*time.Millisecond),
+		WithNodeEventRecorder(recorder),
+		WithNodeReconciliation(),
+	)
+	assert.NilError(t, err)
+
+	defer func() {
+		cancel()
+		<-node.Done()
+		assert.NilError(t, node.Err())
+	}()
+
+	go node.Run(ctx) //nolint:errcheck
+
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for node to be ready")
+	case <-node.Done():
+		t.Fatalf("node.Run returned earlier than expected: %v", node.Err())
+	case <-node.Ready():
+	}
+
+	// Let a few heartbeats be reconciled, none of them should be reported as a correction.
+	nw := makeWatch(ctx, t, nodes, testNode.Name)
+	defer nw.Stop()
+	for i := 0; i < 5; i++ {
+		assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+			return e.Type == watch.Modified
+		}))
+	}
+
+	n, err := nodes.Get(ctx, testNode.Name, emptyGetOptions)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(n.UID, existing.UID))
+	assert.Check(t, is.Len(recorder.Events, 0))
+}
+
+func TestNodeDriftStatus(t *testing.T) {
+	providerNode := testNode(t)
+	providerNode.Status.Conditions = []corev1.NodeCondition{
+		{Type: corev1.NodeReady, Status: corev1.ConditionTrue, Reason: "KubeletReady"},
+		{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse, Reason: "KubeletHasSufficientMemory"},
+	}
+	providerNode.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: corev1.NodeHostName, Address: "vk"},
+	}
+	withAnnotation := func(n *corev1.Node) *corev1.Node {
+		n.Annotations = map[string]string{virtualKubeletLastNodeAppliedObjectMeta: "{}"}
+		return n
+	}
+
+	apiServerNode := withAnnotation(providerNode.DeepCopy())
+	// Heartbeats, extra conditions and the order of addresses are not drift.
+	apiServerNode.Status.Conditions[0].LastHeartbeatTime = metav1.Now()
+	apiServerNode.Status.Conditions = append(apiServerNode.Status.Conditions, corev1.NodeCondition{Type: "Other", Status: corev1.ConditionTrue})
+	apiServerNode.Status.Addresses[0], apiServerNode.Status.Addresses[1] = apiServerNode.Status.Addresses[1], apiServerNode.Status.Addresses[0]
+	assert.Check(t, is.Len(nodeDrift(providerNode, apiServerNode), 0))
+
+	apiServerNode = withAnnotation(providerNode.DeepCopy())
+	apiServerNode.Status.Conditions = apiServerNode.Status.Conditions[:1]
+	apiServerNode.Status.Conditions[0].Status = corev1.ConditionUnknown
+	apiServerNode.Status.Addresses = apiServerNode.Status.Addresses[:1]
+	assert.Check(t, is.DeepEqual(nodeDrift(providerNode, apiServerNode), []string{
+		"conditions MemoryPressure, Ready were removed or modified",
+		"addresses were modified",
+	}))
+}
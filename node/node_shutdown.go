@@ -0,0 +1,246 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// NodeShutdownPolicy determines what the node controller does with the node object in Kubernetes once `Run` exits.
+type NodeShutdownPolicy string //nolint:revive
+
+const (
+	// NodeShutdownPolicyNone leaves the node and its lease in place. This is the default.
+	NodeShutdownPolicyNone NodeShutdownPolicy = "None"
+	// NodeShutdownPolicyCordon marks the node unschedulable, applies the shutdown taint, and removes the node lease so
+	// that the node is quickly considered not ready.
+	NodeShutdownPolicyCordon NodeShutdownPolicy = "Cordon"
+	// NodeShutdownPolicyDelete removes the node and its lease from Kubernetes.
+	NodeShutdownPolicyDelete NodeShutdownPolicy = "Delete"
+)
+
+const (
+	// DefaultNodeShutdownTimeout is the default amount of time the node controller spends cleaning up the node on
+	// shutdown. This does not include the time spent waiting for pods to be drained.
+	DefaultNodeShutdownTimeout = 30 * time.Second
+
+	// NodeShutdownTaintKey is the key of the taint applied to the node when it is cordoned on shutdown and no custom
+	// taint has been provided.
+	NodeShutdownTaintKey = "virtual-kubelet.io/shutdown"
+
+	drainPollInterval = 1 * time.Second
+)
+
+type nodeShutdownConfig struct {
+	policy  NodeShutdownPolicy
+	taint   *corev1.Taint
+	timeout time.Duration
+
+	pods         v1.PodInterface
+	drainTimeout time.Duration
+}
+
+// WithNodeDeleteOnShutdown deletes the node object and its lease from Kubernetes when the node controller exits.
+// The cleanup is bounded by the passed in timeout, if the timeout is 0 DefaultNodeShutdownTimeout is used.
+//
+// If pod draining is enabled with WithNodeShutdownDrain, the node is tainted first and only deleted once its pods are
+// gone or the drain timeout has passed.
+func WithNodeDeleteOnShutdown(timeout time.Duration) NodeControllerOpt {
+	return func(n *NodeController) error {
+		n.shutdown.policy = NodeShutdownPolicyDelete
+		n.shutdown.timeout = timeout
+		return nil
+	}
+}
+
+// WithNodeCordonOnShutdown marks the node as unschedulable, adds the passed in taint, and removes the node lease when
+// the node controller exits.
+// If taint is nil, a NoSchedule taint with the key NodeShutdownTaintKey is used.
+// The cleanup is bounded by the passed in timeout, if the timeout is 0 DefaultNodeShutdownTimeout is used.
+func WithNodeCordonOnShutdown(taint *corev1.Taint, timeout time.Duration) NodeControllerOpt {
+	return func(n *NodeController) error {
+		n.shutdown.policy = NodeShutdownPolicyCordon
+		n.shutdown.taint = taint
+		n.shutdown.timeout = timeout
+		return nil
+	}
+}
+
+// WithNodeShutdownDrain makes the node controller wait, for up to the passed in timeout, for pods bound to the node to
+// be removed before the shutdown policy completes.
+// The pods client must be able to list pods across all namespaces.
+//
+// While draining, the node is tainted with a NoExecute taint (unless a custom taint was passed to
+// WithNodeCordonOnShutdown) so pods are evicted by Kubernetes.
+// This has no effect unless one of WithNodeDeleteOnShutdown or WithNodeCordonOnShutdown is also set.
+func WithNodeShutdownDrain(pods v1.PodInterface, timeout time.Duration) NodeControllerOpt {
+	return func(n *NodeController) error {
+		if pods == nil {
+			return pkgerrors.New("pod client is nil")
+		}
+		if timeout <= 0 {
+			return pkgerrors.Errorf("drain timeout %s is invalid, it must be > 0", timeout)
+		}
+		n.shutdown.pods = pods
+		n.shutdown.drainTimeout = timeout
+		return nil
+	}
+}
+
+// shutdownNode applies the configured shutdown policy to the node.
+//
+// The passed in context is expected to be cancelled already (that's what triggers a shutdown), so it is only used for
+// its values. All API calls made here are bounded by the configured timeouts.
+func (n *NodeController) shutdownNode(ctx context.Context) (retErr error) {
+	if n.shutdown.policy == "" || n.shutdown.policy == NodeShutdownPolicyNone {
+		return nil
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	ctx, span := trace.StartSpan(ctx, "node.shutdown")
+	defer span.End()
+	defer func() {
+		span.SetStatus(retErr)
+	}()
+
+	node, err := n.getServerNode(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = addNodeAttributes(ctx, span, node)
+	log.G(ctx).WithField("policy", n.shutdown.policy).Info("Cleaning up node on shutdown")
+
+	timeout := n.shutdown.timeout
+	if timeout == 0 {
+		timeout = DefaultNodeShutdownTimeout
+	}
+
+	draining := n.shutdown.pods != nil
+	if draining || n.shutdown.policy == NodeShutdownPolicyCordon {
+		cordonCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := n.cordonNode(cordonCtx, node.Name, n.shutdownTaint(draining))
+		cancel()
+		if err != nil {
+			return pkgerrors.Wrap(err, "error cordoning node")
+		}
+	}
+
+	if draining {
+		drainCtx, cancel := context.WithTimeout(ctx, n.shutdown.drainTimeout)
+		err := n.waitForPodsDrained(drainCtx, node.Name)
+		cancel()
+		if err != nil {
+			// Failing to drain should not stop us from cleaning up the node, otherwise it is left behind.
+			log.G(ctx).WithError(err).Warn("Pods were not drained from node before the drain timeout")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := n.deleteLease(ctx, node.Name); err != nil {
+		return pkgerrors.Wrap(err, "error deleting node lease")
+	}
+
+	if n.shutdown.policy == NodeShutdownPolicyDelete {
+		err := n.nodes.Delete(ctx, node.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &node.UID}})
+		if err != nil && !errors.IsNotFound(err) {
+			return pkgerrors.Wrap(err, "error deleting node")
+		}
+		log.G(ctx).Info("Deleted node")
+	}
+	return nil
+}
+
+// shutdownTaint returns the taint that is applied to the node during shutdown.
+func (n *NodeController) shutdownTaint(draining bool) corev1.Taint {
+	if n.shutdown.taint != nil {
+		return *n.shutdown.taint
+	}
+	effect := corev1.TaintEffectNoSchedule
+	if draining {
+		effect = corev1.TaintEffectNoExecute
+	}
+	return corev1.Taint{Key: NodeShutdownTaintKey, Effect: effect}
+}
+
+// cordonNode marks the node unschedulable and makes sure the passed in taint is set on it.
+// Node taints are not merged by strategic merge patches, so this does a read-modify-write instead.
+func (n *NodeController) cordonNode(ctx context.Context, name string, taint corev1.Taint) error {
+	ctx, span := trace.StartSpan(ctx, "node.cordonNode")
+	defer span.End()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := n.nodes.Get(ctx, name, emptyGetOptions)
+		if err != nil {
+			return err
+		}
+
+		node.Spec.Unschedulable = true
+		found := false
+		for i := range node.Spec.Taints {
+			if node.Spec.Taints[i].MatchTaint(&taint) {
+				node.Spec.Taints[i] = taint
+				found = true
+				break
+			}
+		}
+		if !found {
+			taint.TimeAdded = &metav1.Time{Time: time.Now()}
+			node.Spec.Taints = append(node.Spec.Taints, taint)
+		}
+
+		_, err = n.nodes.Update(ctx, node, metav1.UpdateOptions{})
+		return err
+	})
+	span.SetStatus(err)
+	if err == nil {
+		log.G(ctx).WithField("taint", taintsStringer{taint}).Debug("Cordoned node")
+	}
+	return err
+}
+
+// waitForPodsDrained waits until there are no pods bound to the node or the context is done.
+func (n *NodeController) waitForPodsDrained(ctx context.Context, name string) error {
+	ctx, span := trace.StartSpan(ctx, "node.waitForPodsDrained")
+	defer span.End()
+
+	opts := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String()}
+	err := wait.PollUntilContextCancel(ctx, drainPollInterval, true, func(ctx context.Context) (bool, error) {
+		pods, err := n.shutdown.pods.List(ctx, opts)
+		if err != nil {
+			log.G(ctx).WithError(err).Warn("Error listing pods while draining node")
+			return false, nil
+		}
+		if len(pods.Items) > 0 {
+			log.G(ctx).WithField("pods", len(pods.Items)).Debug("Waiting for pods to be drained from node")
+			return false, nil
+		}
+		return true, nil
+	})
+	span.SetStatus(err)
+	return err
+}
+
+// deleteLease removes the node lease, if leases are enabled, so the node is no longer considered alive.
+func (n *NodeController) deleteLease(ctx context.Context, name string) error {
+	if n.leaseController == nil {
+		return nil
+	}
+	err := n.leaseController.leaseClient.Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	log.G(ctx).Debug("Deleted node lease")
+	return nil
+}
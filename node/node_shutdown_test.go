@@ -0,0 +1,141 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeShutdownPolicy(t *testing.T) {
+	t.Run("None", func(t *testing.T) {
+		c := testclient.NewSimpleClientset()
+		name := runAndStopNode(t, c)
+
+		_, err := c.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		assert.NilError(t, err)
+		_, err = c.CoordinationV1().Leases(corev1.NamespaceNodeLease).Get(context.Background(), name, metav1.GetOptions{})
+		assert.NilError(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := testclient.NewSimpleClientset()
+		name := runAndStopNode(t, c, WithNodeDeleteOnShutdown(5*time.Second))
+
+		_, err := c.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		assert.Assert(t, errors.IsNotFound(err), err)
+		_, err = c.CoordinationV1().Leases(corev1.NamespaceNodeLease).Get(context.Background(), name, metav1.GetOptions{})
+		assert.Assert(t, errors.IsNotFound(err), err)
+	})
+
+	t.Run("Cordon", func(t *testing.T) {
+		c := testclient.NewSimpleClientset()
+		taint := &corev1.Taint{Key: "example.com/shutdown", Effect: corev1.TaintEffectNoSchedule}
+		name := runAndStopNode(t, c, WithNodeCordonOnShutdown(taint, 5*time.Second))
+
+		n, err := c.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Check(t, n.Spec.Unschedulable)
+		assert.Assert(t, is.Len(n.Spec.Taints, 1))
+		assert.Check(t, n.Spec.Taints[0].MatchTaint(taint))
+		_, err = c.CoordinationV1().Leases(corev1.NamespaceNodeLease).Get(context.Background(), name, metav1.GetOptions{})
+		assert.Assert(t, errors.IsNotFound(err), err)
+	})
+
+	t.Run("DeleteWithDrain", func(t *testing.T) {
+		c := testclient.NewSimpleClientset()
+		pods := c.CoreV1().Pods(corev1.NamespaceAll)
+
+		pod := &corev1.Pod{}
+		pod.Name = "drained"
+		pod.Namespace = "default"
+		pod.Spec.NodeName = "testnodeshutdownpolicy/deletewithdrain"
+		_, err := c.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+		assert.NilError(t, err)
+
+		// Evict the pod once the node has been tainted, which is what the taint manager would do.
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			for ctx.Err() == nil {
+				n, err := c.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+				if err == nil && n.Spec.Unschedulable {
+					assert.Check(t, is.Len(n.Spec.Taints, 1))
+					assert.Check(t, is.Equal(n.Spec.Taints[0].Effect, corev1.TaintEffectNoExecute))
+					assert.Check(t, c.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}))
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+
+		name := runAndStopNode(t, c, WithNodeDeleteOnShutdown(5*time.Second), WithNodeShutdownDrain(pods, 10*time.Second))
+
+		_, err = c.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		assert.Assert(t, errors.IsNotFound(err), err)
+		_, err = c.CoreV1().Pods(pod.Namespace).Get(context.Background(), pod.Name, metav1.GetOptions{})
+		assert.Assert(t, errors.IsNotFound(err), err)
+	})
+}
+
+// runAndStopNode starts a node controller with leases enabled, waits for it to be ready, and then cancels it and waits
+// for it to exit. It returns the name of the node.
+func runAndStopNode(t *testing.T, c *testclient.Clientset, opts ...NodeControllerOpt) string {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interval := 10 * time.Millisecond
+	opts = append([]NodeControllerOpt{
+		WithNodePingInterval(interval),
+		WithNodeStatusUpdateInterval(interval),
+		WithNodeEnableLeaseV1WithRenewInterval(c.CoordinationV1().Leases(corev1.NamespaceNodeLease), 40, interval),
+	}, opts...)
+
+	testP := &testNodeProvider{NodeProvider: &NaiveNodeProvider{}}
+	n := testNode(t)
+	name := n.Name
+	node, err := NewNodeController(testP, n, c.CoreV1().Nodes(), opts...)
+	assert.NilError(t, err)
+
+	go node.Run(ctx) //nolint:errcheck
+
+	timer := time.NewTimer(10 * time.Second)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		t.Fatal("timeout waiting for node to be ready")
+	case <-node.Done():
+		t.Fatalf("node.Run returned earlier than expected: %v", node.Err())
+	case <-node.Ready():
+	}
+
+	// Make sure the lease has been created before shutting down.
+	leases := c.CoordinationV1().Leases(corev1.NamespaceNodeLease)
+	for {
+		if _, err := leases.Get(ctx, name, metav1.GetOptions{}); err == nil {
+			break
+		}
+		select {
+		case <-timer.C:
+			t.Fatal("timeout waiting for lease to be created")
+		case <-time.After(interval):
+		}
+	}
+
+	cancel()
+	select {
+	case <-timer.C:
+		t.Fatal("timeout waiting for node shutdown")
+	case <-node.Done():
+		assert.NilError(t, node.Err())
+	}
+	return name
+}
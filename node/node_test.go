@@ -27,6 +27,7 @@ import (
 	"gotest.tools/assert/cmp"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	watch "k8s.io/apimachinery/pkg/watch"
 	testclient "k8s.io/client-go/kubernetes/fake"
@@ -561,6 +562,208 @@ func TestManualConditionsPreserved(t *testing.T) {
 	t.Log(newNode.Status.Conditions)
 }
 
+// Are partial updates from the provider applied without dropping values managed by others?
+func TestNodeUpdateFromProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := testclient.NewSimpleClientset()
+	nodes := c.CoreV1().Nodes()
+	np := NewNaiveNodeProvider()
+
+	testNode := testNode(t)
+	testNode.Labels = map[string]string{"type": "virtual-kubelet"}
+	testNode.Spec.Taints = []corev1.Taint{{Key: "registered", Effect: corev1.TaintEffectNoSchedule}}
+	testNodeCopy := testNode.DeepCopy()
+
+	node, err := NewNodeController(np, testNode, nodes, WithNodePingInterval(10*time.Millisecond))
+	assert.NilError(t, err)
+
+	defer func() {
+		cancel()
+		<-node.Done()
+		assert.NilError(t, node.Err())
+	}()
+
+	go node.Run(ctx) //nolint:errcheck
+
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for node to be ready")
+	case <-node.Done():
+		t.Fatalf("node.Run returned earlier than expected: %v", node.Err())
+	case <-node.Ready():
+	}
+
+	// Simulate other controllers updating the node.
+	assert.NilError(t, retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		n, err := nodes.Get(ctx, testNodeCopy.Name, emptyGetOptions)
+		if err != nil {
+			return err
+		}
+		n.Labels["external"] = "value"
+		n.Spec.Taints = append(n.Spec.Taints, corev1.Taint{Key: "external", Effect: corev1.TaintEffectNoExecute})
+		n.Status.Capacity = corev1.ResourceList{"example.com/external": resource.MustParse("1")}
+		_, err = nodes.Update(ctx, n, metav1.UpdateOptions{})
+		return err
+	}))
+
+	nw := makeWatch(ctx, t, nodes, testNodeCopy.Name)
+	defer nw.Stop()
+
+	assert.NilError(t, np.UpdateNode(ctx, &NodeUpdate{
+		Capacity:    corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10"), corev1.ResourcePods: resource.MustParse("100")},
+		Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")},
+		Labels:      map[string]string{"quota": "small"},
+		Taints:      []corev1.Taint{{Key: "provider", Value: "small", Effect: corev1.TaintEffectNoSchedule}},
+	}))
+	assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+		n := e.Object.(*corev1.Node)
+		return taintIn(&corev1.Taint{Key: "provider", Effect: corev1.TaintEffectNoSchedule}, n.Spec.Taints)
+	}))
+
+	n, err := nodes.Get(ctx, testNodeCopy.Name, emptyGetOptions)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(n.Labels["quota"], "small"))
+	assert.Check(t, cmp.Equal(n.Labels["type"], "virtual-kubelet"))
+	assert.Check(t, cmp.Equal(n.Labels["external"], "value"))
+	cpu := n.Status.Capacity[corev1.ResourceCPU]
+	assert.Check(t, cmp.Equal(cpu.String(), "10"))
+	cpu = n.Status.Allocatable[corev1.ResourceCPU]
+	assert.Check(t, cmp.Equal(cpu.String(), "8"))
+	assert.Check(t, cmp.Contains(n.Status.Capacity, corev1.ResourceName("example.com/external")))
+	assert.Check(t, cmp.Len(n.Spec.Taints, 3))
+
+	// Grow the quota, drop the provider taint and label.
+	assert.NilError(t, np.UpdateNode(ctx, &NodeUpdate{
+		Capacity:        corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("20")},
+		RemoveResources: []corev1.ResourceName{corev1.ResourcePods},
+		RemoveLabels:    []string{"quota"},
+		Taints:          []corev1.Taint{},
+	}))
+	assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+		n := e.Object.(*corev1.Node)
+		return !taintIn(&corev1.Taint{Key: "provider", Effect: corev1.TaintEffectNoSchedule}, n.Spec.Taints)
+	}))
+
+	n, err = nodes.Get(ctx, testNodeCopy.Name, emptyGetOptions)
+	assert.NilError(t, err)
+	_, ok := n.Labels["quota"]
+	assert.Check(t, !ok)
+	assert.Check(t, cmp.Equal(n.Labels["external"], "value"))
+	cpu = n.Status.Capacity[corev1.ResourceCPU]
+	assert.Check(t, cmp.Equal(cpu.String(), "20"))
+	_, ok = n.Status.Capacity[corev1.ResourcePods]
+	assert.Check(t, !ok)
+	assert.Check(t, cmp.Contains(n.Status.Capacity, corev1.ResourceName("example.com/external")))
+	// The taint from registration was never applied through a node update, so it is not owned by the provider.
+	assert.Check(t, cmp.DeepEqual(n.Spec.Taints, []corev1.Taint{
+		{Key: "registered", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "external", Effect: corev1.TaintEffectNoExecute},
+	}))
+}
+
+func TestNodeUpdateKeptOnStatusUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := testclient.NewSimpleClientset()
+	nodes := c.CoreV1().Nodes()
+	np := NewNaiveNodeProvider()
+
+	testNode := testNode(t)
+	testNode.Labels = map[string]string{"type": "virtual-kubelet"}
+	testNodeCopy := testNode.DeepCopy()
+
+	node, err := NewNodeController(np, testNode, nodes, WithNodePingInterval(10*time.Millisecond))
+	assert.NilError(t, err)
+
+	defer func() {
+		cancel()
+		<-node.Done()
+		assert.NilError(t, node.Err())
+	}()
+
+	go node.Run(ctx) //nolint:errcheck
+
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for node to be ready")
+	case <-node.Done():
+		t.Fatalf("node.Run returned earlier than expected: %v", node.Err())
+	case <-node.Ready():
+	}
+
+	nw := makeWatch(ctx, t, nodes, testNodeCopy.Name)
+	defer nw.Stop()
+
+	assert.NilError(t, np.UpdateNode(ctx, &NodeUpdate{
+		Capacity: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10")},
+		Labels:   map[string]string{"quota": "small"},
+		Taints:   []corev1.Taint{{Key: "provider", Effect: corev1.TaintEffectNoSchedule}},
+	}))
+	assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+		n := e.Object.(*corev1.Node)
+		return taintIn(&corev1.Taint{Key: "provider", Effect: corev1.TaintEffectNoSchedule}, n.Spec.Taints)
+	}))
+	c.ClearActions()
+
+	// A full status update from the provider does not know about the values from the node update.
+	testNodeCopy.Status.Conditions = []corev1.NodeCondition{{Type: "Example", Status: corev1.ConditionTrue}}
+	assert.NilError(t, np.UpdateStatus(ctx, testNodeCopy))
+	assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+		n := e.Object.(*corev1.Node)
+		for _, cond := range n.Status.Conditions {
+			if cond.Type == "Example" {
+				return true
+			}
+		}
+		return false
+	}))
+
+	n, err := nodes.Get(ctx, testNodeCopy.Name, emptyGetOptions)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(n.Labels["quota"], "small"))
+	assert.Check(t, cmp.Equal(n.Labels["type"], "virtual-kubelet"))
+	cpu := n.Status.Capacity[corev1.ResourceCPU]
+	assert.Check(t, cmp.Equal(cpu.String(), "10"))
+
+	// The control loop handles updates in order, once this one is applied the status update is fully handled.
+	assert.NilError(t, np.UpdateNode(ctx, &NodeUpdate{Labels: map[string]string{"quota": "large"}}))
+	assert.NilError(t, <-waitForEvent(ctx, nw.ResultChan(), func(e watch.Event) bool {
+		return e.Object.(*corev1.Node).Labels["quota"] == "large"
+	}))
+
+	// The taints did not change, so the node is only fetched to patch its status (and once by the test above).
+	var gets, patches int
+	for _, a := range c.Actions() {
+		if a.GetResource().Resource != "nodes" {
+			continue
+		}
+		switch a.GetVerb() {
+		case "get":
+			gets++
+		case "patch":
+			patches++
+		case "update":
+			t.Errorf("unexpected node update: %v", a)
+		}
+	}
+	assert.Check(t, cmp.Equal(gets, patches+1))
+}
+
+func TestMergeTaints(t *testing.T) {
+	a := corev1.Taint{Key: "a", Effect: corev1.TaintEffectNoSchedule}
+	b := corev1.Taint{Key: "b", Effect: corev1.TaintEffectNoSchedule}
+	c := corev1.Taint{Key: "c", Effect: corev1.TaintEffectNoSchedule}
+	aNew := corev1.Taint{Key: "a", Value: "new", Effect: corev1.TaintEffectNoSchedule}
+
+	assert.Check(t, cmp.DeepEqual(mergeTaints(nil, []corev1.Taint{a}, nil), []corev1.Taint{a}))
+	assert.Check(t, cmp.DeepEqual(mergeTaints([]corev1.Taint{a}, nil, []corev1.Taint{a, b}), []corev1.Taint{b}))
+	assert.Check(t, cmp.DeepEqual(mergeTaints([]corev1.Taint{a}, []corev1.Taint{aNew}, []corev1.Taint{a, b}), []corev1.Taint{b, aNew}))
+	assert.Check(t, cmp.DeepEqual(mergeTaints([]corev1.Taint{a, c}, []corev1.Taint{c}, []corev1.Taint{b, c}), []corev1.Taint{b, c}))
+}
+
 func TestNodePingSingleInflight(t *testing.T) {
 	testCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -0,0 +1,187 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Annotation with the JSON-serialized taints last applied by virtual-kubelet. Taints are not part of the node status
+// and cannot be merged by a strategic merge patch, so they are tracked separately.
+const virtualKubeletLastNodeAppliedTaints = "virtual-kubelet.io/last-applied-taints"
+
+// NodeUpdate is a partial update of the node sent by the provider.
+//
+// Capacity, Allocatable, Labels and Annotations are merged into the values previously set by the provider; use the
+// Remove* fields to drop entries. Taints, when non-nil, replace the taints previously set by the provider, so an empty
+// (but non-nil) slice removes all of them.
+// Values which were set on the node by someone other than virtual-kubelet are always preserved.
+// Resources, labels and annotations set through a NodeUpdate are kept when the provider later sends a full node status
+// update, and take precedence over the values in it.
+type NodeUpdate struct { //nolint:revive
+	Capacity    corev1.ResourceList
+	Allocatable corev1.ResourceList
+	Labels      map[string]string
+	Annotations map[string]string
+	Taints      []corev1.Taint
+
+	// RemoveResources are removed from both the node capacity and allocatable resources.
+	RemoveResources   []corev1.ResourceName
+	RemoveLabels      []string
+	RemoveAnnotations []string
+}
+
+// NodeUpdateNotifier is used as an extension to NodeProvider to support partial updates of the node at runtime, such as
+// when the capacity of the backend changes.
+type NodeUpdateNotifier interface { //nolint:revive
+	// NotifyNodeUpdate instructs the notifier to call the passed in function whenever parts of the node change.
+	//
+	// The provided NodeUpdate is guaranteed to be used in a read-only fashion.
+	//
+	// NotifyNodeUpdate must not block the caller since it is only used to register the callback.
+	// The callback passed into `NotifyNodeUpdate` may block when called.
+	NotifyNodeUpdate(ctx context.Context, cb func(*NodeUpdate))
+}
+
+// applyNodeUpdate applies the update to the node as the provider wants it to be.
+// It returns true if taints were part of the update.
+func applyNodeUpdate(node *corev1.Node, update *NodeUpdate) bool {
+	node.Status.Capacity = mergeResources(node.Status.Capacity, update.Capacity, update.RemoveResources)
+	node.Status.Allocatable = mergeResources(node.Status.Allocatable, update.Allocatable, update.RemoveResources)
+	node.Labels = mergeStrings(node.Labels, update.Labels, update.RemoveLabels)
+	node.Annotations = mergeStrings(node.Annotations, update.Annotations, update.RemoveAnnotations)
+
+	if update.Taints == nil {
+		return false
+	}
+	node.Spec.Taints = make([]corev1.Taint, len(update.Taints))
+	for i := range update.Taints {
+		update.Taints[i].DeepCopyInto(&node.Spec.Taints[i])
+	}
+	return true
+}
+
+// mergeNodeUpdate merges the resources, labels and annotations of the update into acc, so the values set through node
+// updates can be applied again on top of a node from a status update. Removed entries are dropped from acc.
+func mergeNodeUpdate(acc, update *NodeUpdate) {
+	acc.Capacity = mergeResources(acc.Capacity, update.Capacity, update.RemoveResources)
+	acc.Allocatable = mergeResources(acc.Allocatable, update.Allocatable, update.RemoveResources)
+	acc.Labels = mergeStrings(acc.Labels, update.Labels, update.RemoveLabels)
+	acc.Annotations = mergeStrings(acc.Annotations, update.Annotations, update.RemoveAnnotations)
+}
+
+func mergeResources(base, upsert corev1.ResourceList, remove []corev1.ResourceName) corev1.ResourceList {
+	if len(upsert) == 0 && len(remove) == 0 {
+		return base
+	}
+	ret := make(corev1.ResourceList, len(base)+len(upsert))
+	for k, v := range base {
+		ret[k] = v.DeepCopy()
+	}
+	for k, v := range upsert {
+		ret[k] = v.DeepCopy()
+	}
+	for _, k := range remove {
+		delete(ret, k)
+	}
+	return ret
+}
+
+func mergeStrings(base, upsert map[string]string, remove []string) map[string]string {
+	if len(upsert) == 0 && len(remove) == 0 {
+		return base
+	}
+	ret := make(map[string]string, len(base)+len(upsert))
+	for k, v := range base {
+		ret[k] = v
+	}
+	for k, v := range upsert {
+		ret[k] = v
+	}
+	for _, k := range remove {
+		delete(ret, k)
+	}
+	return ret
+}
+
+// mergeTaints does a three-way merge of node taints.
+// Taints that were last applied by virtual-kubelet are replaced by the taints from the provider (taints are matched by
+// key and effect), all other taints on the server are preserved.
+func mergeTaints(lastApplied, fromProvider, onServer []corev1.Taint) []corev1.Taint {
+	ret := make([]corev1.Taint, 0, len(onServer)+len(fromProvider))
+	for i := range onServer {
+		if taintIn(&onServer[i], lastApplied) || taintIn(&onServer[i], fromProvider) {
+			continue
+		}
+		ret = append(ret, onServer[i])
+	}
+	return append(ret, fromProvider...)
+}
+
+func taintIn(taint *corev1.Taint, taints []corev1.Taint) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateNodeTaints applies the taints from the provider node to the node in Kubernetes, without touching taints
+// managed by others.
+func updateNodeTaints(ctx context.Context, nodes v1.NodeInterface, nodeFromProvider *corev1.Node) (_ *corev1.Node, retErr error) {
+	ctx, span := trace.StartSpan(ctx, "node.updateNodeTaints")
+	defer span.End()
+	defer func() {
+		span.SetStatus(retErr)
+	}()
+
+	lastAppliedBytes, err := json.Marshal(nodeFromProvider.Spec.Taints)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "Cannot marshal node taints from provider")
+	}
+
+	var updatedNode *corev1.Node
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		apiServerNode, err := nodes.Get(ctx, nodeFromProvider.Name, emptyGetOptions)
+		if err != nil {
+			return err
+		}
+
+		var lastApplied []corev1.Taint
+		if v, ok := apiServerNode.Annotations[virtualKubeletLastNodeAppliedTaints]; ok {
+			if err := json.Unmarshal([]byte(v), &lastApplied); err != nil {
+				return pkgerrors.Wrapf(err, "Cannot unmarshal old node taints (key: %q): %q", virtualKubeletLastNodeAppliedTaints, v)
+			}
+		}
+
+		taints := mergeTaints(lastApplied, nodeFromProvider.Spec.Taints, apiServerNode.Spec.Taints)
+		if equality.Semantic.DeepEqual(taints, apiServerNode.Spec.Taints) &&
+			apiServerNode.Annotations[virtualKubeletLastNodeAppliedTaints] == string(lastAppliedBytes) {
+			updatedNode = apiServerNode
+			return nil
+		}
+
+		apiServerNode.Spec.Taints = taints
+		if apiServerNode.Annotations == nil {
+			apiServerNode.Annotations = make(map[string]string)
+		}
+		apiServerNode.Annotations[virtualKubeletLastNodeAppliedTaints] = string(lastAppliedBytes)
+		updatedNode, err = nodes.Update(ctx, apiServerNode, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.G(ctx).WithField("node.taints", taintsStringer(updatedNode.Spec.Taints)).Debug("updated node taints in api server")
+	return updatedNode, nil
+}
@@ -0,0 +1,27 @@
+package nodeutil
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+// WithAuditLog returns a NodeOpt which writes audit events to the file at the given path as JSON lines.
+// Events are appended to the file, which is created if needed. Use "-" to write to stdout.
+//
+// The file is kept open for the lifetime of the process.
+func WithAuditLog(path string) NodeOpt {
+	return func(cfg *NodeConfig) error {
+		if path == "-" {
+			cfg.Audit = api.NewAuditLogWriter(os.Stdout)
+			return nil
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return errors.Wrap(err, "error opening audit log")
+		}
+		cfg.Audit = api.NewAuditLogWriter(f)
+		return nil
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/authenticatorfactory"
@@ -14,6 +17,7 @@ import (
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/authorization/authorizer"
 	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/server/options"
 	"k8s.io/client-go/kubernetes"
 )
@@ -72,7 +76,7 @@ func handleAuth(auth Auth, w http.ResponseWriter, r *http.Request, next http.Han
 	info, ok, err := auth.AuthenticateRequest(r)
 	if err != nil || !ok {
 		log.G(r.Context()).WithError(err).Error("Authorization error")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		api.WriteError(w, r, errdefs.Unauthorized("Unauthorized"))
 		return
 	}
 
@@ -82,6 +86,7 @@ func handleAuth(auth Auth, w http.ResponseWriter, r *http.Request, next http.Han
 	})
 
 	ctx = log.WithLogger(ctx, logger)
+	ctx = request.WithUser(ctx, info.User)
 	r = r.WithContext(ctx)
 
 	attrs := auth.GetRequestAttributes(info.User, r)
@@ -89,12 +94,15 @@ func handleAuth(auth Auth, w http.ResponseWriter, r *http.Request, next http.Han
 	decision, _, err := auth.Authorize(ctx, attrs)
 	if err != nil {
 		log.G(r.Context()).WithError(err).Error("Authorization error")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		// The error of the authorizer is only logged, it can tell more about the authorization setup than clients
+		// should know.
+		api.WriteError(w, r, errors.New("authorization error"))
 		return
 	}
 
 	if decision != authorizer.DecisionAllow {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		api.WriteError(w, r, errdefs.Forbiddenf("Forbidden (user=%s, verb=%s, resource=%s, subresource=%s)",
+			info.User.GetName(), attrs.GetVerb(), attrs.GetResource(), attrs.GetSubresource()))
 		return
 	}
 
@@ -216,6 +224,9 @@ func getSubresource(r *http.Request) string {
 		// per kubelet code: "log" to match other log subresources (pods/log, etc)
 		return "log"
 	}
+	if isSubpath(r.URL.Path, "/checkpoint") {
+		return "checkpoint"
+	}
 
 	return "proxy"
 }
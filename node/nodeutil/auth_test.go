@@ -0,0 +1,71 @@
+package nodeutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestWithAuthStatus(t *testing.T) {
+	authorize := func(user string) authorizer.AuthorizerFunc {
+		return func(_ context.Context, attrs authorizer.Attributes) (authorizer.Decision, string, error) {
+			switch attrs.GetUser().GetName() {
+			case user:
+				return authorizer.DecisionAllow, "", nil
+			case "broken":
+				return authorizer.DecisionNoOpinion, "", errors.New("webhook at https://authz.internal failed")
+			}
+			return authorizer.DecisionNoOpinion, "", nil
+		}
+	}
+	auth := &authWrapper{
+		Request: authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+			name := req.Header.Get("X-Test-User")
+			if name == "" {
+				return nil, false, nil
+			}
+			return &authenticator.Response{User: &user.DefaultInfo{Name: name}}, true, nil
+		}),
+		RequestAttributesGetter: &NodeRequestAttr{},
+		Authorizer:              authorize("alice"),
+	}
+	h := WithAuth(auth, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, tc := range []struct {
+		user   string
+		code   int
+		reason metav1.StatusReason
+	}{
+		{user: "", code: http.StatusUnauthorized, reason: metav1.StatusReasonUnauthorized},
+		{user: "bob", code: http.StatusForbidden, reason: metav1.StatusReasonForbidden},
+		{user: "broken", code: http.StatusInternalServerError, reason: metav1.StatusReasonInternalError},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/pods", nil)
+		if tc.user != "" {
+			req.Header.Set("X-Test-User", tc.user)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Check(t, is.Equal(w.Code, tc.code), tc.user)
+		assert.Check(t, is.Equal(w.Header().Get("Content-Type"), "application/json"), tc.user)
+
+		var status metav1.Status
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &status), tc.user)
+		assert.Check(t, is.Equal(status.Kind, "Status"), tc.user)
+		assert.Check(t, is.Equal(status.Code, int32(tc.code)), tc.user)
+		assert.Check(t, is.Equal(status.Reason, tc.reason), tc.user)
+		// The authorizer error is not sent to the client.
+		assert.Check(t, !strings.Contains(status.Message, "authz.internal"), status.Message)
+	}
+}
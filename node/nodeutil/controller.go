@@ -9,19 +9,24 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/node"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/server/healthz"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/certificate"
 )
 
 // Node helps manage the startup/shutdown procedure for other controllers.
@@ -45,6 +50,11 @@ type Node struct {
 	listenAddr string
 	h          http.Handler
 	tlsConfig  *tls.Config
+	certs      certificate.Manager
+	listeners  []HTTPListener
+	sessions   *api.StreamSessions
+
+	httpDrainTimeout time.Duration
 
 	workers int
 
@@ -62,32 +72,62 @@ func (n *Node) PodController() *node.PodController {
 }
 
 func (n *Node) runHTTP(ctx context.Context) (func(), error) {
-	if n.tlsConfig == nil {
+	listeners := n.listeners
+	switch {
+	case n.tlsConfig == nil:
 		log.G(ctx).Warn("TLS config not provided, not starting up http service")
-		return func() {}, nil
-	}
-	if n.h == nil {
+	case n.h == nil:
 		log.G(ctx).Debug("No http handler, not starting up http service")
-		return func() {}, nil
+	default:
+		listeners = append([]HTTPListener{{Network: "tcp", Addr: n.listenAddr, TLSConfig: n.tlsConfig, Handler: n.h}}, listeners...)
 	}
 
-	l, err := tls.Listen("tcp", n.listenAddr, n.tlsConfig)
-	if err != nil {
-		return nil, errors.Wrap(err, "error starting http listener")
+	var servers []*http.Server
+	for _, l := range listeners {
+		srv, err := l.serve(ctx)
+		if err != nil {
+			for _, srv := range servers {
+				srv.Close() //nolint:errcheck
+			}
+			return nil, err
+		}
+		servers = append(servers, srv)
 	}
+	return func() {
+		n.shutdownHTTP(ctx, servers)
+	}, nil
+}
 
-	log.G(ctx).Debug("Started TLS listener")
+// shutdownHTTP stops accepting connections and notifies the streaming sessions that the node is shutting down, then
+// waits up to the drain timeout for requests and sessions to end before closing them.
+func (n *Node) shutdownHTTP(ctx context.Context, servers []*http.Server) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), n.httpDrainTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				/* #nosec */
+				srv.Close()
+			}
+		}(srv)
+	}
 
-	srv := &http.Server{Handler: n.h, TLSConfig: n.tlsConfig, ReadHeaderTimeout: 30 * time.Second}
-	go srv.Serve(l) //nolint:errcheck
-	log.G(ctx).Debug("HTTP server running")
+	var interrupted, closed int
+	if n.sessions != nil {
+		interrupted, closed = n.sessions.Drain(ctx)
+	}
+	wg.Wait()
 
-	return func() {
-		/* #nosec */
-		srv.Close()
-		/* #nosec */
-		l.Close()
-	}, nil
+	if interrupted > 0 {
+		log.G(ctx).WithFields(log.Fields{
+			"interrupted": interrupted,
+			"closed":      closed,
+		}).Info("Interrupted streaming sessions to shut down the http server")
+	}
 }
 
 // Run starts all the underlying controllers
@@ -107,18 +147,29 @@ func (n *Node) Run(ctx context.Context) (retErr error) {
 		log.G(ctx).Debug("Started event broadcaster")
 	}
 
+	if n.certs != nil {
+		n.certs.Start()
+		defer n.certs.Stop()
+	}
+
 	cancelHTTP, err := n.runHTTP(ctx)
 	if err != nil {
 		return err
 	}
 	defer cancelHTTP()
 
-	go n.podInformerFactory.Start(ctx.Done())
-	go n.scmInformerFactory.Start(ctx.Done())
-	go n.pc.Run(ctx, n.workers) //nolint:errcheck
+	// The pod controller (and the informers backing it) get their own context so they keep running while the node
+	// controller applies its shutdown policy, which may involve waiting for pods to be removed from the node.
+	// The node controller is always stopped before the pod controller.
+	pcCtx, pcCancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer pcCancel()
+
+	go n.podInformerFactory.Start(pcCtx.Done())
+	go n.scmInformerFactory.Start(pcCtx.Done())
+	go n.pc.Run(pcCtx, n.workers) //nolint:errcheck
 
 	defer func() {
-		cancel()
+		pcCancel()
 		<-n.pc.Done()
 	}()
 
@@ -209,6 +260,9 @@ func (n *Node) Err() error {
 	}
 }
 
+// DefaultHTTPDrainTimeout is the default time to wait on shutdown for http requests and streaming sessions to end.
+const DefaultHTTPDrainTimeout = 10 * time.Second
+
 // NodeOpt is used as functional options when configuring a new node in NewNodeFromClient
 type NodeOpt func(c *NodeConfig) error
 
@@ -228,6 +282,17 @@ type NodeConfig struct {
 
 	// Set the address to listen on for the http API
 	HTTPListenAddr string
+	// Set additional listeners to serve the http API on, see WithHTTPListener.
+	HTTPListeners []HTTPListener
+	// Set how long to wait on shutdown for http requests and streaming sessions to end before closing them.
+	// Streaming sessions are notified that the node is shutting down, see api.StreamSessions.Drain.
+	HTTPDrainTimeout time.Duration
+	// Set the tracker of the streaming sessions served by the handler, used to drain them on shutdown.
+	// One is created for the routes attached by AttachProviderRoutes if this is not set.
+	StreamSessions *api.StreamSessions
+	// Set the routes served under /proxy/ by the routes attached by AttachProviderRoutes, see api.ProxyRoutes.
+	// One is created for providers implementing ProxyRoutesProvider if this is not set.
+	ProxyRoutes *api.ProxyRoutes
 	// Set a custom API handler to use.
 	// You can use this to setup, for example, authentication middleware.
 	// If one is not provided a default one will be created.
@@ -241,7 +306,13 @@ type NodeConfig struct {
 	// Enable http debugging routes
 	DebugHTTP bool
 	// Set the tls config to use for the http server
-	TLSConfig *tls.Config
+	TLSConfig *tls.Config `datapolicy:"security-key"`
+	// Request the serving certificate of the node through a CertificateSigningRequest and rotate it before it
+	// expires, see WithServingCertificateBootstrap.
+	ServingCertificateBootstrap bool
+	// Set the directory to store the bootstrapped serving certificate in.
+	// The certificate is only kept in memory when this is not set.
+	ServingCertificateDir string
 
 	// Specify the event recorder to use
 	// If this is not provided, a default one will be used.
@@ -254,7 +325,48 @@ type NodeConfig struct {
 	// Set the error handler for node status update failures
 	NodeStatusUpdateErrorHandler node.ErrorHandler
 
-	routeAttacher func(Provider, NodeConfig, corev1listers.PodLister)
+	// Set what happens to the node object in Kubernetes when the node is shut down.
+	// By default the node and its lease are left in place.
+	ShutdownPolicy node.NodeShutdownPolicy
+	// Set the maximum time to spend applying the shutdown policy.
+	// If this is not set, node.DefaultNodeShutdownTimeout is used.
+	ShutdownTimeout time.Duration
+	// Set how long to wait for pods to be evicted from the node before completing the shutdown policy.
+	// Pods are not waited for if this is not set.
+	ShutdownDrainTimeout time.Duration
+
+	// Set the minimum time that should be left before the node lease expires.
+	// A warning event is recorded and the node is marked with a condition when it falls below this value.
+	// Set to a negative value to disable.
+	LeaseRenewalMarginThreshold time.Duration
+
+	// Watch the node object and restore it when it is deleted or modified by others.
+	ReconcileNode bool
+
+	// Enable the adaptive heartbeat for periodic node status updates, see node.WithNodeAdaptiveHeartbeat.
+	// Node status updates are sent at a fixed interval if this is not set.
+	AdaptiveHeartbeat *node.HeartbeatConfig
+
+	// Set additional checks for the /healthz endpoint, see AttachHealthRoutes.
+	HealthChecks []healthz.HealthChecker
+	// Set additional checks for the /readyz endpoint, see AttachHealthRoutes.
+	ReadyChecks []healthz.HealthChecker
+	// Set additional sections to include in the /configz endpoint, such as command line options.
+	// The values are serialized to JSON. Struct fields with a `datapolicy` tag are redacted.
+	Configz map[string]interface{}
+	// Set the function to call with an audit event for each exec, attach, port-forward, run and container logs request.
+	// The user is only recorded when the handler authenticates requests, see WithAuth.
+	// See also WithAuditLog.
+	Audit api.AuditFunc
+	// Set where exec and attach sessions are recorded, see api.NewSessionRecordingDir.
+	// The ID of the recording is included in the audit event of the session.
+	SessionRecording api.SessionRecordingSink
+	// Set limits on the concurrent streams and request rates of the kubelet API, requests over a limit get a 429.
+	// Streams and rates are counted per user when the handler authenticates requests, see WithAuth.
+	RequestLimits api.RequestLimits
+
+	routeAttacher func(Provider, NodeConfig, corev1listers.PodLister, *node.PodController)
+	healthMux     api.ServeMux
 }
 
 // WithNodeConfig returns a NodeOpt which replaces the NodeConfig with the passed in value.
@@ -283,10 +395,12 @@ func WithClient(c kubernetes.Interface) NodeOpt {
 // It is up to the caller to configure auth on the HTTP handler.
 func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node, error) {
 	cfg := NodeConfig{
-		NumWorkers:           runtime.NumCPU(),
-		InformerResyncPeriod: time.Minute,
-		KubeconfigPath:       os.Getenv("KUBECONFIG"),
-		HTTPListenAddr:       ":10250",
+		NumWorkers:                  runtime.NumCPU(),
+		InformerResyncPeriod:        time.Minute,
+		LeaseRenewalMarginThreshold: node.DefaultLeaseDuration * time.Second / 2,
+		KubeconfigPath:              os.Getenv("KUBECONFIG"),
+		HTTPListenAddr:              ":10250",
+		HTTPDrainTimeout:            DefaultHTTPDrainTimeout,
 		NodeSpec: v1.Node{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: name,
@@ -320,6 +434,15 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 	if _, _, err := net.SplitHostPort(cfg.HTTPListenAddr); err != nil {
 		return nil, errors.Wrap(err, "error parsing http listen address")
 	}
+	for i := range cfg.HTTPListeners {
+		l := &cfg.HTTPListeners[i]
+		if l.Handler == nil {
+			l.Handler = cfg.Handler
+		}
+		if err := validateHTTPListener(*l); err != nil {
+			return nil, err
+		}
+	}
 
 	if cfg.Client == nil {
 		return nil, errors.New("no client provided")
@@ -352,8 +475,19 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 		return nil, errors.Wrap(err, "error creating provider")
 	}
 
-	if cfg.routeAttacher != nil {
-		cfg.routeAttacher(p, cfg, podInformer.Lister())
+	var certs certificate.Manager
+	if cfg.ServingCertificateBootstrap {
+		if cfg.TLSConfig == nil {
+			if err := WithTLSConfig()(&cfg); err != nil {
+				return nil, err
+			}
+		}
+		// The provider sets the addresses of the node when it is created.
+		certs, err = newServingCertificateManager(cfg.Client, name, append([]v1.NodeAddress(nil), cfg.NodeSpec.Status.Addresses...), cfg.ServingCertificateDir)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig.GetCertificate = getCertificateFunc(certs, cfg.TLSConfig)
 	}
 
 	var readyCb func(context.Context) error
@@ -368,14 +502,46 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 		}
 	}
 
+	var eb record.EventBroadcaster
+	if cfg.EventRecorder == nil {
+		eb = record.NewBroadcaster()
+		cfg.EventRecorder = eb.NewRecorder(scheme.Scheme, v1.EventSource{Component: path.Join(name, "pod-controller")})
+	}
+
 	nodeControllerOpts := []node.NodeControllerOpt{
 		node.WithNodeEnableLeaseV1(NodeLeaseV1Client(cfg.Client), node.DefaultLeaseDuration),
+		node.WithNodeEventRecorder(cfg.EventRecorder),
+	}
+
+	if cfg.LeaseRenewalMarginThreshold > 0 {
+		nodeControllerOpts = append(nodeControllerOpts, node.WithNodeLeaseRenewalMarginThreshold(cfg.LeaseRenewalMarginThreshold))
+	}
+
+	if cfg.ReconcileNode {
+		nodeControllerOpts = append(nodeControllerOpts, node.WithNodeReconciliation())
+	}
+
+	if cfg.AdaptiveHeartbeat != nil {
+		nodeControllerOpts = append(nodeControllerOpts, node.WithNodeAdaptiveHeartbeat(*cfg.AdaptiveHeartbeat))
 	}
 
 	if cfg.NodeStatusUpdateErrorHandler != nil {
 		nodeControllerOpts = append(nodeControllerOpts, node.WithNodeStatusUpdateErrorHandler(cfg.NodeStatusUpdateErrorHandler))
 	}
 
+	switch cfg.ShutdownPolicy {
+	case "", node.NodeShutdownPolicyNone:
+	case node.NodeShutdownPolicyCordon:
+		nodeControllerOpts = append(nodeControllerOpts, node.WithNodeCordonOnShutdown(nil, cfg.ShutdownTimeout))
+	case node.NodeShutdownPolicyDelete:
+		nodeControllerOpts = append(nodeControllerOpts, node.WithNodeDeleteOnShutdown(cfg.ShutdownTimeout))
+	default:
+		return nil, errdefs.InvalidInputf("unknown node shutdown policy %q", cfg.ShutdownPolicy)
+	}
+	if cfg.ShutdownDrainTimeout > 0 {
+		nodeControllerOpts = append(nodeControllerOpts, node.WithNodeShutdownDrain(cfg.Client.CoreV1().Pods(v1.NamespaceAll), cfg.ShutdownDrainTimeout))
+	}
+
 	nc, err := node.NewNodeController(
 		np,
 		&cfg.NodeSpec,
@@ -386,12 +552,6 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 		return nil, errors.Wrap(err, "error creating node controller")
 	}
 
-	var eb record.EventBroadcaster
-	if cfg.EventRecorder == nil {
-		eb = record.NewBroadcaster()
-		cfg.EventRecorder = eb.NewRecorder(scheme.Scheme, v1.EventSource{Component: path.Join(name, "pod-controller")})
-	}
-
 	pc, err := node.NewPodController(node.PodControllerConfig{
 		PodClient:         cfg.Client.CoreV1(),
 		EventRecorder:     cfg.EventRecorder,
@@ -405,7 +565,24 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 		return nil, errors.Wrap(err, "error creating pod controller")
 	}
 
-	return &Node{
+	if cfg.StreamSessions == nil {
+		cfg.StreamSessions = api.NewStreamSessions()
+	}
+	if pp, ok := p.(ProxyRoutesProvider); ok {
+		if cfg.ProxyRoutes == nil {
+			cfg.ProxyRoutes = api.NewProxyRoutes()
+		}
+		for name, h := range pp.ProxyRoutes() {
+			if err := cfg.ProxyRoutes.Register(name, h); err != nil {
+				return nil, errors.Wrap(err, "error registering provider proxy routes")
+			}
+		}
+	}
+	if cfg.routeAttacher != nil {
+		cfg.routeAttacher(p, cfg, podInformer.Lister(), pc)
+	}
+
+	n := &Node{
 		nc:                 nc,
 		pc:                 pc,
 		readyCb:            readyCb,
@@ -416,10 +593,21 @@ func NewNode(name string, newProvider NewProviderFunc, opts ...NodeOpt) (*Node,
 		scmInformerFactory: scmInformerFactory,
 		client:             cfg.Client,
 		tlsConfig:          cfg.TLSConfig,
+		certs:              certs,
 		h:                  cfg.Handler,
 		listenAddr:         cfg.HTTPListenAddr,
+		listeners:          cfg.HTTPListeners,
+		sessions:           cfg.StreamSessions,
+		httpDrainTimeout:   cfg.HTTPDrainTimeout,
 		workers:            cfg.NumWorkers,
-	}, nil
+	}
+
+	if cfg.healthMux != nil {
+		if err := n.installHealthRoutes(cfg.healthMux, p, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
 }
 
 func setNodeReady(n *v1.Node) {
@@ -0,0 +1,223 @@
+package nodeutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/informers"
+)
+
+// HealthCheckProvider is an optional extension to Provider to add provider specific checks to the /healthz and
+// /readyz endpoints.
+type HealthCheckProvider interface {
+	// HealthChecks returns the checks which must pass for the provider to be considered alive.
+	HealthChecks() []healthz.HealthChecker
+	// ReadyChecks returns the checks which must pass for the provider to be considered ready.
+	// Health checks are always part of the ready checks and do not need to be repeated.
+	ReadyChecks() []healthz.HealthChecker
+}
+
+// AttachHealthRoutes returns a NodeOpt which attaches the /healthz, /readyz and /configz routes to the passed in mux.
+//
+// /healthz fails when a controller has exited, the node lease expired, or the provider does not respond to pings.
+// /readyz additionally fails until the controllers are running and the informer caches are synced.
+// /configz returns the effective node configuration, see NodeConfig.Configz.
+//
+// Note this only attaches routes, you'll need to ensure to set the handler in the node config.
+func AttachHealthRoutes(mux api.ServeMux) NodeOpt {
+	return func(cfg *NodeConfig) error {
+		cfg.healthMux = mux
+		return nil
+	}
+}
+
+// installHealthRoutes installs the health and config routes for the node on the mux.
+func (n *Node) installHealthRoutes(mux api.ServeMux, p Provider, cfg NodeConfig) error {
+	checks := append(n.healthChecks(), cfg.HealthChecks...)
+	readyChecks := append(n.readyChecks(), cfg.ReadyChecks...)
+	if hp, ok := p.(HealthCheckProvider); ok {
+		checks = append(checks, hp.HealthChecks()...)
+		readyChecks = append(readyChecks, hp.ReadyChecks()...)
+	}
+
+	configz, err := json.Marshal(configzSections(cfg))
+	if err != nil {
+		return errors.Wrap(err, "error marshalling node config")
+	}
+
+	healthz.InstallPathHandler(mux, "/healthz", checks...)
+	healthz.InstallPathHandler(mux, "/readyz", append(checks, readyChecks...)...)
+	mux.Handle("/configz", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(configz) //nolint:errcheck
+	}))
+	return nil
+}
+
+// healthChecks returns the liveness checks for the node.
+func (n *Node) healthChecks() []healthz.HealthChecker {
+	return []healthz.HealthChecker{
+		healthz.PingHealthz,
+		healthz.NamedCheck("controllers", func(*http.Request) error {
+			select {
+			case <-n.nc.Done():
+				return fmt.Errorf("node controller exited: %v", n.nc.Err())
+			default:
+			}
+			select {
+			case <-n.pc.Done():
+				return fmt.Errorf("pod controller exited: %v", n.pc.Err())
+			default:
+			}
+			return nil
+		}),
+		healthz.NamedCheck("lease", func(*http.Request) error {
+			status, ok := n.nc.LeaseStatus()
+			if !ok || status.ConsecutiveFailures == 0 {
+				return nil
+			}
+			if status.LastRenewTime.IsZero() {
+				return fmt.Errorf("lease was never renewed, %d attempts failed", status.ConsecutiveFailures)
+			}
+			if status.Remaining < 0 {
+				return fmt.Errorf("lease expired, last renewed at %s", status.LastRenewTime.Format(time.RFC3339))
+			}
+			return nil
+		}),
+		healthz.NamedCheck("provider-ping", func(*http.Request) error {
+			status, ok := n.nc.PingStatus()
+			if !ok {
+				return nil
+			}
+			return status.Err
+		}),
+	}
+}
+
+// readyChecks returns the readiness checks for the node, in addition to the liveness checks.
+func (n *Node) readyChecks() []healthz.HealthChecker {
+	return []healthz.HealthChecker{
+		healthz.NamedCheck("pod-controller", func(*http.Request) error {
+			select {
+			case <-n.pc.Ready():
+				return nil
+			default:
+				return errors.New("pod controller is not ready")
+			}
+		}),
+		healthz.NamedCheck("node-controller", func(*http.Request) error {
+			select {
+			case <-n.ready:
+				return nil
+			default:
+				return errors.New("node controller is not ready")
+			}
+		}),
+		healthz.NamedCheck("informer-sync", func(*http.Request) error {
+			return informersSynced(n.podInformerFactory, n.scmInformerFactory)
+		}),
+	}
+}
+
+// informersSynced returns an error listing the informers which have not synced yet.
+func informersSynced(factories ...informers.SharedInformerFactory) error {
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	var notSynced []string
+	for _, f := range factories {
+		for t, synced := range f.WaitForCacheSync(stopCh) {
+			if !synced {
+				notSynced = append(notSynced, t.String())
+			}
+		}
+	}
+	if len(notSynced) == 0 {
+		return nil
+	}
+	sort.Strings(notSynced)
+	return fmt.Errorf("%d informers not synced: %s", len(notSynced), strings.Join(notSynced, ", "))
+}
+
+// configzSections returns the sections of the /configz response.
+func configzSections(cfg NodeConfig) map[string]interface{} {
+	sections := map[string]interface{}{
+		"nodeConfig": redactConfig(reflect.ValueOf(cfg)),
+	}
+	for k, v := range cfg.Configz {
+		sections[k] = redactConfig(reflect.ValueOf(v))
+	}
+	return sections
+}
+
+const redacted = "[REDACTED]"
+
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// redactConfig converts a config value to something which can be marshalled to JSON.
+// Values of struct fields with a `datapolicy` tag (as used by Kubernetes for sensitive data) are redacted.
+// Functions, channels and interfaces, such as clients and handlers, are not configuration and are left out.
+func redactConfig(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid, reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Interface:
+		return nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return redactConfig(v.Elem())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		switch v.Type().Elem().Kind() {
+		case reflect.Func, reflect.Chan, reflect.Interface:
+			return nil
+		}
+	case reflect.Int64:
+		if d, ok := v.Interface().(time.Duration); ok {
+			return d.String()
+		}
+	case reflect.Struct:
+		if v.Type().Implements(jsonMarshaler) || reflect.PtrTo(v.Type()).Implements(jsonMarshaler) || hasJSONTags(v.Type()) {
+			// API types know how to serialize themselves.
+			return v.Interface()
+		}
+		out := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if _, ok := f.Tag.Lookup("datapolicy"); ok {
+				if !v.Field(i).IsZero() {
+					out[f.Name] = redacted
+				}
+				continue
+			}
+			if fv := redactConfig(v.Field(i)); fv != nil {
+				out[f.Name] = fv
+			}
+		}
+		return out
+	}
+	return v.Interface()
+}
+
+func hasJSONTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("json"); ok {
+			return true
+		}
+	}
+	return false
+}
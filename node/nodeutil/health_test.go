@@ -0,0 +1,79 @@
+package nodeutil
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/node"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type healthTestProvider struct {
+	Provider
+}
+
+func (healthTestProvider) HealthChecks() []healthz.HealthChecker {
+	return []healthz.HealthChecker{healthz.NamedCheck("backend", func(*http.Request) error { return nil })}
+}
+
+func (healthTestProvider) ReadyChecks() []healthz.HealthChecker {
+	return []healthz.HealthChecker{healthz.NamedCheck("backend-ready", func(*http.Request) error {
+		return errors.New("backend is starting")
+	})}
+}
+
+func TestHealthRoutes(t *testing.T) {
+	type testOpts struct {
+		Token    string `datapolicy:"token"`
+		Interval time.Duration
+	}
+
+	mux := http.NewServeMux()
+	_, err := NewNode("test", func(ProviderConfig) (Provider, node.NodeProvider, error) {
+		return healthTestProvider{}, node.NewNaiveNodeProvider(), nil
+	}, WithClient(fake.NewSimpleClientset()), AttachHealthRoutes(mux), func(cfg *NodeConfig) error {
+		cfg.TLSConfig = &tls.Config{} //nolint:gosec
+		cfg.Configz = map[string]interface{}{"options": testOpts{Token: "secret", Interval: time.Minute}}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// The controllers are not running, so the node is alive but not ready.
+	assert.Check(t, is.Equal(get("/healthz").Code, http.StatusOK))
+	assert.Check(t, is.Equal(get("/healthz/backend").Code, http.StatusOK))
+	assert.Check(t, is.Equal(get("/readyz").Code, http.StatusInternalServerError))
+	assert.Check(t, is.Equal(get("/readyz/lease").Code, http.StatusOK))
+	assert.Check(t, is.Equal(get("/readyz/pod-controller").Code, http.StatusInternalServerError))
+	assert.Check(t, is.Equal(get("/readyz/backend-ready").Code, http.StatusInternalServerError))
+
+	w := get("/configz")
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	var configz struct {
+		NodeConfig map[string]interface{} `json:"nodeConfig"`
+		Options    map[string]interface{} `json:"options"`
+	}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &configz))
+	assert.Check(t, is.Equal(configz.NodeConfig["TLSConfig"], redacted))
+	assert.Check(t, is.Equal(configz.NodeConfig["HTTPListenAddr"], ":10250"))
+	assert.Check(t, is.Equal(configz.NodeConfig["InformerResyncPeriod"], "1m0s"))
+	_, ok := configz.NodeConfig["NodeSpec"]
+	assert.Check(t, ok)
+	_, ok = configz.NodeConfig["Client"]
+	assert.Check(t, !ok)
+	assert.Check(t, is.Equal(configz.Options["Token"], redacted))
+	assert.Check(t, is.Equal(configz.Options["Interval"], "1m0s"))
+}
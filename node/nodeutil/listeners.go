@@ -0,0 +1,189 @@
+package nodeutil
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+// HTTPListener is an additional listener to serve the http API on, see WithHTTPListener.
+type HTTPListener struct {
+	// Network is either "tcp" or "unix".
+	Network string
+	// Addr is the host:port to listen on for "tcp", or the path of the socket for "unix".
+	// A stale socket left behind at the path is replaced.
+	Addr string
+	// TLSConfig, when set, makes the listener serve TLS. Listeners are plaintext otherwise.
+	TLSConfig *tls.Config `datapolicy:"security-key"`
+	// Handler serves the requests of the listener, including its authentication and authorization.
+	// If this is not set, NodeConfig.Handler is used.
+	Handler http.Handler
+}
+
+// WithHTTPListener returns a NodeOpt which adds a listener to serve the http API on, in addition to the TLS listener
+// on NodeConfig.HTTPListenAddr.
+//
+// Each listener can have its own handler, for example to serve a read-only port (see ReadOnlyHandler), or the API
+// without authentication on a unix socket only accessible to trusted local agents.
+// Unlike the main listener, additional listeners are started whether or not a TLS config is provided.
+func WithHTTPListener(l HTTPListener) NodeOpt {
+	return func(cfg *NodeConfig) error {
+		cfg.HTTPListeners = append(cfg.HTTPListeners, l)
+		return nil
+	}
+}
+
+// readOnlyRoutes are the routes served by ReadOnlyHandler, which match the ones of the kubelet read-only port.
+var readOnlyRoutes = []string{"/pods", "/stats", "/metrics", "/healthz", "/readyz"}
+
+// ReadOnlyHandler returns an http handler which only passes GET requests to the pods, stats, metrics and health
+// routes to h, like the kubelet read-only port. Other requests get a 404.
+//
+// The handler does no authentication, h should not require it.
+func ReadOnlyHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			api.NotFound(w, req)
+			return
+		}
+		for _, r := range readOnlyRoutes {
+			if isSubpath(req.URL.Path, r) {
+				h.ServeHTTP(w, req)
+				return
+			}
+		}
+		api.NotFound(w, req)
+	})
+}
+
+func validateHTTPListener(l HTTPListener) error {
+	switch l.Network {
+	case "tcp":
+		if _, _, err := net.SplitHostPort(l.Addr); err != nil {
+			return errors.Wrapf(err, "error parsing http listen address %q", l.Addr)
+		}
+	case "unix":
+		if l.Addr == "" {
+			return errdefs.InvalidInput("unix socket listener requires a path")
+		}
+	default:
+		return errdefs.InvalidInputf("unsupported network %q for http listener on %q, expected tcp or unix", l.Network, l.Addr)
+	}
+	if l.Handler == nil {
+		return errdefs.InvalidInputf("no http handler for listener on %q", l.Addr)
+	}
+	return nil
+}
+
+// listen creates the listener, unix sockets are created only accessible by the current user.
+func (l HTTPListener) listen() (net.Listener, error) {
+	var (
+		ln  net.Listener
+		err error
+	)
+	if l.Network == "unix" {
+		ln, err = listenUnixPrivate(l.Addr)
+	} else {
+		ln, err = net.Listen(l.Network, l.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if l.TLSConfig != nil {
+		ln = tls.NewListener(ln, l.TLSConfig)
+	}
+	return ln, nil
+}
+
+// listenUnixPrivate listens on a unix socket at p which is only accessible by the current user.
+//
+// The socket is created in a new directory only accessible by the current user, next to p, and moved to p once its
+// permissions are set, so it is never reachable by others, whatever the umask.
+func listenUnixPrivate(p string) (net.Listener, error) {
+	if err := removeStaleSocket(p); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(p), ".vk")
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating directory for unix socket")
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed by unixSocketListener from its final path.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, 0600); err != nil {
+		ln.Close() //nolint:errcheck
+		return nil, errors.Wrap(err, "error setting unix socket permissions")
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		ln.Close() //nolint:errcheck
+		return nil, errors.Wrap(err, "error moving unix socket in place")
+	}
+	return &unixSocketListener{Listener: ln, path: p}, nil
+}
+
+// unixSocketListener removes the socket file when the listener is closed.
+type unixSocketListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+func (l *unixSocketListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() {
+		os.Remove(l.path) //nolint:errcheck
+	})
+	return err
+}
+
+// removeStaleSocket removes the socket at p, left behind by a previous process which did not exit cleanly.
+// Files which are not sockets are left in place.
+func removeStaleSocket(p string) error {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return errdefs.InvalidInputf("cannot listen on %s: file exists and is not a socket", p)
+	}
+	return os.Remove(p)
+}
+
+// serve starts serving the http API on the listener.
+func (l HTTPListener) serve(ctx context.Context) (*http.Server, error) {
+	ln, err := l.listen()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error starting http listener on %s %s", l.Network, l.Addr)
+	}
+
+	srv := &http.Server{Handler: l.Handler, TLSConfig: l.TLSConfig, ReadHeaderTimeout: 30 * time.Second}
+	go srv.Serve(ln) //nolint:errcheck
+	log.G(ctx).WithFields(log.Fields{
+		"network": l.Network,
+		"addr":    ln.Addr().String(),
+		"tls":     l.TLSConfig != nil,
+	}).Debug("HTTP server running")
+	return srv, nil
+}
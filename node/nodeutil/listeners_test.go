@@ -0,0 +1,117 @@
+package nodeutil
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestHTTPListeners(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "vk.sock")
+
+	// A socket left behind by a previous process is replaced.
+	stale, err := net.Listen("unix", socket)
+	assert.NilError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, req.URL.Path) //nolint:errcheck
+	})
+	n := &Node{listeners: []HTTPListener{
+		{Network: "unix", Addr: socket, Handler: h},
+		{Network: "tcp", Addr: "127.0.0.1:0", Handler: ReadOnlyHandler(h)},
+	}}
+	for _, l := range n.listeners {
+		assert.NilError(t, validateHTTPListener(l))
+	}
+
+	// Without a TLS config for the main listener only the additional listeners are started.
+	cancel, err := n.runHTTP(context.Background())
+	assert.NilError(t, err)
+	defer cancel()
+
+	fi, err := os.Stat(socket)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(fi.Mode().Perm(), os.FileMode(0600)))
+	// The socket is created in a private directory which is removed once it is moved in place.
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(entries, 1))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Post("http://localhost/exec/default/web/app", "", nil)
+	assert.NilError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(resp.StatusCode, http.StatusOK))
+	assert.Check(t, is.Equal(string(b), "/exec/default/web/app"))
+
+	cancel()
+	_, err = os.Stat(socket)
+	assert.Check(t, os.IsNotExist(err), "the socket is removed when the listener is closed")
+
+	// Files which are not sockets are not replaced.
+	writeTestFile(t, socket, []byte("data"))
+	_, err = (&Node{listeners: n.listeners[:1]}).runHTTP(context.Background())
+	assert.Check(t, errdefs.IsInvalidInput(err), "%v", err)
+
+	assert.Check(t, is.ErrorContains(validateHTTPListener(HTTPListener{Network: "udp", Addr: ":10255", Handler: h}), `unsupported network "udp"`))
+	assert.Check(t, is.ErrorContains(validateHTTPListener(HTTPListener{Network: "tcp", Addr: "10255", Handler: h}), "error parsing"))
+	assert.Check(t, is.ErrorContains(validateHTTPListener(HTTPListener{Network: "unix", Addr: socket}), "no http handler"))
+}
+
+func TestReadOnlyHandler(t *testing.T) {
+	dir := t.TempDir()
+	h := ReadOnlyHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	l := HTTPListener{Network: "unix", Addr: filepath.Join(dir, "ro.sock"), Handler: h}
+	srv, err := l.serve(context.Background())
+	assert.NilError(t, err)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", l.Addr)
+		},
+	}}
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/pods", http.StatusOK},
+		{http.MethodGet, "/stats/summary", http.StatusOK},
+		{http.MethodGet, "/metrics/resource", http.StatusOK},
+		{http.MethodGet, "/metrics", http.StatusOK},
+		{http.MethodGet, "/healthz", http.StatusOK},
+		{http.MethodGet, "/readyz", http.StatusOK},
+		{http.MethodGet, "/podsx", http.StatusNotFound},
+		{http.MethodGet, "/configz", http.StatusNotFound},
+		{http.MethodGet, "/runningpods/", http.StatusNotFound},
+		{http.MethodGet, "/containerLogs/default/web/app", http.StatusNotFound},
+		{http.MethodPost, "/exec/default/web/app", http.StatusNotFound},
+		{http.MethodPost, "/pods", http.StatusNotFound},
+	} {
+		req, err := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+		assert.NilError(t, err)
+		resp, err := client.Do(req)
+		assert.NilError(t, err)
+		resp.Body.Close()
+		assert.Check(t, is.Equal(resp.StatusCode, tc.code), "%s %s", tc.method, tc.path)
+	}
+}
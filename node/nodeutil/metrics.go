@@ -0,0 +1,30 @@
+package nodeutil
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/virtual-kubelet/virtual-kubelet/internal/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+// MetricsRoute is the route the metrics of virtual-kubelet itself are served on.
+const MetricsRoute = "/metrics"
+
+// MetricsHandler returns an http handler serving the metrics virtual-kubelet collects about itself (work queues,
+// provider calls, node lease and status updates) in the Prometheus format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})
+}
+
+// AttachMetricsRoutes returns a NodeOpt which attaches the /metrics route to the passed in mux.
+// The route is served wherever the mux is: on the main listener it is authorized as the nodes/metrics subresource,
+// like the kubelet, and it is also part of the routes passed through by ReadOnlyHandler.
+//
+// Note this only attaches routes, you'll need to ensure to set the handler in the node config.
+func AttachMetricsRoutes(mux api.ServeMux) NodeOpt {
+	return func(cfg *NodeConfig) error {
+		mux.Handle(MetricsRoute, MetricsHandler())
+		return nil
+	}
+}
@@ -0,0 +1,21 @@
+package nodeutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestMetricsRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	assert.NilError(t, AttachMetricsRoutes(mux)(&NodeConfig{}))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, MetricsRoute, nil))
+	assert.Check(t, is.Equal(w.Code, http.StatusOK))
+	assert.Check(t, is.Contains(w.Header().Get("Content-Type"), "text/plain"))
+	assert.Check(t, is.Contains(w.Body.String(), "go_goroutines"))
+}
@@ -3,12 +3,16 @@ package nodeutil
 import (
 	"context"
 	"io"
+	"net/http"
+	"time"
 
 	dto "github.com/prometheus/client_model/go"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/node"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	statsv1alpha1 "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
 )
@@ -36,12 +40,52 @@ type Provider interface {
 	GetStatsSummary(context.Context) (*statsv1alpha1.Summary, error)
 
 	// GetMetricsResource gets the metrics for the node, including running pods
+	// Providers which only have stats can return an errdefs.NotImplemented error, the routes attached by
+	// AttachProviderRoutes then derive the metrics from GetStatsSummary with api.MetricsResourceFromStatsSummary.
 	GetMetricsResource(context.Context) ([]*dto.MetricFamily, error)
 
 	// PortForward forwards a local port to a port on the pod
 	PortForward(ctx context.Context, namespace, pod string, port int32, stream io.ReadWriteCloser) error
 }
 
+// LogRecordsProvider is an optional extension to Provider.
+// When implemented, container logs are served from the log records of the provider and the log options (tail, since,
+// limit and timestamps) are applied by virtual-kubelet instead of by the provider.
+type LogRecordsProvider interface {
+	// GetContainerLogRecords retrieves the log records of a container by name from the provider.
+	GetContainerLogRecords(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (api.ContainerLogRecordReader, error)
+}
+
+// CheckpointProvider is an optional extension to Provider to support checkpointing containers.
+type CheckpointProvider interface {
+	// CheckpointContainer creates a checkpoint of a container by name, returning either the checkpoint archive or
+	// where it was stored.
+	CheckpointContainer(ctx context.Context, namespace, podName, containerName string, opts api.ContainerCheckpointOpts) (*api.ContainerCheckpoint, error)
+}
+
+// PortForwardStreamProvider is an optional extension to Provider to forward UDP ports and unix sockets of pods as
+// well as TCP ports. When implemented, it is used instead of PortForward.
+type PortForwardStreamProvider interface {
+	// PortForwardStream forwards a local port to a port or socket on the pod, see api.PortForwardStreamHandlerFunc.
+	PortForwardStream(ctx context.Context, namespace, pod string, target api.PortForwardTarget, stream io.ReadWriteCloser) error
+}
+
+// StatsSamplesProvider is an optional extension to Provider.
+// When implemented, /stats/summary and /metrics/resource are built from the resource usage samples of the provider
+// by an api.StatsAggregator, which computes the node totals and rates, instead of using GetStatsSummary and
+// GetMetricsResource.
+type StatsSamplesProvider interface {
+	// GetStatsSamples gets the resource usage samples of the running pods.
+	GetStatsSamples(context.Context) ([]api.PodStatsSample, error)
+}
+
+// ProxyRoutesProvider is an optional extension to Provider to serve provider specific endpoints, such as
+// diagnostics, under /proxy/<name>/ on the node, see api.ProxyRoutes.
+type ProxyRoutesProvider interface {
+	// ProxyRoutes returns the handlers to serve by name, names must be DNS labels.
+	ProxyRoutes() map[string]http.Handler
+}
+
 // ProviderConfig holds objects created by NewNodeFromClient that a provider may need to bootstrap itself.
 type ProviderConfig struct {
 	Pods       corev1listers.PodLister
@@ -63,22 +107,63 @@ type NewProviderFunc func(ProviderConfig) (Provider, node.NodeProvider, error)
 // Note this only attaches routes, you'll need to ensure to set the handler in the node config.
 func AttachProviderRoutes(mux api.ServeMux) NodeOpt {
 	return func(cfg *NodeConfig) error {
-		cfg.routeAttacher = func(p Provider, cfg NodeConfig, pods corev1listers.PodLister) {
+		cfg.routeAttacher = func(p Provider, cfg NodeConfig, pods corev1listers.PodLister, pc *node.PodController) {
+			var logRecords api.ContainerLogRecordsFunc
+			if lp, ok := p.(LogRecordsProvider); ok {
+				logRecords = lp.GetContainerLogRecords
+			}
+			var portForwardStream api.PortForwardStreamHandlerFunc
+			if pp, ok := p.(PortForwardStreamProvider); ok {
+				portForwardStream = pp.PortForwardStream
+			}
+			var checkpoint api.ContainerCheckpointHandlerFunc
+			if cp, ok := p.(CheckpointProvider); ok {
+				checkpoint = cp.CheckpointContainer
+			}
+			getStatsSummary, getMetricsResource := p.GetStatsSummary, metricsResourceOrFromStatsSummary(p)
+			if sp, ok := p.(StatsSamplesProvider); ok {
+				stats := api.NewStatsAggregator(cfg.NodeSpec.Name, time.Now(), sp.GetStatsSamples)
+				getStatsSummary, getMetricsResource = stats.GetStatsSummary, stats.GetMetricsResource
+			}
 			mux.Handle("/", api.PodHandler(api.PodHandlerConfig{
-				RunInContainer:    p.RunInContainer,
-				AttachToContainer: p.AttachToContainer,
-				GetContainerLogs:  p.GetContainerLogs,
-				GetPods:           p.GetPods,
+				RunInContainer:         p.RunInContainer,
+				AttachToContainer:      p.AttachToContainer,
+				GetContainerLogs:       p.GetContainerLogs,
+				GetContainerLogRecords: logRecords,
+				GetPods:                p.GetPods,
 				GetPodsFromKubernetes: func(context.Context) ([]*v1.Pod, error) {
 					return pods.List(labels.Everything())
 				},
-				GetStatsSummary:       p.GetStatsSummary,
-				GetMetricsResource:    p.GetMetricsResource,
+				WatchPodsFromKubernetes: func(context.Context) (watch.Interface, error) {
+					return pc.WatchPods()
+				},
+				GetStatsSummary:       getStatsSummary,
+				GetMetricsResource:    getMetricsResource,
 				StreamIdleTimeout:     cfg.StreamIdleTimeout,
 				StreamCreationTimeout: cfg.StreamCreationTimeout,
 				PortForward:           p.PortForward,
+				PortForwardStream:     portForwardStream,
+				CheckpointContainer:   checkpoint,
+				Audit:                 cfg.Audit,
+				RecordSession:         cfg.SessionRecording,
+				Limits:                cfg.RequestLimits,
+				Sessions:              cfg.StreamSessions,
+				ProxyRoutes:           cfg.ProxyRoutes,
 			}, true))
 		}
 		return nil
 	}
 }
+
+// metricsResourceOrFromStatsSummary returns the metrics of the provider, or derives them from its stats summary when
+// the provider does not implement them.
+func metricsResourceOrFromStatsSummary(p Provider) api.PodMetricsResourceHandlerFunc {
+	fromStatsSummary := api.MetricsResourceFromStatsSummary(p.GetStatsSummary)
+	return func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		metrics, err := p.GetMetricsResource(ctx)
+		if errdefs.IsNotImplemented(err) {
+			return fromStatsSummary(ctx)
+		}
+		return metrics, err
+	}
+}
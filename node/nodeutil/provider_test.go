@@ -0,0 +1,96 @@
+package nodeutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	statsv1alpha1 "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+	"k8s.io/utils/ptr"
+)
+
+// statsOnlyProvider is a Provider which only implements stats, optionally with its own metrics.
+type statsOnlyProvider struct {
+	metrics []*dto.MetricFamily
+}
+
+var errNotSupported = errors.New("not supported")
+
+func (p *statsOnlyProvider) CreatePod(context.Context, *v1.Pod) error { return errNotSupported }
+func (p *statsOnlyProvider) UpdatePod(context.Context, *v1.Pod) error { return errNotSupported }
+func (p *statsOnlyProvider) DeletePod(context.Context, *v1.Pod) error { return errNotSupported }
+func (p *statsOnlyProvider) GetPod(context.Context, string, string) (*v1.Pod, error) {
+	return nil, errNotSupported
+}
+func (p *statsOnlyProvider) GetPodStatus(context.Context, string, string) (*v1.PodStatus, error) {
+	return nil, errNotSupported
+}
+func (p *statsOnlyProvider) GetPods(context.Context) ([]*v1.Pod, error) { return nil, nil }
+func (p *statsOnlyProvider) GetContainerLogs(context.Context, string, string, string, api.ContainerLogOpts) (io.ReadCloser, error) {
+	return nil, errNotSupported
+}
+func (p *statsOnlyProvider) RunInContainer(context.Context, string, string, string, []string, api.AttachIO) error {
+	return errNotSupported
+}
+func (p *statsOnlyProvider) AttachToContainer(context.Context, string, string, string, api.AttachIO) error {
+	return errNotSupported
+}
+func (p *statsOnlyProvider) PortForward(context.Context, string, string, int32, io.ReadWriteCloser) error {
+	return errNotSupported
+}
+
+func (p *statsOnlyProvider) GetStatsSummary(context.Context) (*statsv1alpha1.Summary, error) {
+	return &statsv1alpha1.Summary{
+		Node: statsv1alpha1.NodeStats{
+			NodeName: "vk",
+			CPU:      &statsv1alpha1.CPUStats{Time: metav1.NewTime(time.Now()), UsageCoreNanoSeconds: ptr.To[uint64](2e9)},
+		},
+	}, nil
+}
+
+func (p *statsOnlyProvider) GetMetricsResource(context.Context) ([]*dto.MetricFamily, error) {
+	if p.metrics == nil {
+		return nil, errdefs.NotImplemented("metrics are not implemented")
+	}
+	return p.metrics, nil
+}
+
+func TestAttachProviderRoutesMetricsResource(t *testing.T) {
+	get := func(p Provider) string {
+		mux := http.NewServeMux()
+		var cfg NodeConfig
+		assert.NilError(t, AttachProviderRoutes(mux)(&cfg))
+		cfg.routeAttacher(p, cfg, nil, nil)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, api.MetricsResourceRouteSuffix, nil))
+		assert.Check(t, is.Equal(w.Code, http.StatusOK))
+		return w.Body.String()
+	}
+
+	// The metrics are derived from the stats summary when the provider does not implement them.
+	body := get(&statsOnlyProvider{})
+	assert.Check(t, is.Contains(body, "node_cpu_usage_seconds_total"))
+
+	// Metrics from the provider are used as is.
+	name := "provider_metric"
+	body = get(&statsOnlyProvider{metrics: []*dto.MetricFamily{{
+		Name:   &name,
+		Type:   dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: ptr.To(1.0)}}},
+	}}})
+	assert.Check(t, is.Contains(body, name))
+	assert.Check(t, !strings.Contains(body, "node_cpu_usage_seconds_total"), body)
+}
@@ -0,0 +1,157 @@
+package nodeutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/certificate"
+)
+
+// WithServingCertificateBootstrap returns a NodeOpt which gets the serving certificate of the node from a
+// kubernetes.io/kubelet-serving CertificateSigningRequest instead of from files, like the kubelet does with
+// --rotate-server-certificates.
+// The certificate is requested for the addresses of the node and is rotated before it expires.
+//
+// When dir is set, the key and certificate are stored in it so they are reused across restarts.
+// Otherwise they are only kept in memory.
+//
+// The CSR must be approved for the node to serve requests, the cluster does not approve kubelet serving CSRs on its own.
+func WithServingCertificateBootstrap(dir string) NodeOpt {
+	return func(cfg *NodeConfig) error {
+		cfg.ServingCertificateBootstrap = true
+		cfg.ServingCertificateDir = dir
+		return nil
+	}
+}
+
+// newServingCertificateManager creates a certificate manager which requests serving certificates for the node.
+func newServingCertificateManager(client kubernetes.Interface, nodeName string, addresses []v1.NodeAddress, dir string) (certificate.Manager, error) {
+	var store certificate.Store = &memoryCertificateStore{}
+	if dir != "" {
+		var err error
+		store, err = certificate.NewFileStore("kubelet-server", dir, dir, "", "")
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating serving certificate store")
+		}
+	}
+
+	template := servingCertificateTemplate(nodeName, addresses)
+	logger := log.G(context.TODO()).WithField("node", nodeName)
+	m, err := certificate.NewManager(&certificate.Config{
+		ClientsetFn: func(*tls.Certificate) (kubernetes.Interface, error) {
+			return client, nil
+		},
+		GetTemplate: func() *x509.CertificateRequest { return template },
+		SignerName:  certificatesv1.KubeletServingSignerName,
+		Usages: []certificatesv1.KeyUsage{
+			certificatesv1.UsageDigitalSignature,
+			certificatesv1.UsageKeyEncipherment,
+			certificatesv1.UsageServerAuth,
+		},
+		CertificateStore: store,
+		Name:             "kubelet-serving",
+		Logf:             logger.Infof,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating serving certificate manager")
+	}
+	return m, nil
+}
+
+// servingCertificateTemplate returns the CSR template for the serving certificate of the node, which is valid for all
+// the addresses of the node.
+// It returns nil when the node has no address, the certificate manager does not request certificates without template.
+func servingCertificateTemplate(nodeName string, addresses []v1.NodeAddress) *x509.CertificateRequest {
+	var (
+		dnsNames []string
+		ips      []net.IP
+		seen     = make(map[string]bool)
+	)
+	for _, addr := range addresses {
+		if addr.Address == "" || seen[addr.Address] {
+			continue
+		}
+		seen[addr.Address] = true
+
+		switch addr.Type {
+		case v1.NodeHostName, v1.NodeInternalDNS, v1.NodeExternalDNS:
+			dnsNames = append(dnsNames, addr.Address)
+		case v1.NodeInternalIP, v1.NodeExternalIP:
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(dnsNames) == 0 && len(ips) == 0 {
+		return nil
+	}
+
+	return &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   "system:node:" + nodeName,
+			Organization: []string{"system:nodes"},
+		},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+	}
+}
+
+// getCertificateFunc returns a function for tls.Config.GetCertificate which serves the current certificate of m.
+// The certificates already configured in cfg, if any, are served until a certificate is issued.
+func getCertificateFunc(m certificate.Manager, cfg *tls.Config) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	fallback := cfg.GetCertificate
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert := m.Current()
+		if cert == nil {
+			if fallback != nil {
+				return fallback(hello)
+			}
+			if len(cfg.Certificates) > 0 {
+				return nil, nil
+			}
+			return nil, errors.New("no serving certificate available for the node, the certificate signing request may not be approved yet")
+		}
+		return cert, nil
+	}
+}
+
+// memoryCertificateStore is a certificate.Store which keeps the certificate in memory.
+type memoryCertificateStore struct {
+	mu   sync.Mutex
+	cert *tls.Certificate
+}
+
+func (s *memoryCertificateStore) Current() (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cert == nil {
+		noKeyErr := certificate.NoCertKeyError("no serving certificate has been issued yet")
+		return nil, &noKeyErr
+	}
+	return s.cert, nil
+}
+
+func (s *memoryCertificateStore) Update(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing serving certificate")
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing serving certificate")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cert = &cert
+	return s.cert, nil
+}
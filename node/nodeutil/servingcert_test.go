@@ -0,0 +1,119 @@
+package nodeutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+// approveCSRs makes the client approve and sign the certificate signing requests it creates.
+func approveCSRs(t *testing.T, client *fake.Clientset) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	assert.NilError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NilError(t, err)
+
+	client.PrependReactor("create", "certificatesigningrequests", func(action ktesting.Action) (bool, runtime.Object, error) {
+		csr := action.(ktesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		if csr.Name == "" {
+			csr.Name = csr.GenerateName + "test"
+		}
+
+		block, _ := pem.Decode(csr.Spec.Request)
+		req, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			return true, nil, err
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      req.Subject,
+			DNSNames:     req.DNSNames,
+			IPAddresses:  req.IPAddresses,
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, ca, req.PublicKey, caKey)
+		if err != nil {
+			return true, nil, err
+		}
+
+		csr.Status.Certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+			Type:   certificatesv1.CertificateApproved,
+			Status: v1.ConditionTrue,
+		})
+		// Let the object tracker store the signed request.
+		return false, nil, nil
+	})
+}
+
+func TestServingCertificateBootstrap(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	approveCSRs(t, client)
+
+	addresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeHostName, Address: "vk"},
+		{Type: v1.NodeInternalDNS, Address: "vk"},
+	}
+	m, err := newServingCertificateManager(client, "vk", addresses, t.TempDir())
+	assert.NilError(t, err)
+
+	tlsCfg := &tls.Config{}
+	getCertificate := getCertificateFunc(m, tlsCfg)
+	_, err = getCertificate(nil)
+	assert.Check(t, is.ErrorContains(err, "no serving certificate"))
+
+	m.Start()
+	defer m.Stop()
+
+	err = wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 30*time.Second, true, func(context.Context) (bool, error) {
+		return m.Current() != nil, nil
+	})
+	assert.NilError(t, err)
+
+	cert, err := getCertificate(nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(cert.Leaf.Subject.CommonName, "system:node:vk"))
+	assert.Check(t, is.DeepEqual(cert.Leaf.DNSNames, []string{"vk"}))
+	assert.Assert(t, is.Len(cert.Leaf.IPAddresses, 1))
+	assert.Check(t, cert.Leaf.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")))
+
+	csrs, err := client.CertificatesV1().CertificateSigningRequests().List(context.Background(), metav1.ListOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(csrs.Items, 1))
+	assert.Check(t, is.Equal(csrs.Items[0].Spec.SignerName, certificatesv1.KubeletServingSignerName))
+	assert.Check(t, is.Contains(csrs.Items[0].Spec.Usages, certificatesv1.UsageServerAuth))
+
+	assert.Check(t, is.Nil(servingCertificateTemplate("vk", nil)), "no certificate is requested without addresses")
+}
@@ -0,0 +1,254 @@
+package nodeutil
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
+	"k8s.io/apiserver/pkg/authentication/request/union"
+	x509request "k8s.io/apiserver/pkg/authentication/request/x509"
+	"k8s.io/apiserver/pkg/authentication/token/tokenfile"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"sigs.k8s.io/yaml"
+)
+
+// StaticAuthOption is used as a functional argument to configure static auth.
+type StaticAuthOption func(*StaticAuthConfig) error
+
+// StaticAuthConfig stores the configuration for static auth.
+type StaticAuthConfig struct {
+	// ClientCAFile is the PEM encoded CA bundle used to authenticate client certificates.
+	// The user name is the common name of the certificate and the groups are its organizations.
+	ClientCAFile string
+	// TokenFile is a CSV file of bearer tokens, in the format of the Kubernetes API server --token-auth-file:
+	// token,user,uid,"group1,group2".
+	TokenFile string
+	// PolicyFile is a YAML or JSON file holding a StaticAuthPolicy.
+	PolicyFile string
+	// Reload makes the files be reloaded when they change, they are checked during authentication at most once per
+	// DefaultTLSReloadInterval.
+	// Files which fail to load are logged and the previous content is used until they are fixed.
+	Reload bool
+}
+
+// StaticAuthPolicy is the policy of static auth, requests are allowed when any of the rules matches.
+type StaticAuthPolicy struct {
+	Rules []StaticAuthRule `json:"rules"`
+}
+
+// StaticAuthRule allows the users and groups to make requests with the verbs to the subresources of the node.
+// "*" matches any user, group, verb or subresource.
+//
+// The verbs and subresources are the ones of the attributes built by NodeRequestAttr: verbs are "get", "create",
+// "update", "patch" or "delete" and subresources "stats", "metrics", "log", "checkpoint" or "proxy", which covers all
+// the other endpoints.
+// The "exec" subresource can be used to only allow the exec, attach, port-forward and run endpoints, which are part
+// of "proxy".
+type StaticAuthRule struct {
+	Users        []string `json:"users,omitempty"`
+	Groups       []string `json:"groups,omitempty"`
+	Verbs        []string `json:"verbs"`
+	Subresources []string `json:"subresources"`
+}
+
+var (
+	staticAuthVerbs        = sets.New("*", "get", "create", "update", "patch", "delete")
+	staticAuthSubresources = sets.New("*", "stats", "metrics", "log", "checkpoint", "proxy", "exec")
+)
+
+// StaticAuth creates an Auth which authenticates and authorizes requests from local files instead of through the
+// Kubernetes API server, see StaticAuthConfig.
+// A policy file is required, along with a client CA file, a token file or both.
+func StaticAuth(nodeName string, opts ...StaticAuthOption) (Auth, error) {
+	var cfg StaticAuthConfig
+	for _, o := range opts {
+		if err := o(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.PolicyFile == "" {
+		return nil, errors.New("static auth requires a policy file")
+	}
+	if cfg.ClientCAFile == "" && cfg.TokenFile == "" {
+		return nil, errors.New("static auth requires a client CA file or a token file")
+	}
+
+	interval := DefaultTLSReloadInterval
+	if !cfg.Reload {
+		interval = -1
+	}
+
+	var authns []authenticator.Request
+	if cfg.ClientCAFile != "" {
+		r, err := newCAReloader(cfg.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client CA file")
+		}
+		r.interval = interval
+		authns = append(authns, x509request.NewDynamic(func() (x509.VerifyOptions, bool) {
+			opts := x509request.DefaultVerifyOptions()
+			opts.Roots = r.Pool()
+			return opts, true
+		}, x509request.CommonNameUserConversion))
+	}
+	if cfg.TokenFile != "" {
+		tokens := &tokenFileAuthenticator{path: cfg.TokenFile}
+		r, err := newFileReloader(tokens.load, cfg.TokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading token file")
+		}
+		r.interval = interval
+		tokens.reloader = r
+		authns = append(authns, bearertoken.New(tokens))
+	}
+
+	authz := &policyAuthorizer{}
+	r, err := newFileReloader(authz.load, cfg.PolicyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading policy file")
+	}
+	r.interval = interval
+	authz.reloader = r
+
+	return &authWrapper{
+		Request:                 union.New(authns...),
+		RequestAttributesGetter: NodeRequestAttr{nodeName},
+		Authorizer:              authz,
+	}, nil
+}
+
+// tokenFileAuthenticator authenticates bearer tokens from a CSV file.
+type tokenFileAuthenticator struct {
+	path     string
+	reloader *fileReloader
+
+	mu     sync.RWMutex
+	tokens *tokenfile.TokenAuthenticator
+}
+
+func (a *tokenFileAuthenticator) load([][]byte) error {
+	tokens, err := tokenfile.NewCSV(a.path)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.tokens = tokens
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *tokenFileAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	a.reloader.maybeReload()
+
+	a.mu.RLock()
+	tokens := a.tokens
+	a.mu.RUnlock()
+	return tokens.AuthenticateToken(ctx, token)
+}
+
+// policyAuthorizer authorizes requests according to a StaticAuthPolicy.
+type policyAuthorizer struct {
+	reloader *fileReloader
+
+	mu     sync.RWMutex
+	policy *StaticAuthPolicy
+}
+
+func (a *policyAuthorizer) load(contents [][]byte) error {
+	var policy StaticAuthPolicy
+	if err := yaml.UnmarshalStrict(contents[0], &policy); err != nil {
+		return errors.Wrap(err, "error parsing policy")
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.policy = &policy
+	a.mu.Unlock()
+	return nil
+}
+
+func (p *StaticAuthPolicy) validate() error {
+	for i, rule := range p.Rules {
+		if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+			return errors.Errorf("rule %d: at least one user or group is required", i)
+		}
+		if len(rule.Verbs) == 0 {
+			return errors.Errorf("rule %d: at least one verb is required", i)
+		}
+		if len(rule.Subresources) == 0 {
+			return errors.Errorf("rule %d: at least one subresource is required", i)
+		}
+		for _, v := range rule.Verbs {
+			if !staticAuthVerbs.Has(v) {
+				return errors.Errorf("rule %d: unknown verb %q, expected one of %s", i, v, strings.Join(sets.List(staticAuthVerbs), ", "))
+			}
+		}
+		for _, s := range rule.Subresources {
+			if !staticAuthSubresources.Has(s) {
+				return errors.Errorf("rule %d: unknown subresource %q, expected one of %s", i, s, strings.Join(sets.List(staticAuthSubresources), ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func (a *policyAuthorizer) Authorize(_ context.Context, attrs authorizer.Attributes) (authorizer.Decision, string, error) {
+	a.reloader.maybeReload()
+
+	a.mu.RLock()
+	policy := a.policy
+	a.mu.RUnlock()
+
+	for i, rule := range policy.Rules {
+		if rule.matches(attrs) {
+			return authorizer.DecisionAllow, fmt.Sprintf("allowed by rule %d", i), nil
+		}
+	}
+	return authorizer.DecisionNoOpinion, "no rule allows the request", nil
+}
+
+func (r *StaticAuthRule) matches(attrs authorizer.Attributes) bool {
+	u := attrs.GetUser()
+	if u == nil {
+		return false
+	}
+	if !matchesAny(r.Users, u.GetName()) && !matchesAny(r.Groups, u.GetGroups()...) {
+		return false
+	}
+	if !matchesAny(r.Verbs, attrs.GetVerb()) {
+		return false
+	}
+
+	subresource := attrs.GetSubresource()
+	if matchesAny(r.Subresources, subresource) {
+		return true
+	}
+	return subresource == "proxy" && isExecPath(attrs.GetPath()) && matchesAny(r.Subresources, "exec")
+}
+
+func isExecPath(p string) bool {
+	return isSubpath(p, "/exec") || isSubpath(p, "/attach") || isSubpath(p, "/portForward") || isSubpath(p, "/run")
+}
+
+// matchesAny returns true if any of the values is in allowed, or allowed contains "*".
+func matchesAny(allowed []string, values ...string) bool {
+	for _, a := range allowed {
+		if a == "*" {
+			return true
+		}
+		for _, v := range values {
+			if a == v {
+				return true
+			}
+		}
+	}
+	return false
+}
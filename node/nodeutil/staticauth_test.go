@@ -0,0 +1,140 @@
+package nodeutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestStaticAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	assert.NilError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NilError(t, err)
+	clientDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "carol", Organization: []string{"admins"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey.Public(), caKey)
+	assert.NilError(t, err)
+	clientCert, err := x509.ParseCertificate(clientDER)
+	assert.NilError(t, err)
+	// A certificate with the same subject as the CA, but signed by another key.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	otherDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, otherKey.Public(), otherKey)
+	assert.NilError(t, err)
+	otherCert, err := x509.ParseCertificate(otherDER)
+	assert.NilError(t, err)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	writeTestFile(t, caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+	tokenFile := filepath.Join(dir, "tokens.csv")
+	writeTestFile(t, tokenFile, []byte("alice-token,alice,1,\"ops,dev\"\nbob-token,bob,2\n"))
+	policyFile := filepath.Join(dir, "policy.yaml")
+	writeTestFile(t, policyFile, []byte(`
+rules:
+- groups: ["ops"]
+  verbs: ["get"]
+  subresources: ["stats", "metrics"]
+- users: ["bob"]
+  verbs: ["*"]
+  subresources: ["exec"]
+- groups: ["admins"]
+  verbs: ["*"]
+  subresources: ["*"]
+`))
+
+	auth, err := StaticAuth("vk", func(cfg *StaticAuthConfig) error {
+		cfg.ClientCAFile = caFile
+		cfg.TokenFile = tokenFile
+		cfg.PolicyFile = policyFile
+		cfg.Reload = true
+		return nil
+	})
+	assert.NilError(t, err)
+	h := WithAuth(auth, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	type request struct {
+		method, path, token string
+		cert                *x509.Certificate
+	}
+	do := func(r request) int {
+		req := httptest.NewRequest(r.method, r.path, nil)
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		if r.cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{r.cert}}
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, tc := range []struct {
+		request
+		code int
+	}{
+		{request{http.MethodGet, "/stats/summary", "", nil}, http.StatusUnauthorized},
+		{request{http.MethodGet, "/stats/summary", "wrong-token", nil}, http.StatusUnauthorized},
+		{request{http.MethodGet, "/stats/summary", "alice-token", nil}, http.StatusOK},
+		{request{http.MethodGet, "/metrics", "alice-token", nil}, http.StatusOK},
+		{request{http.MethodGet, "/containerLogs/default/web/app", "alice-token", nil}, http.StatusForbidden},
+		{request{http.MethodPost, "/exec/default/web/app", "bob-token", nil}, http.StatusOK},
+		{request{http.MethodGet, "/attach/default/web/app", "bob-token", nil}, http.StatusOK},
+		{request{http.MethodGet, "/pods", "bob-token", nil}, http.StatusForbidden},
+		{request{http.MethodGet, "/pods", "", clientCert}, http.StatusOK},
+		{request{http.MethodGet, "/pods", "", otherCert}, http.StatusUnauthorized},
+	} {
+		assert.Check(t, is.Equal(do(tc.request), tc.code), "%s %s %s", tc.method, tc.path, tc.token)
+	}
+
+	authz := auth.(*authWrapper).Authorizer.(*policyAuthorizer)
+	authz.reloader.interval = 0
+
+	// An invalid policy is not loaded.
+	writeTestFile(t, policyFile, []byte("rules:\n- users: [alice]\n  verbs: [get]\n  subresources: [pods]\n"))
+	assert.Check(t, is.Equal(do(request{http.MethodGet, "/stats/summary", "alice-token", nil}), http.StatusOK))
+
+	writeTestFile(t, policyFile, []byte("rules:\n- users: [alice]\n  verbs: [get]\n  subresources: [log]\n"))
+	assert.Check(t, is.Equal(do(request{http.MethodGet, "/stats/summary", "alice-token", nil}), http.StatusForbidden))
+	assert.Check(t, is.Equal(do(request{http.MethodGet, "/containerLogs/default/web/app", "alice-token", nil}), http.StatusForbidden))
+	assert.Check(t, is.Equal(do(request{http.MethodGet, "/logs/", "alice-token", nil}), http.StatusOK))
+
+	writeTestFile(t, policyFile, []byte("rules:\n- users: [alice]\n  verbs: [watch]\n  subresources: [log]\n"))
+	_, err = StaticAuth("vk", func(cfg *StaticAuthConfig) error {
+		cfg.TokenFile = tokenFile
+		cfg.PolicyFile = policyFile
+		return nil
+	})
+	assert.Check(t, is.ErrorContains(err, `unknown verb "watch"`))
+}
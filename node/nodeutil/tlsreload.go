@@ -0,0 +1,319 @@
+package nodeutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	x509request "k8s.io/apiserver/pkg/authentication/request/x509"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+)
+
+var _ dynamiccertificates.CAContentProvider = &ClientCA{}
+
+// DefaultTLSReloadInterval is how often the files of reloading TLS options are checked for changes.
+const DefaultTLSReloadInterval = 10 * time.Second
+
+// WithKeyPairFromPathReload makes a TLS config option like WithKeyPairFromPath which reloads the key pair when the
+// files change, through tls.Config.GetCertificate.
+//
+// The files are checked for changes during TLS handshakes, at most once per DefaultTLSReloadInterval.
+// A key pair which fails to load is logged and the previous key pair is served until the files are fixed.
+// Established connections, such as exec sessions, keep using the key pair they were created with.
+func WithKeyPairFromPathReload(cert, key string) func(*tls.Config) error {
+	return func(cfg *tls.Config) error {
+		r, err := newKeyPairReloader(cert, key)
+		if err != nil {
+			return fmt.Errorf("error loading key pair: %w", err)
+		}
+		cfg.GetCertificate = r.GetCertificate
+		return nil
+	}
+}
+
+// WithCAFromPathReload makes a TLS config option like WithCAFromPath which reloads the CA certs when the file
+// changes, through tls.Config.GetConfigForClient.
+//
+// The file is checked for changes during TLS handshakes, at most once per DefaultTLSReloadInterval.
+// A CA file which fails to load is logged and the previous CA certs are used until the file is fixed.
+func WithCAFromPathReload(p string) func(*tls.Config) error {
+	return func(cfg *tls.Config) error {
+		ca, err := NewClientCAFromPathReload(p)
+		if err != nil {
+			return err
+		}
+		return ca.TLSConfig(cfg)
+	}
+}
+
+// ClientCA is a CA bundle, reloaded when its file changes like with WithCAFromPathReload, which verifies client
+// certificates.
+// The same ClientCA should be used for the TLS config and the authentication of requests, so both agree on the CA
+// certs when the file changes. It implements dynamiccertificates.CAContentProvider, see WebhookAuthConfig.
+type ClientCA struct {
+	name string
+	r    *caReloader
+
+	listenersMu sync.Mutex
+	listeners   []dynamiccertificates.Listener
+}
+
+// NewClientCAFromPathReload loads the CA bundle from the file at p.
+func NewClientCAFromPathReload(p string) (*ClientCA, error) {
+	ca := &ClientCA{name: p}
+	r, err := newCAReloader(p)
+	if err != nil {
+		return nil, fmt.Errorf("error loading ca cert pem: %w", err)
+	}
+	r.notify = ca.notify
+	ca.r = r
+	return ca, nil
+}
+
+// TLSConfig is a TLS config option which requires client certificates verified by the CA certs.
+func (ca *ClientCA) TLSConfig(cfg *tls.Config) error {
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	cfg.ClientCAs = ca.r.Pool()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := cfg.Clone()
+		c.ClientCAs = ca.r.Pool()
+		return c, nil
+	}
+	return nil
+}
+
+// Name implements dynamiccertificates.CAContentProvider.
+func (ca *ClientCA) Name() string {
+	return ca.name
+}
+
+// CurrentCABundleContent implements dynamiccertificates.CAContentProvider.
+func (ca *ClientCA) CurrentCABundleContent() []byte {
+	return ca.r.Content()
+}
+
+// VerifyOptions implements dynamiccertificates.CAContentProvider.
+func (ca *ClientCA) VerifyOptions() (x509.VerifyOptions, bool) {
+	opts := x509request.DefaultVerifyOptions()
+	opts.Roots = ca.r.Pool()
+	return opts, true
+}
+
+// AddListener implements dynamiccertificates.Notifier, listeners are notified when the CA certs are reloaded.
+func (ca *ClientCA) AddListener(l dynamiccertificates.Listener) {
+	ca.listenersMu.Lock()
+	defer ca.listenersMu.Unlock()
+	ca.listeners = append(ca.listeners, l)
+}
+
+func (ca *ClientCA) notify() {
+	ca.listenersMu.Lock()
+	defer ca.listenersMu.Unlock()
+	for _, l := range ca.listeners {
+		l.Enqueue()
+	}
+}
+
+// fileReloader calls load with the content of files when they change.
+type fileReloader struct {
+	paths []string
+	load  func(contents [][]byte) error
+	// interval is the minimum time between checks for changes, files are never reloaded when it is negative.
+	interval time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	versions  []string
+}
+
+func newFileReloader(load func([][]byte) error, paths ...string) (*fileReloader, error) {
+	r := &fileReloader{paths: paths, load: load, interval: DefaultTLSReloadInterval}
+	versions, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.read(); err != nil {
+		return nil, err
+	}
+	r.versions = versions
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+// stat returns the version of the files, secrets mounted in pods are updated by replacing symlinks which is seen as
+// a change of the modification time.
+func (r *fileReloader) stat() ([]string, error) {
+	versions := make([]string, 0, len(r.paths))
+	for _, p := range r.paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size()))
+	}
+	return versions, nil
+}
+
+func (r *fileReloader) read() error {
+	contents := make([][]byte, 0, len(r.paths))
+	for _, p := range r.paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		contents = append(contents, b)
+	}
+	return r.load(contents)
+}
+
+// maybeReload reloads the files if they changed since they were last loaded.
+func (r *fileReloader) maybeReload() {
+	if r.interval < 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < r.interval {
+		return
+	}
+	r.lastCheck = time.Now()
+
+	logger := log.G(context.TODO()).WithField("files", r.paths)
+	versions, err := r.stat()
+	if err != nil {
+		logger.WithError(err).Error("Error checking files for changes")
+		return
+	}
+	if equalStrings(versions, r.versions) {
+		return
+	}
+	if err := r.read(); err != nil {
+		logger.WithError(err).Error("Error reloading files, keeping the current ones")
+		return
+	}
+	r.versions = versions
+	logger.Info("Reloaded files")
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type keyPairReloader struct {
+	*fileReloader
+
+	certMu sync.RWMutex
+	cert   *tls.Certificate
+}
+
+func newKeyPairReloader(cert, key string) (*keyPairReloader, error) {
+	kr := &keyPairReloader{}
+	fr, err := newFileReloader(kr.load, cert, key)
+	if err != nil {
+		return nil, err
+	}
+	kr.fileReloader = fr
+	return kr, nil
+}
+
+func (r *keyPairReloader) load(contents [][]byte) error {
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return err
+	}
+	if cert.Leaf == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	r.certMu.Lock()
+	defer r.certMu.Unlock()
+	// Don't replace a certificate with one which is already expired, the files are likely being updated.
+	if r.cert != nil && time.Now().After(cert.Leaf.NotAfter) {
+		return fmt.Errorf("certificate expired on %s", cert.Leaf.NotAfter)
+	}
+	r.cert = &cert
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *keyPairReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+
+	r.certMu.RLock()
+	defer r.certMu.RUnlock()
+	return r.cert, nil
+}
+
+type caReloader struct {
+	*fileReloader
+	// notify, when set, is called when the CA certs are reloaded.
+	notify func()
+
+	poolMu  sync.RWMutex
+	pool    *x509.CertPool
+	content []byte
+}
+
+func newCAReloader(p string) (*caReloader, error) {
+	cr := &caReloader{}
+	fr, err := newFileReloader(cr.load, p)
+	if err != nil {
+		return nil, err
+	}
+	cr.fileReloader = fr
+	return cr, nil
+}
+
+func (r *caReloader) load(contents [][]byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(contents[0]) {
+		return fmt.Errorf("could not parse ca cert pem")
+	}
+
+	r.poolMu.Lock()
+	reloaded := r.pool != nil
+	r.pool = pool
+	r.content = contents[0]
+	r.poolMu.Unlock()
+
+	if reloaded && r.notify != nil {
+		r.notify()
+	}
+	return nil
+}
+
+// Pool returns the current CA certs.
+func (r *caReloader) Pool() *x509.CertPool {
+	r.maybeReload()
+
+	r.poolMu.RLock()
+	defer r.poolMu.RUnlock()
+	return r.pool
+}
+
+// Content returns the current CA bundle.
+func (r *caReloader) Content() []byte {
+	r.maybeReload()
+
+	r.poolMu.RLock()
+	defer r.poolMu.RUnlock()
+	return r.content
+}
@@ -0,0 +1,181 @@
+package nodeutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+var testFileTime = time.Now()
+
+// writeTestFile writes the file with a new modification time, so changes are seen even if the size doesn't change.
+func writeTestFile(t *testing.T, p string, data []byte) {
+	t.Helper()
+	assert.NilError(t, os.WriteFile(p, data, 0600))
+	testFileTime = testFileTime.Add(time.Second)
+	assert.NilError(t, os.Chtimes(p, testFileTime, testFileTime))
+}
+
+func writeTestKeyPair(t *testing.T, dir, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NilError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestFile(t, certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeTestFile(t, keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPath, keyPath
+}
+
+func TestKeyPairReload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeTestKeyPair(t, dir, "first")
+
+	r, err := newKeyPairReloader(certPath, keyPath)
+	assert.NilError(t, err)
+	r.interval = 0
+	serverCfg := &tls.Config{GetCertificate: r.GetCertificate}
+
+	serverName := func(c *tls.Conn) string {
+		return c.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", serverCfg)
+	assert.NilError(t, err)
+	defer l.Close()
+	connect := func() (net.Conn, *tls.Conn) {
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				conn.(*tls.Conn).Handshake() //nolint:errcheck
+			}
+			accepted <- conn
+		}()
+		client, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+		assert.NilError(t, err)
+		return <-accepted, client
+	}
+
+	server, client := connect()
+	defer server.Close()
+	defer client.Close()
+	assert.Check(t, is.Equal(serverName(client), "first"))
+
+	// An invalid key pair is not loaded.
+	writeTestFile(t, certPath, []byte("not a certificate"))
+	s, c := connect()
+	assert.Check(t, is.Equal(serverName(c), "first"))
+	s.Close()
+	c.Close()
+
+	writeTestKeyPair(t, dir, "second")
+	s, c = connect()
+	assert.Check(t, is.Equal(serverName(c), "second"))
+	s.Close()
+	c.Close()
+
+	// Established connections are not affected by reloads.
+	go func() {
+		io.WriteString(server, "still connected") //nolint:errcheck
+	}()
+	b := make([]byte, len("still connected"))
+	_, err = io.ReadFull(client, b)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(b), "still connected"))
+}
+
+func TestCAReload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, _ := writeTestKeyPair(t, dir, "first-ca")
+	first, err := os.ReadFile(certPath)
+	assert.NilError(t, err)
+
+	cfg := &tls.Config{}
+	assert.NilError(t, WithCAFromPathReload(certPath)(cfg))
+	assert.Check(t, is.Equal(cfg.ClientAuth, tls.RequireAndVerifyClientCert))
+
+	r, err := newCAReloader(certPath)
+	assert.NilError(t, err)
+	r.interval = 0
+
+	expected := x509.NewCertPool()
+	expected.AppendCertsFromPEM(first)
+	assert.Check(t, r.Pool().Equal(expected))
+
+	writeTestFile(t, certPath, []byte("not a certificate"))
+	assert.Check(t, r.Pool().Equal(expected), "an invalid CA file is not loaded")
+
+	writeTestKeyPair(t, dir, "second-ca")
+	second, err := os.ReadFile(certPath)
+	assert.NilError(t, err)
+	expected = x509.NewCertPool()
+	expected.AppendCertsFromPEM(second)
+	assert.Check(t, r.Pool().Equal(expected))
+
+	clientCfg, err := cfg.GetConfigForClient(nil)
+	assert.NilError(t, err)
+	assert.Check(t, clientCfg.ClientCAs != nil)
+}
+
+type countingListener int
+
+func (l *countingListener) Enqueue() { *l++ }
+
+func TestClientCA(t *testing.T) {
+	dir := t.TempDir()
+	certPath, _ := writeTestKeyPair(t, dir, "first-ca")
+
+	ca, err := NewClientCAFromPathReload(certPath)
+	assert.NilError(t, err)
+	ca.r.interval = 0
+	var reloads countingListener
+	ca.AddListener(&reloads)
+
+	cfg := &tls.Config{}
+	assert.NilError(t, ca.TLSConfig(cfg))
+
+	// After the CA rotates, the TLS handshakes and the authentication of requests both use the new CA.
+	writeTestKeyPair(t, dir, "second-ca")
+	second, err := os.ReadFile(certPath)
+	assert.NilError(t, err)
+	expected := x509.NewCertPool()
+	expected.AppendCertsFromPEM(second)
+
+	opts, ok := ca.VerifyOptions()
+	assert.Assert(t, ok)
+	assert.Check(t, opts.Roots.Equal(expected))
+	assert.Check(t, is.DeepEqual(opts.KeyUsages, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}))
+	assert.Check(t, is.Equal(string(ca.CurrentCABundleContent()), string(second)))
+	clientCfg, err := cfg.GetConfigForClient(nil)
+	assert.NilError(t, err)
+	assert.Check(t, clientCfg.ClientCAs.Equal(expected))
+	assert.Check(t, is.Equal(int(reloads), 1))
+}
@@ -17,6 +17,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 	"testing"
 	"time"
@@ -29,6 +30,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
@@ -468,3 +470,88 @@ func newPodSpec() corev1.PodSpec {
 		},
 	}
 }
+
+func TestPodStatusUpdateWatch(t *testing.T) {
+	ctx := context.Background()
+	c := newTestController()
+	pod := &corev1.Pod{}
+	pod.ObjectMeta.Namespace = "default"
+	pod.ObjectMeta.Name = "nginx"
+	pod.Spec = newPodSpec()
+	fk8s := fake.NewSimpleClientset(pod)
+	c.client = fk8s
+	c.PodController.client = fk8s.CoreV1()
+
+	w, err := c.WatchPods()
+	assert.NilError(t, err)
+	defer w.Stop()
+
+	podFromProvider := pod.DeepCopy()
+	podFromProvider.Status.Phase = corev1.PodRunning
+	key := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+	c.knownPods.Store(key, &knownPod{lastPodStatusReceivedFromProvider: podFromProvider})
+	assert.NilError(t, c.updatePodStatus(ctx, pod, key))
+
+	select {
+	case e := <-w.ResultChan():
+		assert.Check(t, is.Equal(e.Type, watch.Modified))
+		assert.Check(t, is.Equal(e.Object.(*corev1.Pod).Status.Phase, corev1.PodRunning))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for pod event")
+	}
+}
+
+func TestPodWatchSlowWatcher(t *testing.T) {
+	ctx := context.Background()
+	c := newTestController()
+
+	slow, err := c.WatchPods()
+	assert.NilError(t, err)
+	defer slow.Stop()
+	fast, err := c.WatchPods()
+	assert.NilError(t, err)
+	defer fast.Stop()
+
+	var received int
+	fastDone := make(chan struct{})
+	go func() {
+		defer close(fastDone)
+		for range fast.ResultChan() {
+			received++
+			if received == podWatchQueueLength+1 {
+				return
+			}
+		}
+	}()
+
+	pod := &corev1.Pod{}
+	pod.ObjectMeta.Namespace = "default"
+	pod.ObjectMeta.Name = "nginx"
+	for i := 0; i < podWatchQueueLength+1; i++ {
+		c.notifyPodEvent(ctx, watch.Modified, pod)
+		// Let the fast watcher keep up.
+		for len(fast.ResultChan()) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	select {
+	case <-fastDone:
+		assert.Check(t, is.Equal(received, podWatchQueueLength+1), "fast watcher should see every event")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the fast watcher")
+	}
+
+	// The slow watcher gets the events which fit in its queue, then an Expired error and the end of the watch.
+	var events []watch.Event
+	for e := range slow.ResultChan() {
+		events = append(events, e)
+	}
+	assert.Assert(t, is.Len(events, podWatchQueueLength+1))
+	last := events[len(events)-1]
+	assert.Check(t, is.Equal(last.Type, watch.Error))
+	status, ok := last.Object.(*v1.Status)
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(status.Code, int32(http.StatusGone)))
+	assert.Check(t, is.Equal(status.Reason, v1.StatusReasonExpired))
+}
@@ -0,0 +1,109 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// podWatchQueueLength is the number of events buffered for each pod watcher.
+const podWatchQueueLength = 100
+
+// WatchPods returns a watch of the pods handled by the controller.
+//
+// Pods are added when they are scheduled to the node, modified when their spec changes or when the controller
+// updates their status with the status from the provider, and deleted when they are removed from Kubernetes.
+// Watchers which do not keep up get an Expired error event and their watch is closed, so they can list the pods
+// again, like the watches of the API server. The watch is also closed when the controller exits.
+func (pc *PodController) WatchPods() (watch.Interface, error) {
+	return pc.podEvents.watch()
+}
+
+// notifyPodEvent sends the pod event to the pod watchers.
+func (pc *PodController) notifyPodEvent(ctx context.Context, t watch.EventType, pod *corev1.Pod) {
+	if expired := pc.podEvents.send(watch.Event{Type: t, Object: pod.DeepCopy()}); expired > 0 {
+		log.G(ctx).WithField("pod", loggablePodName(pod)).WithField("watchers", expired).Debug("Closed pod watchers which were not keeping up")
+	}
+}
+
+// podWatchers fans out pod events to the watchers from WatchPods.
+type podWatchers struct {
+	mu       sync.Mutex
+	watchers map[*podWatcher]struct{}
+	closed   bool
+}
+
+func newPodWatchers() *podWatchers {
+	return &podWatchers{watchers: make(map[*podWatcher]struct{})}
+}
+
+func (pw *podWatchers) watch() (watch.Interface, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.closed {
+		return nil, apierrors.NewServiceUnavailable("pod controller is shutting down")
+	}
+	// One more event than the queue length is buffered to leave room for the error event of watchers which fall behind.
+	w := &podWatcher{parent: pw, result: make(chan watch.Event, podWatchQueueLength+1)}
+	pw.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// send queues the event for each watcher without blocking, watchers whose queue is full are ended with an Expired
+// error. It returns the number of watchers which were ended.
+func (pw *podWatchers) send(e watch.Event) int {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	var expired int
+	for w := range pw.watchers {
+		// Only send adds to the channel, with the lock held, so the length can only decrease while checking it.
+		if len(w.result) < podWatchQueueLength {
+			w.result <- e
+			continue
+		}
+		status := apierrors.NewResourceExpired("pod watch fell behind, the pods need to be listed again").ErrStatus
+		w.result <- watch.Event{Type: watch.Error, Object: &status}
+		pw.removeLocked(w)
+		expired++
+	}
+	return expired
+}
+
+// shutdown closes the watches, no new watch can be started after.
+func (pw *podWatchers) shutdown() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.closed = true
+	for w := range pw.watchers {
+		pw.removeLocked(w)
+	}
+}
+
+func (pw *podWatchers) removeLocked(w *podWatcher) {
+	if _, ok := pw.watchers[w]; !ok {
+		return
+	}
+	delete(pw.watchers, w)
+	close(w.result)
+}
+
+// podWatcher is a watch.Interface of the pod events.
+type podWatcher struct {
+	parent *podWatchers
+	result chan watch.Event
+}
+
+func (w *podWatcher) Stop() {
+	w.parent.mu.Lock()
+	defer w.parent.mu.Unlock()
+	w.parent.removeLocked(w)
+}
+
+func (w *podWatcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
@@ -30,6 +30,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
@@ -125,6 +126,9 @@ type PodController struct {
 
 	podEventFilterFunc PodEventFilterFunc
 
+	// podEvents broadcasts pod changes to the watchers from WatchPods.
+	podEvents *podWatchers
+
 	// ready is a channel which will be closed once the pod controller is fully up and running.
 	// this channel will never be closed if there is an error on startup.
 	ready chan struct{}
@@ -245,6 +249,7 @@ func NewPodController(cfg PodControllerConfig) (*PodController, error) {
 		done:               make(chan struct{}),
 		recorder:           cfg.EventRecorder,
 		podEventFilterFunc: cfg.PodEventFilterFunc,
+		podEvents:          newPodWatchers(),
 	}
 
 	pc.syncPodsFromKubernetes = queue.New(cfg.SyncPodsFromKubernetesRateLimiter, "syncPodsFromKubernetes", pc.syncPodFromKubernetesHandler, cfg.SyncPodsFromKubernetesShouldRetryFunc)
@@ -278,14 +283,16 @@ func (pc *PodController) Run(ctx context.Context, podSyncWorkers int) (retErr er
 		close(pc.done)
 		pc.mu.Unlock()
 	}()
+	defer pc.podEvents.shutdown()
 
 	var provider asyncProvider
 	runProvider := func(context.Context) {}
 
+	instrumented := &instrumentedProvider{PodLifecycleHandler: pc.provider}
 	if p, ok := pc.provider.(asyncProvider); ok {
-		provider = p
+		provider = &instrumentedAsyncProvider{instrumentedProvider: instrumented, PodNotifier: p}
 	} else {
-		wrapped := &syncProviderWrapper{PodLifecycleHandler: pc.provider, l: pc.podsLister}
+		wrapped := &syncProviderWrapper{PodLifecycleHandler: instrumented, l: pc.podsLister}
 		runProvider = wrapped.run
 		provider = wrapped
 		log.G(ctx).Debug("Wrapped non-async provider with async")
@@ -320,6 +327,9 @@ func (pc *PodController) Run(ctx context.Context, podSyncWorkers int) (retErr er
 				ctx = span.WithField(ctx, "key", key)
 				pc.knownPods.Store(key, &knownPod{})
 				pc.syncPodsFromKubernetes.Enqueue(ctx, key)
+				if p, ok := pod.(*corev1.Pod); ok {
+					pc.notifyPodEvent(ctx, watch.Added, p)
+				}
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
@@ -363,6 +373,7 @@ func (pc *PodController) Run(ctx context.Context, podSyncWorkers int) (retErr er
 
 				if podShouldEnqueue(oldPod, newPod) {
 					pc.syncPodsFromKubernetes.Enqueue(ctx, key)
+					pc.notifyPodEvent(ctx, watch.Modified, newPod)
 				}
 			}
 		},
@@ -382,6 +393,7 @@ func (pc *PodController) Run(ctx context.Context, podSyncWorkers int) (retErr er
 				ctx = span.WithField(ctx, "key", key)
 				pc.knownPods.Delete(key)
 				pc.syncPodsFromKubernetes.Enqueue(ctx, key)
+				pc.notifyPodEvent(ctx, watch.Deleted, k8sPod)
 				// If this pod was in the deletion queue, forget about it
 				key = fmt.Sprintf("%v/%v", key, k8sPod.UID)
 				pc.deletePodsFromKubernetes.Forget(ctx, key)